@@ -1,3 +1,135 @@
 package main
 
-func main() {}
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"movies-auth/movies/internal/api/handlers"
+	"movies-auth/movies/internal/api/middlewares"
+	"movies-auth/movies/internal/clients/users"
+	"movies-auth/movies/internal/config"
+	"movies-auth/movies/internal/domain"
+	"movies-auth/movies/internal/services"
+	"movies-auth/movies/internal/storage/inmemory"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/joho/godotenv"
+	"github.com/spf13/viper"
+)
+
+func main() {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	cfgPath := os.Getenv("CONFIG_PATH")
+	cfgName := os.Getenv("CONFIG_NAME")
+
+	viper.AddConfigPath(cfgPath)
+	viper.SetConfigName(cfgName)
+	// общий с users токен не хранится в конфигурации
+	_ = viper.BindEnv("users.internal_token", "INTERNAL_TOKEN")
+
+	err = viper.ReadInConfig()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var cfg config.Config
+	err = viper.Unmarshal(&cfg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = cfg.UsersService.Validate()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	usersClient := users.NewClient(cfg.UsersService.BaseURL, cfg.UsersService.Timeout, cfg.UsersService.Retries, cfg.UsersService.RetryDelay)
+	sessionsCache := users.NewCachingClient(usersClient, cfg.SessionCache.TTL, cfg.SessionCache.NegativeTTL, cfg.SessionCache.MaxSize)
+	tokenVerifier := users.NewTokenVerifier(usersClient, cfg.UsersService.JWKSRefresh)
+
+	storage := inmemory.NewStorage()
+	actorsHandler := handlers.NewActorsHandler(services.NewActorsService(storage))
+	moviesHandler := handlers.NewMoviesHandler(services.NewMoviesService(storage))
+	sessionsHandler := handlers.NewSessionsHandler(sessionsCache)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.AllowContentType("application/json"))
+
+	// без токена служебные запросы не принимаются, кэш сессий очищается только по TTL
+	if cfg.UsersService.InternalToken != "" {
+		r.Route("/internal", func(r chi.Router) {
+			r.Use(middlewares.InternalToken(cfg.UsersService.InternalToken))
+			r.Delete("/sessions/{key}", sessionsHandler.Invalidate)
+		})
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Auth(sessionsCache, tokenVerifier))
+		r.Use(middlewares.RequirePermission(domain.PermissionMoviesRead))
+		write := middlewares.RequirePermission(domain.PermissionMoviesWrite)
+
+		r.Route("/actors", func(r chi.Router) {
+			r.With(write).Post("/", actorsHandler.Create)
+			r.Get("/", actorsHandler.List)
+			r.Get("/{id}", actorsHandler.Get)
+			r.With(write).Patch("/{id}", actorsHandler.Update)
+			r.With(write).Delete("/{id}", actorsHandler.Delete)
+		})
+		r.Route("/movies", func(r chi.Router) {
+			r.With(write).Post("/", moviesHandler.Create)
+			r.Get("/", moviesHandler.List)
+			r.Get("/{id}", moviesHandler.Get)
+			r.With(write).Patch("/{id}", moviesHandler.Update)
+			r.With(write).Delete("/{id}", moviesHandler.Delete)
+			r.With(write).Post("/{movie_id}/actors", moviesHandler.AddActors)
+			r.Get("/{movie_id}/actors", moviesHandler.Actors)
+		})
+	})
+
+	addr := fmt.Sprintf("%s:%d", cfg.ServerConfig.Host, cfg.ServerConfig.Port)
+
+	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+
+	srv := http.Server{
+		Addr:    addr,
+		Handler: r,
+	}
+	log.Println("starting server...")
+	go func() {
+		err = srv.ListenAndServe()
+		if errors.Is(err, http.ErrServerClosed) {
+			log.Println("server stopped")
+			return
+		}
+
+		log.Printf("unexpected server error: %s", err)
+	}()
+	log.Printf("server started on: %s", addr)
+
+	<-ctx.Done()
+	stop()
+
+	tCtx, tCancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer tCancel()
+	err = srv.Shutdown(tCtx)
+	if err != nil {
+		log.Printf("server shutdown error: %s", err)
+	}
+}
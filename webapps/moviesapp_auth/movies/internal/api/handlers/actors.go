@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+)
+
+type ActorsService interface {
+	Create(ctx context.Context, actor domain.Actor) (domain.Actor, error)
+	Get(ctx context.Context, id int) (domain.Actor, error)
+	Update(ctx context.Context, id int, patch domain.ActorPatch) (domain.Actor, error)
+	Delete(ctx context.Context, id int) error
+	List(ctx context.Context, filter domain.ActorsFilter) ([]domain.Actor, error)
+}
+
+type ActorsHandler struct {
+	ActorsService ActorsService
+}
+
+func NewActorsHandler(actorsService ActorsService) ActorsHandler {
+	return ActorsHandler{
+		ActorsService: actorsService,
+	}
+}
+
+func (h ActorsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var actor domain.Actor
+	err := json.NewDecoder(r.Body).Decode(&actor)
+	if err != nil {
+		http.Error(w, "error", http.StatusBadRequest)
+		return
+	}
+
+	createdActor, err := h.ActorsService.Create(r.Context(), actor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, createdActor)
+}
+
+func (h ActorsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r, "id")
+	if !ok {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	actor, err := h.ActorsService.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, actor)
+}
+
+func (h ActorsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r, "id")
+	if !ok {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var patch domain.ActorPatch
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		http.Error(w, "error", http.StatusBadRequest)
+		return
+	}
+
+	actor, err := h.ActorsService.Update(r.Context(), id, patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, actor)
+}
+
+func (h ActorsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r, "id")
+	if !ok {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	err := h.ActorsService.Delete(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h ActorsHandler) List(w http.ResponseWriter, r *http.Request) {
+	desc, ok := sortDesc(r)
+	if !ok {
+		http.Error(w, "invalid sort", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := domain.ActorsFilter{
+		Name:    query.Get("name"),
+		Country: query.Get("country"),
+		OrderBy: query.Get("order"),
+		Desc:    desc,
+	}
+
+	actors, err := h.ActorsService.List(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, actors)
+}
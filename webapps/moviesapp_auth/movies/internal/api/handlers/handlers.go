@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	respBytes, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(respBytes)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrInvalidParams):
+		http.Error(w, "invalid params", http.StatusBadRequest)
+	default:
+		log.Println(err)
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+}
+
+func idParam(r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// sortDesc разбирает query параметр sort: asc (по умолчанию) или desc.
+func sortDesc(r *http.Request) (bool, bool) {
+	switch r.URL.Query().Get("sort") {
+	case "", "asc":
+		return false, true
+	case "desc":
+		return true, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+)
+
+type MoviesService interface {
+	Create(ctx context.Context, movie domain.Movie) (domain.Movie, error)
+	Get(ctx context.Context, id int) (domain.Movie, error)
+	Update(ctx context.Context, id int, patch domain.MoviePatch) (domain.Movie, error)
+	Delete(ctx context.Context, id int) error
+	List(ctx context.Context, filter domain.MoviesFilter) ([]domain.Movie, error)
+	AddActors(ctx context.Context, movieID int, actorIDs []int) error
+	Actors(ctx context.Context, movieID int) ([]domain.Actor, error)
+}
+
+type MoviesHandler struct {
+	MoviesService MoviesService
+}
+
+func NewMoviesHandler(moviesService MoviesService) MoviesHandler {
+	return MoviesHandler{
+		MoviesService: moviesService,
+	}
+}
+
+func (h MoviesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var movie domain.Movie
+	err := json.NewDecoder(r.Body).Decode(&movie)
+	if err != nil {
+		http.Error(w, "error", http.StatusBadRequest)
+		return
+	}
+
+	createdMovie, err := h.MoviesService.Create(r.Context(), movie)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, createdMovie)
+}
+
+func (h MoviesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r, "id")
+	if !ok {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	movie, err := h.MoviesService.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, movie)
+}
+
+func (h MoviesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r, "id")
+	if !ok {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var patch domain.MoviePatch
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		http.Error(w, "error", http.StatusBadRequest)
+		return
+	}
+
+	movie, err := h.MoviesService.Update(r.Context(), id, patch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, movie)
+}
+
+func (h MoviesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r, "id")
+	if !ok {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	err := h.MoviesService.Delete(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h MoviesHandler) List(w http.ResponseWriter, r *http.Request) {
+	desc, ok := sortDesc(r)
+	if !ok {
+		http.Error(w, "invalid sort", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := domain.MoviesFilter{
+		Name:    query.Get("name"),
+		Genre:   query.Get("genre"),
+		OrderBy: query.Get("order"),
+		Desc:    desc,
+	}
+
+	movies, err := h.MoviesService.List(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, movies)
+}
+
+func (h MoviesHandler) AddActors(w http.ResponseWriter, r *http.Request) {
+	movieID, ok := idParam(r, "movie_id")
+	if !ok {
+		http.Error(w, "invalid movie id", http.StatusBadRequest)
+		return
+	}
+
+	var actorIDs []int
+	err := json.NewDecoder(r.Body).Decode(&actorIDs)
+	if err != nil {
+		http.Error(w, "error", http.StatusBadRequest)
+		return
+	}
+
+	err = h.MoviesService.AddActors(r.Context(), movieID, actorIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h MoviesHandler) Actors(w http.ResponseWriter, r *http.Request) {
+	movieID, ok := idParam(r, "movie_id")
+	if !ok {
+		http.Error(w, "invalid movie id", http.StatusBadRequest)
+		return
+	}
+
+	actors, err := h.MoviesService.Actors(r.Context(), movieID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, actors)
+}
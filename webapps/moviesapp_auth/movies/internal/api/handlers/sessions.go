@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type SessionsCache interface {
+	Invalidate(key uuid.UUID)
+}
+
+// SessionsHandler принимает от сервиса users уведомления о завершенных сессиях.
+type SessionsHandler struct {
+	Cache SessionsCache
+}
+
+func NewSessionsHandler(cache SessionsCache) SessionsHandler {
+	return SessionsHandler{
+		Cache: cache,
+	}
+}
+
+func (h SessionsHandler) Invalidate(w http.ResponseWriter, r *http.Request) {
+	sessionKey, err := uuid.Parse(chi.URLParam(r, "key"))
+	if err != nil {
+		http.Error(w, "invalid session key", http.StatusBadRequest)
+		return
+	}
+
+	h.Cache.Invalidate(sessionKey)
+	w.WriteHeader(http.StatusNoContent)
+}
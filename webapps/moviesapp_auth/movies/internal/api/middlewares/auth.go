@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type SessionsClient interface {
+	GetSession(ctx context.Context, key uuid.UUID) (domain.Session, error)
+}
+
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (domain.Session, error)
+}
+
+type sessionKey string
+
+var SessionKey sessionKey = "session"
+
+// Auth проверяет ключ сессии через сервис users или access токен по открытым ключам сервиса users.
+// Ключ или токен берется из заголовка Authorization или cookie session. verifier может быть nil,
+// тогда токены не принимаются. Отвечает 401, если сессия не признана, и 503, если сервис users недоступен.
+func Auth(client SessionsClient, verifier TokenVerifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			credential := sessionCredential(r)
+			if credential == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			var session domain.Session
+			sessionKey, err := uuid.Parse(credential)
+			switch {
+			case err == nil:
+				session, err = client.GetSession(r.Context(), sessionKey)
+			case verifier != nil:
+				session, err = verifier.VerifyToken(r.Context(), credential)
+			default:
+				err = domain.ErrUnauthorized
+			}
+			if err != nil {
+				if errors.Is(err, domain.ErrUnauthorized) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				log.Printf("session validation failed: %s", err)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), SessionKey, session)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func sessionCredential(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return ""
+	}
+
+	return sessionCookie.Value
+}
@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"movies-auth/movies/internal/clients/users"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAuth(t *testing.T) {
+	sessionKey := uuid.New()
+
+	testCases := []struct {
+		name           string
+		cookie         *http.Cookie
+		usersHandler   func(calls int32) http.HandlerFunc
+		stopUsers      bool
+		wantStatusCode int
+		wantCalls      int32
+	}{
+		{
+			name:           "fail_no_cookie",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "fail_invalid_cookie",
+			cookie:         &http.Cookie{Name: "session", Value: "abc"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "fail_unknown_session",
+			cookie: &http.Cookie{Name: "session", Value: sessionKey.String()},
+			usersHandler: func(calls int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantCalls:      1,
+		},
+		{
+			name:   "fail_users_error",
+			cookie: &http.Cookie{Name: "session", Value: sessionKey.String()},
+			usersHandler: func(calls int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantCalls:      3,
+		},
+		{
+			name:           "fail_users_down",
+			cookie:         &http.Cookie{Name: "session", Value: sessionKey.String()},
+			stopUsers:      true,
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "success_after_retry",
+			cookie: &http.Cookie{Name: "session", Value: sessionKey.String()},
+			usersHandler: func(calls int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if calls == 1 {
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					json.NewEncoder(w).Encode(domain.Session{Key: sessionKey, UserID: 7})
+				}
+			},
+			wantStatusCode: http.StatusOK,
+			wantCalls:      2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			usersSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				if r.URL.Path != "/users/sessions/"+sessionKey.String() {
+					t.Errorf("unexpected path: %s", r.URL.Path)
+				}
+				tc.usersHandler(n)(w, r)
+			}))
+			defer usersSrv.Close()
+			if tc.stopUsers {
+				usersSrv.Close()
+			}
+
+			client := users.NewClient(usersSrv.URL, time.Second, 2, time.Millisecond)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				session, ok := r.Context().Value(SessionKey).(domain.Session)
+				if !ok || session.UserID != 7 {
+					t.Errorf("expected session in context, got: %v", r.Context().Value(SessionKey))
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+			if tc.cookie != nil {
+				req.AddCookie(tc.cookie)
+			}
+			recorder := httptest.NewRecorder()
+
+			Auth(client, nil)(next).ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+
+			if atomic.LoadInt32(&calls) != tc.wantCalls {
+				t.Errorf("expected users service calls: %d, got: %d", tc.wantCalls, calls)
+			}
+		})
+	}
+}
+
+type tokenVerifierStub struct {
+	session domain.Session
+	err     error
+}
+
+func (v tokenVerifierStub) VerifyToken(ctx context.Context, token string) (domain.Session, error) {
+	return v.session, v.err
+}
+
+func TestAuthToken(t *testing.T) {
+	testCases := []struct {
+		name           string
+		verifier       TokenVerifier
+		header         string
+		wantStatusCode int
+	}{
+		{
+			name:           "fail_tokens_disabled",
+			header:         "Bearer token",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "fail_invalid_token",
+			verifier:       tokenVerifierStub{err: domain.ErrUnauthorized},
+			header:         "Bearer token",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "fail_keys_unavailable",
+			verifier:       tokenVerifierStub{err: domain.ErrUnavailable},
+			header:         "Bearer token",
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "success",
+			verifier:       tokenVerifierStub{session: domain.Session{UserID: 7}},
+			header:         "Bearer token",
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				session, ok := r.Context().Value(SessionKey).(domain.Session)
+				if !ok || session.UserID != 7 {
+					t.Errorf("expected session in context, got: %v", r.Context().Value(SessionKey))
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+			req.Header.Set("Authorization", tc.header)
+			recorder := httptest.NewRecorder()
+
+			// ключи сессий в этом тесте не проверяются, клиент сервиса users не нужен
+			Auth(nil, tc.verifier)(next).ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"log"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+)
+
+// RequirePermission пропускает запрос, только если у сессии, проверенной Auth, есть право permission.
+func RequirePermission(permission string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, ok := r.Context().Value(SessionKey).(domain.Session)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if !session.HasPermission(permission) {
+				log.Printf("user %d has no permission %s", session.UserID, permission)
+				http.Error(w, "permission denied", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
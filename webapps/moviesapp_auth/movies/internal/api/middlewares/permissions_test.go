@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"context"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirePermission(t *testing.T) {
+	handler := RequirePermission(domain.PermissionMoviesWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name           string
+		session        *domain.Session
+		wantStatusCode int
+	}{
+		{
+			name:           "fail_no_session",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "fail_viewer",
+			session:        &domain.Session{UserID: 1, Permissions: []string{domain.PermissionMoviesRead}},
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "success_editor",
+			session:        &domain.Session{UserID: 1, Permissions: []string{domain.PermissionMoviesRead, domain.PermissionMoviesWrite}},
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/movies", nil)
+			if tc.session != nil {
+				req = req.WithContext(context.WithValue(req.Context(), SessionKey, *tc.session))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, rec.Code)
+			}
+		})
+	}
+}
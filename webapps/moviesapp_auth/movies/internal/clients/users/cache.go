@@ -0,0 +1,169 @@
+package users
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"movies-auth/movies/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionsClient interface {
+	GetSession(ctx context.Context, key uuid.UUID) (domain.Session, error)
+}
+
+type cacheEntry struct {
+	key       uuid.UUID
+	session   domain.Session
+	err       error
+	expiresAt time.Time
+}
+
+// CachingClient кэширует результаты проверки сессий в памяти процесса.
+// Признанные сессии хранятся ttl, но не дольше срока жизни самой сессии,
+// непризнанные ключи - negativeTTL. Ошибки доступности сервиса users не кэшируются.
+// При переполнении вытесняются давно не использованные записи.
+type CachingClient struct {
+	client      SessionsClient
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxSize     int
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	lru     *list.List
+	// pending - ключи, которые сейчас запрашиваются у сервиса users
+	pending map[uuid.UUID]*pendingFetch
+}
+
+// pendingFetch - запросы одного ключа к сервису users. Invalidate увеличивает generation,
+// и ответ, полученный до инвалидации, не попадает в кэш.
+type pendingFetch struct {
+	count      int
+	generation uint64
+}
+
+func NewCachingClient(client SessionsClient, ttl time.Duration, negativeTTL time.Duration, maxSize int) *CachingClient {
+	return &CachingClient{
+		client:      client,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxSize:     maxSize,
+		entries:     make(map[uuid.UUID]*list.Element),
+		lru:         list.New(),
+		pending:     make(map[uuid.UUID]*pendingFetch),
+	}
+}
+
+func (c *CachingClient) GetSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	if entry, ok := c.get(key); ok {
+		return entry.session, entry.err
+	}
+
+	generation := c.beginFetch(key)
+	session, err := c.client.GetSession(ctx, key)
+	switch {
+	case err == nil:
+		expiresAt := time.Now().Add(c.ttl)
+		if !session.ExpiresAt.IsZero() && session.ExpiresAt.Before(expiresAt) {
+			expiresAt = session.ExpiresAt
+		}
+		c.finishFetch(key, generation, &cacheEntry{key: key, session: session, expiresAt: expiresAt})
+	case errors.Is(err, domain.ErrUnauthorized):
+		c.finishFetch(key, generation, &cacheEntry{key: key, err: err, expiresAt: time.Now().Add(c.negativeTTL)})
+	default:
+		c.finishFetch(key, generation, nil)
+	}
+
+	return session, err
+}
+
+// Invalidate удаляет сессию из кэша, например после выхода пользователя.
+func (c *CachingClient) Invalidate(key uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+	}
+	if fetch, ok := c.pending[key]; ok {
+		fetch.generation++
+	}
+}
+
+// beginFetch отмечает запрос ключа к сервису users и возвращает поколение ключа на момент запроса.
+func (c *CachingClient) beginFetch(key uuid.UUID) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fetch, ok := c.pending[key]
+	if !ok {
+		fetch = &pendingFetch{}
+		c.pending[key] = fetch
+	}
+	fetch.count++
+
+	return fetch.generation
+}
+
+// finishFetch завершает запрос, начатый beginFetch, и кэширует entry, если ключ не инвалидирован
+// за время запроса. nil entry ничего не кэширует.
+func (c *CachingClient) finishFetch(key uuid.UUID, generation uint64, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fetch := c.pending[key]
+	fetch.count--
+	if fetch.count == 0 {
+		delete(c.pending, key)
+	}
+	if entry == nil || fetch.generation != generation {
+		return
+	}
+
+	c.set(*entry)
+}
+
+func (c *CachingClient) get(key uuid.UUID) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	entry := el.Value.(cacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return cacheEntry{}, false
+	}
+	c.lru.MoveToFront(el)
+
+	return entry, true
+}
+
+// set сохраняет запись, вызывается под c.mu.
+func (c *CachingClient) set(entry cacheEntry) {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	for c.lru.Len() >= c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(cacheEntry).key)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+}
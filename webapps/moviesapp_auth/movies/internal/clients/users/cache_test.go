@@ -0,0 +1,167 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"movies-auth/movies/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type sessionsClientStub struct {
+	calls    map[uuid.UUID]int
+	sessions map[uuid.UUID]domain.Session
+	err      error
+	// inFlight вызывается, пока запрос еще не вернул ответ
+	inFlight func(key uuid.UUID)
+}
+
+func (c *sessionsClientStub) GetSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	c.calls[key]++
+	if c.inFlight != nil {
+		c.inFlight(key)
+	}
+	if c.err != nil {
+		return domain.Session{}, c.err
+	}
+
+	session, ok := c.sessions[key]
+	if !ok {
+		return domain.Session{}, domain.ErrUnauthorized
+	}
+
+	return session, nil
+}
+
+func newSessionsClientStub(keys ...uuid.UUID) *sessionsClientStub {
+	c := &sessionsClientStub{
+		calls:    make(map[uuid.UUID]int),
+		sessions: make(map[uuid.UUID]domain.Session),
+	}
+	for _, k := range keys {
+		c.sessions[k] = domain.Session{Key: k, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+
+	return c
+}
+
+func TestCachingClient(t *testing.T) {
+	known := uuid.New()
+	unknown := uuid.New()
+
+	t.Run("success_cached", func(t *testing.T) {
+		stub := newSessionsClientStub(known)
+		c := NewCachingClient(stub, time.Minute, time.Minute, 10)
+
+		for i := 0; i < 3; i++ {
+			session, err := c.GetSession(context.Background(), known)
+			if err != nil || session.Key != known {
+				t.Fatalf("expected session %s, got: %v, %v", known, session, err)
+			}
+		}
+
+		if stub.calls[known] != 1 {
+			t.Errorf("expected users service calls: 1, got: %d", stub.calls[known])
+		}
+	})
+
+	t.Run("success_negative_cached", func(t *testing.T) {
+		stub := newSessionsClientStub()
+		c := NewCachingClient(stub, time.Minute, time.Minute, 10)
+
+		for i := 0; i < 3; i++ {
+			_, err := c.GetSession(context.Background(), unknown)
+			if !errors.Is(err, domain.ErrUnauthorized) {
+				t.Fatalf("expected error: %v, got: %v", domain.ErrUnauthorized, err)
+			}
+		}
+
+		if stub.calls[unknown] != 1 {
+			t.Errorf("expected users service calls: 1, got: %d", stub.calls[unknown])
+		}
+	})
+
+	t.Run("fail_unavailable_not_cached", func(t *testing.T) {
+		stub := newSessionsClientStub(known)
+		stub.err = domain.ErrUnavailable
+		c := NewCachingClient(stub, time.Minute, time.Minute, 10)
+
+		c.GetSession(context.Background(), known)
+		c.GetSession(context.Background(), known)
+
+		if stub.calls[known] != 2 {
+			t.Errorf("expected users service calls: 2, got: %d", stub.calls[known])
+		}
+	})
+
+	t.Run("success_invalidate", func(t *testing.T) {
+		stub := newSessionsClientStub(known)
+		c := NewCachingClient(stub, time.Minute, time.Minute, 10)
+
+		c.GetSession(context.Background(), known)
+		delete(stub.sessions, known)
+		c.Invalidate(known)
+
+		_, err := c.GetSession(context.Background(), known)
+		if !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("expected error: %v, got: %v", domain.ErrUnauthorized, err)
+		}
+	})
+
+	t.Run("success_invalidate_during_fetch", func(t *testing.T) {
+		stub := newSessionsClientStub(known)
+		c := NewCachingClient(stub, time.Minute, time.Minute, 10)
+		// сессия завершена, пока ответ о ней еще в пути: устаревший ответ не должен попасть в кэш
+		stub.inFlight = func(key uuid.UUID) {
+			stub.inFlight = nil
+			c.Invalidate(key)
+		}
+
+		_, err := c.GetSession(context.Background(), known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delete(stub.sessions, known)
+
+		_, err = c.GetSession(context.Background(), known)
+		if !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("expected error: %v, got: %v", domain.ErrUnauthorized, err)
+		}
+		if len(c.pending) != 0 {
+			t.Errorf("expected no pending fetches, got: %d", len(c.pending))
+		}
+	})
+
+	t.Run("success_ttl_bounded_by_session", func(t *testing.T) {
+		stub := newSessionsClientStub(known)
+		stub.sessions[known] = domain.Session{Key: known, ExpiresAt: time.Now().Add(-time.Second)}
+		c := NewCachingClient(stub, time.Minute, time.Minute, 10)
+
+		c.GetSession(context.Background(), known)
+		c.GetSession(context.Background(), known)
+
+		if stub.calls[known] != 2 {
+			t.Errorf("expected users service calls: 2, got: %d", stub.calls[known])
+		}
+	})
+
+	t.Run("success_size_bound", func(t *testing.T) {
+		first, second, third := uuid.New(), uuid.New(), uuid.New()
+		stub := newSessionsClientStub(first, second, third)
+		c := NewCachingClient(stub, time.Minute, time.Minute, 2)
+
+		c.GetSession(context.Background(), first)
+		c.GetSession(context.Background(), second)
+		c.GetSession(context.Background(), first)
+		c.GetSession(context.Background(), third)
+
+		if len(c.entries) != 2 {
+			t.Fatalf("expected cache size: 2, got: %d", len(c.entries))
+		}
+		if _, ok := c.entries[second]; ok {
+			t.Errorf("expected least recently used session to be evicted")
+		}
+	})
+}
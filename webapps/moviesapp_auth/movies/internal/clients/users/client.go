@@ -0,0 +1,113 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Client - клиент API сервиса users.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	retryDelay time.Duration
+}
+
+// NewClient создает клиента сервиса users. timeout ограничивает одну попытку запроса,
+// неудачные из-за сети или ответа 5xx попытки повторяются до retries раз
+// с экспоненциально растущей паузой, начиная с retryDelay.
+func NewClient(baseURL string, timeout time.Duration, retries int, retryDelay time.Duration) *Client {
+	return &Client{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		retries:    retries,
+		retryDelay: retryDelay,
+	}
+}
+
+// GetSession возвращает сессию по ключу.
+// domain.ErrUnauthorized означает, что сервис users не признал сессию,
+// domain.ErrUnavailable - что сервис users не удалось опросить.
+func (c *Client) GetSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	endpoint, err := url.JoinPath(c.baseURL, "users", "sessions", key.String())
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	var session domain.Session
+	err = c.get(ctx, endpoint, &session)
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	return session, nil
+}
+
+func (c *Client) get(ctx context.Context, endpoint string, dst any) error {
+	var lastErr error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			delay := c.retryDelay * time.Duration(1<<(attempt-1))
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %s", domain.ErrUnavailable, ctx.Err())
+			case <-time.After(delay):
+			}
+		}
+
+		retry, err := c.doGet(ctx, endpoint, dst)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			return err
+		}
+		lastErr = err
+	}
+
+	return fmt.Errorf("%w: %s", domain.ErrUnavailable, lastErr)
+}
+
+// doGet выполняет одну попытку запроса и сообщает, имеет ли смысл ее повторить.
+func (c *Client) doGet(ctx context.Context, endpoint string, dst any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return false, fmt.Errorf("%w: %s", domain.ErrUnavailable, err)
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		err = json.NewDecoder(resp.Body).Decode(dst)
+		if err != nil {
+			return false, fmt.Errorf("%w: invalid response: %s", domain.ErrUnavailable, err)
+		}
+		return false, nil
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return true, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusBadRequest:
+		return false, domain.ErrUnauthorized
+	default:
+		return false, fmt.Errorf("%w: unexpected status code: %d", domain.ErrUnavailable, resp.StatusCode)
+	}
+}
@@ -0,0 +1,184 @@
+package users
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"movies-auth/movies/internal/domain"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	KeyID   string `json:"kid"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// getJWKS возвращает открытые ключи Ed25519, которыми сервис users подписывает access токены.
+func (c *Client) getJWKS(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	endpoint, err := url.JoinPath(c.baseURL, "users", "sessions", "jwks")
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwks
+	err = c.get(ctx, endpoint, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.KeyType != "OKP" || key.Curve != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[key.KeyID] = ed25519.PublicKey(x)
+	}
+
+	return keys, nil
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Subject     string   `json:"sub"`
+	SessionID   string   `json:"sid"`
+	IssuedAt    int64    `json:"iat"`
+	ExpiresAt   int64    `json:"exp"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// TokenVerifier проверяет access токены сервиса users по его открытым ключам, не обращаясь к нему
+// на каждый запрос. Ключи загружаются при первом токене и перезагружаются, когда встречается
+// неизвестный kid, но не чаще minRefresh. Токен остается действительным до истечения срока
+// даже после выхода пользователя.
+type TokenVerifier struct {
+	client     *Client
+	minRefresh time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]ed25519.PublicKey
+	fetchedAt time.Time
+}
+
+func NewTokenVerifier(client *Client, minRefresh time.Duration) *TokenVerifier {
+	return &TokenVerifier{
+		client:     client,
+		minRefresh: minRefresh,
+	}
+}
+
+// VerifyToken возвращает сессию из access токена.
+// domain.ErrUnauthorized означает, что токен не признан, domain.ErrUnavailable - что не удалось получить ключи.
+func (v *TokenVerifier) VerifyToken(ctx context.Context, token string) (domain.Session, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return domain.Session{}, fmt.Errorf("%w: malformed token", domain.ErrUnauthorized)
+	}
+
+	var header tokenHeader
+	err := decodeTokenPart(parts[0], &header)
+	if err != nil {
+		return domain.Session{}, err
+	}
+	// сервис users публикует только ключи EdDSA
+	if header.Algorithm != "EdDSA" {
+		return domain.Session{}, fmt.Errorf("%w: unsupported algorithm %q", domain.ErrUnauthorized, header.Algorithm)
+	}
+
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), signature) {
+		return domain.Session{}, fmt.Errorf("%w: bad signature", domain.ErrUnauthorized)
+	}
+
+	var claims tokenClaims
+	err = decodeTokenPart(parts[1], &claims)
+	if err != nil {
+		return domain.Session{}, err
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return domain.Session{}, fmt.Errorf("%w: token expired", domain.ErrUnauthorized)
+	}
+
+	sessionID, err := strconv.Atoi(claims.SessionID)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("%w: invalid session id", domain.ErrUnauthorized)
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("%w: invalid subject", domain.ErrUnauthorized)
+	}
+
+	return domain.Session{
+		ID:          sessionID,
+		UserID:      userID,
+		StartedAt:   time.Unix(claims.IssuedAt, 0).UTC(),
+		ExpiresAt:   time.Unix(claims.ExpiresAt, 0).UTC(),
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}, nil
+}
+
+func (v *TokenVerifier) key(ctx context.Context, keyID string) (ed25519.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[keyID]; ok {
+		return key, nil
+	}
+	if v.keys != nil && time.Since(v.fetchedAt) < v.minRefresh {
+		return nil, fmt.Errorf("%w: unknown key %q", domain.ErrUnauthorized, keyID)
+	}
+
+	keys, err := v.client.getJWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	key, ok := v.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", domain.ErrUnauthorized, keyID)
+	}
+
+	return key, nil
+}
+
+func decodeTokenPart(part string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", domain.ErrUnauthorized)
+	}
+
+	err = json.Unmarshal(data, dst)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", domain.ErrUnauthorized)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package users
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"movies-auth/movies/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func signTestToken(t *testing.T, key ed25519.PrivateKey, keyID string, claims tokenClaims) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signed)))
+}
+
+func TestTokenVerifier(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int32
+	usersSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != "/users/sessions/jwks" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(jwks{Keys: []jwk{
+			{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(public), KeyID: "key-1"},
+		}})
+	}))
+	defer usersSrv.Close()
+
+	verifier := NewTokenVerifier(NewClient(usersSrv.URL, time.Second, 0, time.Millisecond), time.Hour)
+	claims := tokenClaims{Subject: strconv.Itoa(7), SessionID: strconv.Itoa(3), ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		Permissions: []string{domain.PermissionMoviesRead}}
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "success",
+			token: signTestToken(t, private, "key-1", claims),
+		},
+		{
+			name:    "fail_malformed",
+			token:   "token",
+			wantErr: domain.ErrUnauthorized,
+		},
+		{
+			name:    "fail_other_key",
+			token:   signTestToken(t, otherPrivate, "key-1", claims),
+			wantErr: domain.ErrUnauthorized,
+		},
+		{
+			name:    "fail_unknown_key",
+			token:   signTestToken(t, private, "key-2", claims),
+			wantErr: domain.ErrUnauthorized,
+		},
+		{
+			name: "fail_expired",
+			token: signTestToken(t, private, "key-1", tokenClaims{Subject: "7", SessionID: "3",
+				ExpiresAt: time.Now().Add(-time.Second).Unix()}),
+			wantErr: domain.ErrUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			session, err := verifier.VerifyToken(context.Background(), tc.token)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil && (session.ID != 3 || session.UserID != 7 ||
+				!session.HasPermission(domain.PermissionMoviesRead)) {
+				t.Errorf("unexpected session: %+v", session)
+			}
+		})
+	}
+
+	// неизвестный kid не приводит к повторной загрузке ключей чаще minRefresh
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected keys fetched once, got: %d", calls)
+	}
+}
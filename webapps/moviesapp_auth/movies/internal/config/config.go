@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	ServerConfig ServerConfig `mapstructure:"server"`
+	UsersService UsersService `mapstructure:"users"`
+	SessionCache SessionCache `mapstructure:"session_cache"`
+}
+
+type ServerConfig struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+}
+
+type UsersService struct {
+	BaseURL    string        `mapstructure:"base_url"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	Retries    int           `mapstructure:"retries"`
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+	// InternalToken - общий с сервисом users токен для служебных запросов, можно задать переменной
+	// окружения INTERNAL_TOKEN. Пустой токен отключает служебные запросы
+	InternalToken string `mapstructure:"internal_token"`
+	// JWKSRefresh - как часто можно перезагружать ключи проверки access токенов
+	JWKSRefresh time.Duration `mapstructure:"jwks_refresh"`
+}
+
+// defaultInternalTokens - заглушки из примеров конфигурации, с ними служебные запросы не защищены.
+var defaultInternalTokens = []string{"change-me", "changeme", "secret", "token"}
+
+// Validate проверяет, что токен для служебных запросов не оставлен заглушкой.
+func (u UsersService) Validate() error {
+	for _, token := range defaultInternalTokens {
+		if strings.EqualFold(u.InternalToken, token) {
+			return fmt.Errorf("users.internal_token must not be the default value %q", u.InternalToken)
+		}
+	}
+
+	return nil
+}
+
+type SessionCache struct {
+	TTL         time.Duration `mapstructure:"ttl"`
+	NegativeTTL time.Duration `mapstructure:"negative_ttl"`
+	MaxSize     int           `mapstructure:"max_size"`
+}
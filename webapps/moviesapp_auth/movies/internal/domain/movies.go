@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Actor struct {
+	ID           int    `json:"id"`
+	FullName     string `json:"fullName"`
+	BirthYear    int    `json:"birthYear"`
+	BirthCountry string `json:"birthCountry"`
+	Gender       string `json:"gender"`
+}
+
+// ActorPatch - частичное обновление актера, nil поля не изменяются.
+type ActorPatch struct {
+	FullName     *string `json:"fullName"`
+	BirthYear    *int    `json:"birthYear"`
+	BirthCountry *string `json:"birthCountry"`
+	Gender       *string `json:"gender"`
+}
+
+type Movie struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ReleaseDate time.Time `json:"releaseDate"`
+	Country     string    `json:"country"`
+	Genre       string    `json:"genre"`
+	Rating      int       `json:"rating"`
+}
+
+// MoviePatch - частичное обновление фильма, nil поля не изменяются.
+type MoviePatch struct {
+	Name        *string    `json:"name"`
+	ReleaseDate *time.Time `json:"releaseDate"`
+	Country     *string    `json:"country"`
+	Genre       *string    `json:"genre"`
+	Rating      *int       `json:"rating"`
+}
+
+const (
+	ActorsOrderByName      = "name"
+	ActorsOrderByCountry   = "country"
+	ActorsOrderByBirthdate = "birthdate"
+
+	MoviesOrderByName  = "name"
+	MoviesOrderByGenre = "genre"
+	MoviesOrderByDate  = "date"
+)
+
+type ActorsFilter struct {
+	Name    string
+	Country string
+	OrderBy string
+	Desc    bool
+}
+
+type MoviesFilter struct {
+	Name    string
+	Genre   string
+	OrderBy string
+	Desc    bool
+}
+
+// Session - сессия пользователя, полученная от сервиса users.
+type Session struct {
+	ID         int       `json:"id"`
+	Key        uuid.UUID `json:"key"`
+	UserID     int       `json:"userId"`
+	StartedAt  time.Time `json:"startedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	// Roles и Permissions - роли пользователя и их права, назначенные в сервисе users
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+const (
+	PermissionMoviesRead  = "movies:read"
+	PermissionMoviesWrite = "movies:write"
+)
+
+// HasPermission сообщает, есть ли у пользователя сессии право permission.
+func (s Session) HasPermission(permission string) bool {
+	for _, p := range s.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+var ErrNotFound = errors.New("not found")
+var ErrInvalidParams = errors.New("invalid params")
+var ErrUnauthorized = errors.New("unauthorized")
+var ErrUnavailable = errors.New("service unavailable")
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"movies-auth/movies/internal/domain"
+)
+
+type ActorsStorage interface {
+	InsertActor(ctx context.Context, actor domain.Actor) (domain.Actor, error)
+	GetActor(ctx context.Context, id int) (domain.Actor, error)
+	UpdateActor(ctx context.Context, actor domain.Actor) (domain.Actor, error)
+	DeleteActor(ctx context.Context, id int) error
+	ListActors(ctx context.Context, filter domain.ActorsFilter) ([]domain.Actor, error)
+}
+
+type ActorsService struct {
+	Storage ActorsStorage
+}
+
+func NewActorsService(storage ActorsStorage) *ActorsService {
+	return &ActorsService{
+		Storage: storage,
+	}
+}
+
+func (s *ActorsService) Create(ctx context.Context, actor domain.Actor) (domain.Actor, error) {
+	err := validateActor(actor)
+	if err != nil {
+		return domain.Actor{}, err
+	}
+
+	return s.Storage.InsertActor(ctx, actor)
+}
+
+func (s *ActorsService) Get(ctx context.Context, id int) (domain.Actor, error) {
+	return s.Storage.GetActor(ctx, id)
+}
+
+func (s *ActorsService) Update(ctx context.Context, id int, patch domain.ActorPatch) (domain.Actor, error) {
+	actor, err := s.Storage.GetActor(ctx, id)
+	if err != nil {
+		return domain.Actor{}, err
+	}
+
+	if patch.FullName != nil {
+		actor.FullName = *patch.FullName
+	}
+	if patch.BirthYear != nil {
+		actor.BirthYear = *patch.BirthYear
+	}
+	if patch.BirthCountry != nil {
+		actor.BirthCountry = *patch.BirthCountry
+	}
+	if patch.Gender != nil {
+		actor.Gender = *patch.Gender
+	}
+
+	err = validateActor(actor)
+	if err != nil {
+		return domain.Actor{}, err
+	}
+
+	return s.Storage.UpdateActor(ctx, actor)
+}
+
+func (s *ActorsService) Delete(ctx context.Context, id int) error {
+	return s.Storage.DeleteActor(ctx, id)
+}
+
+func (s *ActorsService) List(ctx context.Context, filter domain.ActorsFilter) ([]domain.Actor, error) {
+	switch filter.OrderBy {
+	case "", domain.ActorsOrderByName, domain.ActorsOrderByCountry, domain.ActorsOrderByBirthdate:
+	default:
+		return nil, fmt.Errorf("unknown order %q: %w", filter.OrderBy, domain.ErrInvalidParams)
+	}
+
+	return s.Storage.ListActors(ctx, filter)
+}
+
+func validateActor(actor domain.Actor) error {
+	if actor.FullName == "" {
+		return fmt.Errorf("full name required: %w", domain.ErrInvalidParams)
+	}
+	if actor.BirthYear <= 0 {
+		return fmt.Errorf("birth year required: %w", domain.ErrInvalidParams)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"movies-auth/movies/internal/domain"
+)
+
+type MoviesStorage interface {
+	InsertMovie(ctx context.Context, movie domain.Movie) (domain.Movie, error)
+	GetMovie(ctx context.Context, id int) (domain.Movie, error)
+	UpdateMovie(ctx context.Context, movie domain.Movie) (domain.Movie, error)
+	DeleteMovie(ctx context.Context, id int) error
+	ListMovies(ctx context.Context, filter domain.MoviesFilter) ([]domain.Movie, error)
+	AddMovieActors(ctx context.Context, movieID int, actorIDs []int) error
+	GetMovieActors(ctx context.Context, movieID int) ([]domain.Actor, error)
+}
+
+type MoviesService struct {
+	Storage MoviesStorage
+}
+
+func NewMoviesService(storage MoviesStorage) *MoviesService {
+	return &MoviesService{
+		Storage: storage,
+	}
+}
+
+func (s *MoviesService) Create(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
+	err := validateMovie(movie)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	return s.Storage.InsertMovie(ctx, movie)
+}
+
+func (s *MoviesService) Get(ctx context.Context, id int) (domain.Movie, error) {
+	return s.Storage.GetMovie(ctx, id)
+}
+
+func (s *MoviesService) Update(ctx context.Context, id int, patch domain.MoviePatch) (domain.Movie, error) {
+	movie, err := s.Storage.GetMovie(ctx, id)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	if patch.Name != nil {
+		movie.Name = *patch.Name
+	}
+	if patch.ReleaseDate != nil {
+		movie.ReleaseDate = *patch.ReleaseDate
+	}
+	if patch.Country != nil {
+		movie.Country = *patch.Country
+	}
+	if patch.Genre != nil {
+		movie.Genre = *patch.Genre
+	}
+	if patch.Rating != nil {
+		movie.Rating = *patch.Rating
+	}
+
+	err = validateMovie(movie)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	return s.Storage.UpdateMovie(ctx, movie)
+}
+
+func (s *MoviesService) Delete(ctx context.Context, id int) error {
+	return s.Storage.DeleteMovie(ctx, id)
+}
+
+func (s *MoviesService) List(ctx context.Context, filter domain.MoviesFilter) ([]domain.Movie, error) {
+	switch filter.OrderBy {
+	case "", domain.MoviesOrderByName, domain.MoviesOrderByGenre, domain.MoviesOrderByDate:
+	default:
+		return nil, fmt.Errorf("unknown order %q: %w", filter.OrderBy, domain.ErrInvalidParams)
+	}
+
+	return s.Storage.ListMovies(ctx, filter)
+}
+
+func (s *MoviesService) AddActors(ctx context.Context, movieID int, actorIDs []int) error {
+	if len(actorIDs) == 0 {
+		return fmt.Errorf("actor ids required: %w", domain.ErrInvalidParams)
+	}
+
+	return s.Storage.AddMovieActors(ctx, movieID, actorIDs)
+}
+
+func (s *MoviesService) Actors(ctx context.Context, movieID int) ([]domain.Actor, error) {
+	return s.Storage.GetMovieActors(ctx, movieID)
+}
+
+func validateMovie(movie domain.Movie) error {
+	if movie.Name == "" {
+		return fmt.Errorf("name required: %w", domain.ErrInvalidParams)
+	}
+	if movie.Rating < 1 || movie.Rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5: %w", domain.ErrInvalidParams)
+	}
+
+	return nil
+}
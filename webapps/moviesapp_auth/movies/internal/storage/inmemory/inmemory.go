@@ -0,0 +1,288 @@
+package inmemory
+
+import (
+	"context"
+	"movies-auth/movies/internal/domain"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Storage struct {
+	mu          sync.RWMutex
+	actors      []domain.Actor
+	movies      []domain.Movie
+	movieActors map[int][]int
+	lastActorID int
+	lastMovieID int
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		actors:      make([]domain.Actor, 0),
+		movies:      make([]domain.Movie, 0),
+		movieActors: make(map[int][]int),
+	}
+}
+
+func (s *Storage) InsertActor(ctx context.Context, actor domain.Actor) (domain.Actor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastActorID++
+	actor.ID = s.lastActorID
+	s.actors = append(s.actors, actor)
+
+	return actor, nil
+}
+
+func (s *Storage) GetActor(ctx context.Context, id int) (domain.Actor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.actorIndex(id)
+	if i < 0 {
+		return domain.Actor{}, domain.ErrNotFound
+	}
+
+	return s.actors[i], nil
+}
+
+func (s *Storage) UpdateActor(ctx context.Context, actor domain.Actor) (domain.Actor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.actorIndex(actor.ID)
+	if i < 0 {
+		return domain.Actor{}, domain.ErrNotFound
+	}
+	s.actors[i] = actor
+
+	return actor, nil
+}
+
+func (s *Storage) DeleteActor(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.actorIndex(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.actors = append(s.actors[:i], s.actors[i+1:]...)
+
+	for movieID, actorIDs := range s.movieActors {
+		s.movieActors[movieID] = removeID(actorIDs, id)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListActors(ctx context.Context, filter domain.ActorsFilter) ([]domain.Actor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	actors := make([]domain.Actor, 0, len(s.actors))
+	for _, a := range s.actors {
+		if filter.Name != "" && !containsFold(a.FullName, filter.Name) {
+			continue
+		}
+		if filter.Country != "" && !strings.EqualFold(a.BirthCountry, filter.Country) {
+			continue
+		}
+		actors = append(actors, a)
+	}
+
+	sort.SliceStable(actors, func(i, j int) bool {
+		a, b := actors[i], actors[j]
+		if filter.Desc {
+			a, b = b, a
+		}
+
+		switch filter.OrderBy {
+		case domain.ActorsOrderByName:
+			return a.FullName < b.FullName
+		case domain.ActorsOrderByCountry:
+			return a.BirthCountry < b.BirthCountry
+		case domain.ActorsOrderByBirthdate:
+			return a.BirthYear < b.BirthYear
+		default:
+			return a.ID < b.ID
+		}
+	})
+
+	return actors, nil
+}
+
+func (s *Storage) InsertMovie(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastMovieID++
+	movie.ID = s.lastMovieID
+	s.movies = append(s.movies, movie)
+
+	return movie, nil
+}
+
+func (s *Storage) GetMovie(ctx context.Context, id int) (domain.Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.movieIndex(id)
+	if i < 0 {
+		return domain.Movie{}, domain.ErrNotFound
+	}
+
+	return s.movies[i], nil
+}
+
+func (s *Storage) UpdateMovie(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.movieIndex(movie.ID)
+	if i < 0 {
+		return domain.Movie{}, domain.ErrNotFound
+	}
+	s.movies[i] = movie
+
+	return movie, nil
+}
+
+func (s *Storage) DeleteMovie(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.movieIndex(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.movies = append(s.movies[:i], s.movies[i+1:]...)
+	delete(s.movieActors, id)
+
+	return nil
+}
+
+func (s *Storage) ListMovies(ctx context.Context, filter domain.MoviesFilter) ([]domain.Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	movies := make([]domain.Movie, 0, len(s.movies))
+	for _, m := range s.movies {
+		if filter.Name != "" && !containsFold(m.Name, filter.Name) {
+			continue
+		}
+		if filter.Genre != "" && !strings.EqualFold(m.Genre, filter.Genre) {
+			continue
+		}
+		movies = append(movies, m)
+	}
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		a, b := movies[i], movies[j]
+		if filter.Desc {
+			a, b = b, a
+		}
+
+		switch filter.OrderBy {
+		case domain.MoviesOrderByName:
+			return a.Name < b.Name
+		case domain.MoviesOrderByGenre:
+			return a.Genre < b.Genre
+		case domain.MoviesOrderByDate:
+			return a.ReleaseDate.Before(b.ReleaseDate)
+		default:
+			return a.ID < b.ID
+		}
+	})
+
+	return movies, nil
+}
+
+func (s *Storage) AddMovieActors(ctx context.Context, movieID int, actorIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.movieIndex(movieID) < 0 {
+		return domain.ErrNotFound
+	}
+	for _, id := range actorIDs {
+		if s.actorIndex(id) < 0 {
+			return domain.ErrNotFound
+		}
+	}
+
+	existing := s.movieActors[movieID]
+	for _, id := range actorIDs {
+		if !containsID(existing, id) {
+			existing = append(existing, id)
+		}
+	}
+	s.movieActors[movieID] = existing
+
+	return nil
+}
+
+func (s *Storage) GetMovieActors(ctx context.Context, movieID int) ([]domain.Actor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.movieIndex(movieID) < 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	actors := make([]domain.Actor, 0, len(s.movieActors[movieID]))
+	for _, id := range s.movieActors[movieID] {
+		if i := s.actorIndex(id); i >= 0 {
+			actors = append(actors, s.actors[i])
+		}
+	}
+
+	return actors, nil
+}
+
+func (s *Storage) actorIndex(id int) int {
+	for i := range s.actors {
+		if s.actors[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Storage) movieIndex(id int) int {
+	for i := range s.movies {
+		if s.movies[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeID(ids []int, id int) []int {
+	result := ids[:0]
+	for _, v := range ids {
+		if v != id {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
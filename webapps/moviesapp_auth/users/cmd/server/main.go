@@ -2,19 +2,25 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"movies-auth/users/internal/api/handlers"
 	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/audit"
+	"movies-auth/users/internal/clients/movies"
 	"movies-auth/users/internal/config"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/lifecycle"
+	"movies-auth/users/internal/logging"
+	"movies-auth/users/internal/metrics"
+	"movies-auth/users/internal/passwords"
+	"movies-auth/users/internal/ratelimit"
 	"movies-auth/users/internal/services"
-	"movies-auth/users/internal/storage/db"
+	"movies-auth/users/internal/workers"
 	"net/http"
 	"os"
 	"os/signal"
-	"time"
+	"syscall"
 
 	"github.com/go-chi/chi/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -25,7 +31,7 @@ import (
 func main() {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println(err)
+		slog.Error(err.Error())
 		return
 	}
 
@@ -34,78 +40,248 @@ func main() {
 
 	viper.AddConfigPath(cfgPath)
 	viper.SetConfigName(cfgName)
+	// общий с movies токен не хранится в конфигурации
+	_ = viper.BindEnv("movies.internal_token", "INTERNAL_TOKEN")
 
 	err = viper.ReadInConfig()
 	if err != nil {
-		log.Println(err)
+		slog.Error(err.Error())
 		return
 	}
 
 	var cfg config.Config
 	err = viper.Unmarshal(&cfg)
 	if err != nil {
-		log.Println(err)
+		slog.Error(err.Error())
 		return
 	}
 
-	dbCon, err := sql.Open("pgx", cfg.DBConfig.ConnectionString())
+	logger, err := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
 	if err != nil {
-		log.Println(err)
+		slog.Error(err.Error())
 		return
 	}
-	err = dbCon.Ping()
+	slog.SetDefault(logger)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		dbCon, err := openDB(cfg.DBConfig)
+		if err != nil {
+			slog.Error(err.Error())
+			return
+		}
+		defer dbCon.Close()
+
+		err = runMigrate(context.Background(), dbCon, os.Args[2:])
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		return
+	}
+
+	passwordManager, err := passwords.NewDefaultManager(cfg.Passwords.Algorithm)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+
+	store, closeStorage, err := openStorage(cfg)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+
+	notifier, closeNotifier, err := openNotifier(cfg.Notifications)
 	if err != nil {
-		log.Printf("failed to connect to db %v", err)
+		slog.Error(err.Error())
+		closeStorage()
 		return
 	}
 
-	dbStorage := db.NewDbStorage(dbCon)
-	usersService := services.NewUsersService(dbStorage)
-	sessionsService := services.NewSessionService(dbStorage)
+	passwordPolicy := passwords.Policy{
+		MinLength:     cfg.Passwords.MinLength,
+		RequireUpper:  cfg.Passwords.RequireUpper,
+		RequireLower:  cfg.Passwords.RequireLower,
+		RequireDigit:  cfg.Passwords.RequireDigit,
+		RequireSymbol: cfg.Passwords.RequireSymbol,
+		MaxAge:        cfg.Passwords.MaxAge,
+		HistorySize:   cfg.Passwords.History,
+	}
+	loginProtection := services.LoginProtection{
+		MaxFailures:     cfg.Login.MaxFailures,
+		LockoutDuration: cfg.Login.LockoutDuration,
+		TOTPIssuer:      cfg.Login.TOTPIssuer,
+		ChallengeTTL:    cfg.Login.ChallengeTTL,
+	}
+	if cfg.Login.LoginLimit > 0 {
+		loginProtection.Limiter = ratelimit.NewLimiter(store, cfg.Login.LoginLimit, cfg.Login.Window)
+	}
+	verification := services.AccountVerification{
+		RequireVerified: cfg.Accounts.RequireVerified,
+		VerificationTTL: cfg.Accounts.VerificationTTL,
+		ResetTTL:        cfg.Accounts.ResetTTL,
+	}
+	if cfg.Accounts.MailerChannel != "" {
+		mailer, ok := notifier.Channel(cfg.Accounts.MailerChannel)
+		if ok {
+			verification.Mailer = mailer
+		} else {
+			slog.Warn("mailer channel not configured, email verification and password reset disabled",
+				slog.String("channel", cfg.Accounts.MailerChannel))
+		}
+	}
+	if verification.RequireVerified && verification.Mailer == nil {
+		slog.Error("accounts.require_verified needs a configured mailer channel")
+		closeNotifier()
+		closeStorage()
+		return
+	}
+	auditRecorder := audit.NewRecorder(store)
+	usersService := services.NewUsersService(store, passwordManager, passwordPolicy, loginProtection, verification)
+	usersService.Audit = auditRecorder
+
+	if len(os.Args) > 1 && os.Args[1] == "roles" {
+		err = runRoles(context.Background(), usersService, os.Args[2:])
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		closeNotifier()
+		closeStorage()
+		return
+	}
+
+	err = cfg.Movies.Validate()
+	if err != nil {
+		slog.Error(err.Error())
+		closeNotifier()
+		closeStorage()
+		return
+	}
+	var sessionsInvalidator services.SessionsInvalidator
+	if cfg.Movies.BaseURL != "" {
+		sessionsInvalidator = movies.NewClient(cfg.Movies.BaseURL, cfg.Movies.InternalToken, cfg.Movies.Timeout)
+	}
+	var sessionsService *services.SessionsService
+	switch cfg.Sessions.Mode {
+	case sessionModeCookie, "":
+		sessionsService = services.NewSessionService(store, sessionsInvalidator, cfg.Sessions.Lifetime, cfg.Sessions.IdleTimeout)
+	case sessionModeToken:
+		accessTokens, err := openTokens(cfg.Tokens)
+		if err != nil {
+			slog.Error(err.Error())
+			closeNotifier()
+			closeStorage()
+			return
+		}
+		sessionsService = services.NewTokenSessionService(store, sessionsInvalidator, cfg.Sessions.Lifetime, cfg.Sessions.IdleTimeout,
+			accessTokens, cfg.Tokens.AccessTTL)
+	default:
+		slog.Error("unknown sessions mode", slog.String("mode", cfg.Sessions.Mode))
+		closeNotifier()
+		closeStorage()
+		return
+	}
+	sessionsService.Audit = auditRecorder
 	usersHandler := handlers.NewUsersHandler(usersService, sessionsService)
+	auditHandler := handlers.NewAuditHandler(services.NewAuditService(store))
 
 	r := chi.NewRouter()
+	r.Use(middlewares.RequestID, middlewares.Logger(logger), middlewares.Audit, middlewares.Metrics)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		middlewares.WriteError(w, r, domain.ErrNotFound)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		middlewares.WriteProblem(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	})
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
 	r.Route("/users", func(r chi.Router) {
 		r.Use(middlewares.Auth(sessionsService))
 		r.Post("/register", usersHandler.Register)
-		r.Post("/login", usersHandler.Login)
 		r.Post("/logout", usersHandler.Logout)
-		r.Get("/list", usersHandler.List)
+		r.Post("/refresh", usersHandler.Refresh)
+		r.Group(func(r chi.Router) {
+			if cfg.Login.IPLimit > 0 {
+				r.Use(middlewares.RateLimit(ratelimit.NewLimiter(store, cfg.Login.IPLimit, cfg.Login.Window)))
+			}
+			r.Post("/login", usersHandler.Login)
+			r.Post("/login/2fa", usersHandler.LoginSecondFactor)
+			r.Post("/password", usersHandler.ChangePassword)
+			if verification.Mailer != nil {
+				r.Post("/email/verify/request", usersHandler.RequestEmailVerification)
+				r.Post("/email/verify", usersHandler.VerifyEmail)
+				r.Post("/password/reset/request", usersHandler.RequestPasswordReset)
+				r.Post("/password/reset", usersHandler.ResetPassword)
+			}
+		})
+		r.With(middlewares.RequirePermission(domain.PermissionUsersRead)).Get("/list", usersHandler.List)
+		r.Get("/me/sessions", usersHandler.MySessions)
+		r.Delete("/me/sessions", usersHandler.RevokeMySessions)
+		r.Delete("/me/sessions/{id}", usersHandler.RevokeMySession)
+		r.Get("/me/2fa", usersHandler.TwoFactorStatus)
+		r.Post("/me/2fa", usersHandler.BeginTwoFactor)
+		r.Get("/me/2fa/qr", usersHandler.TwoFactorQRCode)
+		r.Post("/me/2fa/confirm", usersHandler.ConfirmTwoFactor)
+		r.Post("/me/2fa/disable", usersHandler.DisableTwoFactor)
+		r.Get("/sessions/jwks", usersHandler.JWKS)
 		r.Get("/sessions/{key}", usersHandler.Session)
 	})
+	r.Route("/admin/users/{id}", func(r chi.Router) {
+		r.Use(middlewares.Auth(sessionsService))
+		read := middlewares.RequirePermission(domain.PermissionUsersRead)
+		write := middlewares.RequirePermission(domain.PermissionUsersWrite)
 
-	addr := fmt.Sprintf("%s:%d", cfg.ServerConfig.Host, cfg.ServerConfig.Port)
-
-	ctx := context.Background()
-	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		r.With(read).Get("/", usersHandler.GetUser)
+		r.With(write).Patch("/", usersHandler.UpdateUser)
+		r.With(write).Delete("/", usersHandler.DeleteUser)
+		r.With(write).Post("/disable", usersHandler.DisableUser)
+		r.With(write).Post("/enable", usersHandler.EnableUser)
+		r.With(read).Get("/sessions", usersHandler.UserSessions)
+		r.With(write).Delete("/sessions", usersHandler.RevokeUserSessions)
+		r.With(write).Delete("/sessions/{sessionID}", usersHandler.RevokeUserSession)
+		r.With(write).Delete("/2fa", usersHandler.ResetUserTwoFactor)
+	})
+	r.Route("/admin/audit", func(r chi.Router) {
+		r.Use(middlewares.Auth(sessionsService), middlewares.RequirePermission(domain.PermissionAuditRead))
+		r.Get("/", auditHandler.List)
+		r.Get("/export", auditHandler.Export)
+	})
 
-	srv := http.Server{
+	addr := fmt.Sprintf("%s:%d", cfg.ServerConfig.Host, cfg.ServerConfig.Port)
+	srv := &http.Server{
 		Addr:    addr,
 		Handler: r,
 	}
-	log.Println("starting server...")
-	go func() {
-		err = srv.ListenAndServe()
-		if errors.Is(err, http.ErrServerClosed) {
-			log.Println("server stopped")
-			return
-		}
 
-		log.Printf("unexpected server error: %s", err)
-	}()
-	log.Printf("server started on: %s", addr)
+	// компоненты останавливаются в обратном порядке: сервер, воркеры, уведомления, хранилище
+	manager := lifecycle.NewManager(cfg.ServerConfig.ShutdownTimeout)
+	manager.AddCloser("storage", closeStorage)
+	manager.AddCloser("notifications", closeNotifier)
+
+	sessionReaper := workers.NewSessionReaper(cfg.Sessions.ReapInterval, store, cfg.Sessions.IdleTimeout)
+	manager.AddWorker("session reaper", sessionReaper.Run, workers.ErrStopped)
+	rateLimitReaper := workers.NewRateLimitReaper(cfg.Sessions.ReapInterval, store)
+	manager.AddWorker("rate limit reaper", rateLimitReaper.Run, workers.ErrStopped)
+
+	if cfg.PassCheck.Enabled {
+		retry := workers.RetryPolicy{
+			MaxAttempts: cfg.PassCheck.MaxAttempts,
+			BaseDelay:   cfg.PassCheck.BaseDelay,
+			MaxDelay:    cfg.PassCheck.MaxDelay,
+		}
+		passCheckWorker := workers.NewPassCheckWorker(cfg.PassCheck.Interval, store, notifier, cfg.PassCheck.WorkersCount, retry,
+			auditRecorder)
+		manager.AddWorker("password check", passCheckWorker.Run, workers.ErrStopped)
+	}
 
-	<-ctx.Done()
-	stop()
+	manager.AddServer("http server", srv)
 
-	log.Println("stopping database...")
-	dbCon.Close()
-	log.Println("database stopped")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	tCtx, tCancel := context.WithTimeout(ctx, time.Second*30)
-	defer tCancel()
-	err = srv.Shutdown(tCtx)
+	slog.Info("starting server", slog.String("addr", addr))
+	err = manager.Run(ctx)
 	if err != nil {
-		log.Printf("server shutdown error: %s", err)
+		slog.Error("server stopped with errors", slog.Any("error", err))
+		os.Exit(1)
 	}
+	slog.Info("server stopped")
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"movies-auth/users/internal/storage/migrations"
+	"strconv"
+)
+
+var errMigrateUsage = errors.New("usage: server migrate up | down [steps] | status")
+
+// runMigrate выполняет подкоманду migrate: up, down [steps] или status.
+func runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("migrations applied: %d\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errMigrateUsage
+			}
+			steps = n
+		}
+
+		reverted, err := migrations.Down(ctx, db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("migrations reverted: %d\n", reverted)
+	case "status":
+		statuses, err := migrations.GetStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Printf("%04d_%s\tapplied at %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		return errMigrateUsage
+	}
+
+	return nil
+}
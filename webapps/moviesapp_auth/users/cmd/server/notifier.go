@@ -0,0 +1,32 @@
+package main
+
+import (
+	"movies-auth/users/internal/config"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/notifications"
+)
+
+// openNotifier создает отправителя уведомлений из каналов, заданных в конфиге.
+// Возвращаемая функция освобождает ресурсы каналов.
+func openNotifier(cfg config.Notifications) (*notifications.Dispatcher, func() error, error) {
+	notifiers := make(map[string]notifications.Notifier)
+	closeNotifiers := func() error { return nil }
+
+	if cfg.SMTP.Host != "" {
+		notifiers[domain.NotificationChannelEmail] = notifications.NewSMTPNotifier(cfg.SMTP.Host, cfg.SMTP.Port,
+			cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From)
+	}
+	if cfg.Webhook.URL != "" {
+		notifiers[domain.NotificationChannelWebhook] = notifications.NewWebhookNotifier(cfg.Webhook.URL, cfg.Webhook.Timeout)
+	}
+	if cfg.LogFile.Path != "" {
+		logFile, err := notifications.NewLogFileNotifier(cfg.LogFile.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		notifiers[domain.NotificationChannelLogFile] = logFile
+		closeNotifiers = logFile.Close
+	}
+
+	return notifications.NewDispatcher(cfg.DefaultChannel, notifiers), closeNotifiers, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"movies-auth/users/internal/services"
+)
+
+var errRolesUsage = errors.New("usage: server roles <login> <role> [role...]")
+
+// runRoles выполняет подкоманду roles: заменяет роли пользователя, например чтобы назначить первого администратора.
+func runRoles(ctx context.Context, usersService *services.UsersService, args []string) error {
+	if len(args) < 2 {
+		return errRolesUsage
+	}
+
+	err := usersService.SetRoles(ctx, args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("roles of %s: %v\n", args[0], args[1:])
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"movies-auth/users/internal/audit"
+	"movies-auth/users/internal/config"
+	"movies-auth/users/internal/ratelimit"
+	"movies-auth/users/internal/services"
+	"movies-auth/users/internal/storage/db"
+	"movies-auth/users/internal/storage/inmemory"
+	"movies-auth/users/internal/storage/migrations"
+	"movies-auth/users/internal/workers"
+)
+
+const (
+	storagePostgres = "postgres"
+	storageInMemory = "inmemory"
+)
+
+// storage - хранилище, которое нужно сервисам и воркерам сервера.
+type storage interface {
+	services.UsersStorage
+	services.SessionsStorage
+	workers.PassCheckStore
+	workers.SessionsStore
+	workers.RateLimitsStore
+	ratelimit.Store
+	audit.Store
+	services.AuditStorage
+}
+
+// openStorage создает хранилище выбранного в конфиге типа.
+// Возвращаемая функция освобождает его ресурсы.
+func openStorage(cfg config.Config) (storage, func() error, error) {
+	switch cfg.Storage.Type {
+	case storageInMemory:
+		slog.Warn("using in-memory storage, data will be lost on restart")
+		return inmemory.NewStorage(), func() error { return nil }, nil
+	case storagePostgres, "":
+		dbCon, err := openDB(cfg.DBConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if cfg.DBConfig.AutoMigrate {
+			applied, err := migrations.Up(context.Background(), dbCon)
+			if err != nil {
+				dbCon.Close()
+				return nil, nil, fmt.Errorf("failed to apply migrations: %w", err)
+			}
+			slog.Info("migrations applied", slog.Int("count", applied))
+		}
+
+		return db.NewDbStorage(dbCon), dbCon.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage type: %s", cfg.Storage.Type)
+	}
+}
+
+func openDB(cfg config.DBConfig) (*sql.DB, error) {
+	dbCon, err := sql.Open("pgx", cfg.ConnectionString())
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbCon.Ping()
+	if err != nil {
+		dbCon.Close()
+		return nil, fmt.Errorf("failed to connect to db %w", err)
+	}
+
+	return dbCon, nil
+}
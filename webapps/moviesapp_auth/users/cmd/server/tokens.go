@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"movies-auth/users/internal/config"
+	"movies-auth/users/internal/tokens"
+)
+
+const (
+	sessionModeCookie = "cookie"
+	sessionModeToken  = "token"
+)
+
+// openTokens создает менеджер access токенов из ключей конфига.
+func openTokens(cfg config.Tokens) (*tokens.Manager, error) {
+	keys := make([]tokens.Key, 0, len(cfg.Keys))
+	for _, keyCfg := range cfg.Keys {
+		secret, err := base64.StdEncoding.DecodeString(keyCfg.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid base64 secret: %w", keyCfg.ID, err)
+		}
+
+		var key tokens.Key
+		switch keyCfg.Algorithm {
+		case tokens.AlgHS256:
+			key, err = tokens.NewHS256Key(keyCfg.ID, secret)
+		case tokens.AlgEdDSA:
+			key, err = tokens.NewEdDSAKey(keyCfg.ID, secret)
+		default:
+			err = fmt.Errorf("key %q: unknown algorithm %q", keyCfg.ID, keyCfg.Algorithm)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return tokens.NewManager(cfg.Issuer, cfg.SigningKey, keys...)
+}
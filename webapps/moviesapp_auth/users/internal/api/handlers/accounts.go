@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"movies-auth/users/internal/api/middlewares"
+	"net/http"
+)
+
+type accountTokenRequest struct {
+	Login string `json:"login"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (req resetPasswordRequest) validate() error {
+	return validate(
+		field{name: "token", value: req.Token, rules: []rule{required()}},
+		field{name: "newPassword", value: req.NewPassword, rules: []rule{required(), length(0, maxPasswordLength)}},
+	)
+}
+
+// RequestEmailVerification повторно отправляет письмо для подтверждения email.
+// Ответ одинаков для любых логинов.
+func (h UsersHandler) RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var req accountTokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	err := h.UsersService.RequestEmailVerification(r.Context(), req.Login)
+	writeAccountTokenRequested(w, r, err)
+}
+
+func (h UsersHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	err := h.UsersService.VerifyEmail(r.Context(), req.Token)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordReset отправляет письмо со сбросом пароля. Ответ одинаков для любых логинов.
+func (h UsersHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req accountTokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	err := h.UsersService.RequestPasswordReset(r.Context(), req.Login)
+	writeAccountTokenRequested(w, r, err)
+}
+
+// ResetPassword устанавливает новый пароль по токену из письма и завершает все сессии пользователя.
+func (h UsersHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	err := req.validate()
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	user, err := h.UsersService.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		middlewares.WriteError(w, r, newPasswordError(err))
+		return
+	}
+
+	// сброс пароля мог понадобиться из-за утечки, поэтому все устройства входят заново
+	_, err = h.SessionsService.RevokeUserSessions(r.Context(), user.ID)
+	if err != nil {
+		logError(r, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAccountTokenRequested(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
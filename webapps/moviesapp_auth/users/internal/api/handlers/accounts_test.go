@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"movies-auth/users/internal/domain"
+	mock_api "movies-auth/users/internal/tests/api_mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAccountTokens(t *testing.T) {
+	testCases := []struct {
+		name            string
+		target          string
+		body            string
+		mockUsersInit   func(u *mock_api.MockUsersService)
+		mockSessionInit func(s *mock_api.MockSessionService)
+		wantStatusCode  int
+	}{
+		{
+			name:   "success_reset_requested",
+			target: "/users/password/reset/request",
+			body:   `{"login":"unknown"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().RequestPasswordReset(gomock.Any(), "unknown").Return(nil)
+			},
+			wantStatusCode: http.StatusAccepted,
+		},
+		{
+			name:   "fail_reset_request_throttled",
+			target: "/users/password/reset/request",
+			body:   `{"login":"user1"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().RequestPasswordReset(gomock.Any(), "user1").
+					Return(&domain.RetryError{Err: domain.ErrTooManyRequests, RetryAfter: time.Minute})
+			},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:   "fail_verify_invalid_token",
+			target: "/users/email/verify",
+			body:   `{"token":"token"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().VerifyEmail(gomock.Any(), "token").Return(domain.ErrInvalidToken)
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "fail_reset_weak_password",
+			target: "/users/password/reset",
+			body:   `{"token":"token","newPassword":"short"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().ResetPassword(gomock.Any(), "token", "short").Return(domain.User{}, domain.ErrWeakPassword)
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "success_reset_revokes_sessions",
+			target: "/users/password/reset",
+			body:   `{"token":"token","newPassword":"password2"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().ResetPassword(gomock.Any(), "token", "password2").Return(domain.User{ID: 2}, nil)
+			},
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().RevokeUserSessions(gomock.Any(), 2).Return(0, errors.New("unexpected error"))
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			us := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUsersInit != nil {
+				tc.mockUsersInit(us)
+			}
+			ss := mock_api.NewMockSessionService(ctrl)
+			if tc.mockSessionInit != nil {
+				tc.mockSessionInit(ss)
+			}
+
+			h := NewUsersHandler(us, ss)
+			r := chi.NewRouter()
+			r.Post("/users/email/verify", h.VerifyEmail)
+			r.Post("/users/password/reset/request", h.RequestPasswordReset)
+			r.Post("/users/password/reset", h.ResetPassword)
+
+			req := httptest.NewRequest(http.MethodPost, tc.target, bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			r.ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"time"
+)
+
+// adminUserResponse - пользователь в ответах API администратора.
+type adminUserResponse struct {
+	userResponse
+	Roles         []string   `json:"roles"`
+	Disabled      bool       `json:"disabled"`
+	EmailVerified bool       `json:"emailVerified"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
+
+func newAdminUserResponse(user domain.User) adminUserResponse {
+	resp := adminUserResponse{
+		userResponse:  newUserResponse(user),
+		Roles:         user.Roles,
+		Disabled:      !user.DisabledAt.IsZero(),
+		EmailVerified: !user.EmailVerifiedAt.IsZero(),
+	}
+	if user.LockedUntil.After(time.Now()) {
+		resp.LockedUntil = &user.LockedUntil
+	}
+
+	return resp
+}
+
+type updateUserRequest struct {
+	Email               *string  `json:"email"`
+	NotificationChannel *string  `json:"notificationChannel"`
+	Roles               []string `json:"roles"`
+}
+
+// sessionResponse - сессия пользователя без ключа: ключ дает доступ к сессии и в ответах не показывается.
+type sessionResponse struct {
+	ID         int       `json:"id"`
+	StartedAt  time.Time `json:"startedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+}
+
+func newSessionResponse(session domain.Session) sessionResponse {
+	return sessionResponse{
+		ID:         session.ID,
+		StartedAt:  session.StartedAt,
+		ExpiresAt:  session.ExpiresAt,
+		LastSeenAt: session.LastSeenAt,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+	}
+}
+
+func (h UsersHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	user, err := h.UsersService.Get(r.Context(), id)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, newAdminUserResponse(user))
+}
+
+func (h UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req updateUserRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.UsersService.Update(r.Context(), id, domain.UserUpdate{
+		Email:               req.Email,
+		NotificationChannel: req.NotificationChannel,
+		Roles:               req.Roles,
+	})
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, newAdminUserResponse(user))
+}
+
+func (h UsersHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+func (h UsersHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h UsersHandler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	user, err := h.UsersService.SetDisabled(r.Context(), id, disabled)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	if disabled {
+		// сессии отключенного пользователя и так не проходят проверку, ошибка их удаления не критична
+		_, err = h.SessionsService.RevokeUserSessions(r.Context(), id)
+		if err != nil {
+			logError(r, err)
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, newAdminUserResponse(user))
+}
+
+func (h UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	err := h.UsersService.Delete(r.Context(), id)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	_, err = h.SessionsService.RevokeUserSessions(r.Context(), id)
+	if err != nil {
+		logError(r, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h UsersHandler) UserSessions(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	_, err := h.UsersService.Get(r.Context(), id)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	sessions, err := h.SessionsService.UserSessions(r.Context(), id)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, newSessionResponse(s))
+	}
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+func (h UsersHandler) RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+	sessionID, ok := intParam(w, r, "sessionID")
+	if !ok {
+		return
+	}
+
+	err := h.SessionsService.RevokeUserSession(r.Context(), id, sessionID)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h UsersHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	_, err := h.SessionsService.RevokeUserSessions(r.Context(), id)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"movies-auth/users/internal/domain"
+	mock_api "movies-auth/users/internal/tests/api_mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/mock/gomock"
+)
+
+func newAdminRouter(h UsersHandler) chi.Router {
+	r := chi.NewRouter()
+	r.Patch("/admin/users/{id}", h.UpdateUser)
+	r.Post("/admin/users/{id}/disable", h.DisableUser)
+	r.Delete("/admin/users/{id}", h.DeleteUser)
+	r.Delete("/admin/users/{id}/sessions/{sessionID}", h.RevokeUserSession)
+
+	return r
+}
+
+func TestAdminUsers(t *testing.T) {
+	testCases := []struct {
+		name            string
+		method          string
+		target          string
+		body            string
+		mockUsersInit   func(u *mock_api.MockUsersService)
+		mockSessionInit func(s *mock_api.MockSessionService)
+		wantStatusCode  int
+	}{
+		{
+			name:           "fail_invalid_id",
+			method:         http.MethodPost,
+			target:         "/admin/users/abc/disable",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "fail_disable_not_found",
+			method: http.MethodPost,
+			target: "/admin/users/2/disable",
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().SetDisabled(gomock.Any(), 2, true).Return(domain.User{}, domain.ErrNotFound)
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:   "success_disable_revokes_sessions",
+			method: http.MethodPost,
+			target: "/admin/users/2/disable",
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().SetDisabled(gomock.Any(), 2, true).Return(domain.User{ID: 2, DisabledAt: time.Now()}, nil)
+			},
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().RevokeUserSessions(gomock.Any(), 2).Return(3, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "fail_update_invalid_params",
+			method: http.MethodPatch,
+			target: "/admin/users/2",
+			body:   `{"roles":[]}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().Update(gomock.Any(), 2, domain.UserUpdate{Roles: []string{}}).Return(domain.User{}, domain.ErrInvalidParams)
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "success_delete",
+			method: http.MethodDelete,
+			target: "/admin/users/2",
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().Delete(gomock.Any(), 2).Return(nil)
+			},
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().RevokeUserSessions(gomock.Any(), 2).Return(0, errors.New("unexpected error"))
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:   "fail_revoke_other_user_session",
+			method: http.MethodDelete,
+			target: "/admin/users/2/sessions/7",
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().RevokeUserSession(gomock.Any(), 2, 7).Return(domain.ErrNotFound)
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			us := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUsersInit != nil {
+				tc.mockUsersInit(us)
+			}
+			ss := mock_api.NewMockSessionService(ctrl)
+			if tc.mockSessionInit != nil {
+				tc.mockSessionInit(ss)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.target, bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			newAdminRouter(NewUsersHandler(us, ss)).ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+			if tc.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			var resp adminUserResponse
+			err := json.NewDecoder(recorder.Body).Decode(&resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.ID != 2 || !resp.Disabled {
+				t.Errorf("unexpected user response: %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuditService interface {
+	List(ctx context.Context, filter domain.AuditFilter, cursor string) (domain.AuditPage, error)
+	Export(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error
+}
+
+type AuditHandler struct {
+	AuditService AuditService
+}
+
+func NewAuditHandler(auditService AuditService) AuditHandler {
+	return AuditHandler{AuditService: auditService}
+}
+
+//go:generate mockgen -source audit.go -destination ../../tests/api_mocks/audit.go package apimocks
+
+type auditEventResponse struct {
+	ID        int64     `json:"id"`
+	ActorID   int       `json:"actorId,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type auditListResponse struct {
+	Events     []auditEventResponse `json:"events"`
+	NextCursor string               `json:"nextCursor,omitempty"`
+}
+
+// List возвращает страницу журнала аудита от старых записей к новым.
+// Фильтры: from и to в RFC 3339 (to не включается), actorId, actor (логин), action.
+func (h AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := limitParam(w, r)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+
+	page, err := h.AuditService.List(r.Context(), filter, r.URL.Query().Get("cursor"))
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	resp := auditListResponse{
+		Events:     make([]auditEventResponse, 0, len(page.Events)),
+		NextCursor: page.NextCursor,
+	}
+	for _, e := range page.Events {
+		resp.Events = append(resp.Events, auditEventResponse(e))
+	}
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// Export выгружает весь журнал с теми же фильтрами, что и List, в формате JSON Lines: одна запись в строке.
+func (h AuditHandler) Export(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	written := false
+	err := h.AuditService.Export(r.Context(), filter, func(e domain.AuditEvent) error {
+		if !written {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+			written = true
+		}
+		return enc.Encode(auditEventResponse(e))
+	})
+	if err != nil {
+		if written {
+			// заголовок ответа уже отправлен, клиент увидит оборванную выгрузку
+			logError(r, err)
+			return
+		}
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	if !written {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// parseAuditFilter читает фильтры журнала из параметров запроса, при ошибке отвечает 400.
+func parseAuditFilter(w http.ResponseWriter, r *http.Request) (domain.AuditFilter, bool) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			middlewares.WriteError(w, r, rfc3339Error("from"))
+			return domain.AuditFilter{}, false
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			middlewares.WriteError(w, r, rfc3339Error("to"))
+			return domain.AuditFilter{}, false
+		}
+	}
+
+	if actorID := query.Get("actorId"); actorID != "" {
+		id, err := strconv.Atoi(actorID)
+		if err != nil || id <= 0 {
+			middlewares.WriteError(w, r, positiveIntError("actorId"))
+			return domain.AuditFilter{}, false
+		}
+		filter.ActorID = id
+	}
+
+	return filter, true
+}
+
+func rfc3339Error(field string) error {
+	return domain.NewValidationError(domain.FieldError{Field: field, Code: "invalid", Message: "must be a RFC 3339 time"})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"movies-auth/users/internal/domain"
+	mock_api "movies-auth/users/internal/tests/api_mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAuditList(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		target         string
+		mockInit       func(a *mock_api.MockAuditService)
+		wantStatusCode int
+	}{
+		{
+			name:   "success_filters",
+			target: "/admin/audit?from=2024-01-01T00:00:00Z&actorId=3&action=login&limit=20&cursor=10",
+			mockInit: func(a *mock_api.MockAuditService) {
+				filter := domain.AuditFilter{From: from, ActorID: 3, Action: domain.AuditLogin, Limit: 20}
+				a.EXPECT().List(gomock.Any(), filter, "10").Return(domain.AuditPage{
+					Events: []domain.AuditEvent{{ID: 11, ActorID: 3, Action: domain.AuditLogin, Outcome: domain.AuditSuccess}},
+				}, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "fail_invalid_from",
+			target:         "/admin/audit?from=yesterday",
+			mockInit:       func(a *mock_api.MockAuditService) {},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "fail_invalid_actor_id",
+			target:         "/admin/audit?actorId=admin",
+			mockInit:       func(a *mock_api.MockAuditService) {},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "fail_invalid_range",
+			target: "/admin/audit?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z",
+			mockInit: func(a *mock_api.MockAuditService) {
+				a.EXPECT().List(gomock.Any(), gomock.Any(), "").Return(domain.AuditPage{}, domain.ErrInvalidParams)
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			as := mock_api.NewMockAuditService(ctrl)
+			tc.mockInit(as)
+
+			h := NewAuditHandler(as)
+			r := chi.NewRouter()
+			r.Get("/admin/audit", h.List)
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			recorder := httptest.NewRecorder()
+
+			r.ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestAuditExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	as := mock_api.NewMockAuditService(ctrl)
+	as.EXPECT().Export(gomock.Any(), domain.AuditFilter{Actor: "user1"}, gomock.Any()).
+		DoAndReturn(func(_ any, _ domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+			for id := int64(1); id <= 3; id++ {
+				err := fn(domain.AuditEvent{ID: id, Actor: "user1", Action: domain.AuditLogin, Outcome: domain.AuditSuccess})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	h := NewAuditHandler(as)
+	r := chi.NewRouter()
+	r.Get("/admin/audit/export", h.Export)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit/export?actor=user1", nil)
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected status code: %d, got: %d", http.StatusOK, recorder.Result().StatusCode)
+	}
+	if ct := recorder.Result().Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected json lines content type, got: %s", ct)
+	}
+
+	scanner := bufio.NewScanner(recorder.Body)
+	var lines int
+	for scanner.Scan() {
+		var event auditEventResponse
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			t.Fatalf("expected json object per line, got: %s", scanner.Text())
+		}
+		lines++
+		if event.ID != int64(lines) || event.Actor != "user1" {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	}
+	if lines != 3 {
+		t.Errorf("expected 3 lines, got: %d", lines)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	"net/http"
+)
+
+// mySessionResponse - сессия текущего пользователя. Current отмечает сессию, с которой пришел запрос.
+type mySessionResponse struct {
+	sessionResponse
+	Current bool `json:"current"`
+}
+
+func (h UsersHandler) MySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+	currentID, _ := middlewares.GetSessionID(r.Context())
+
+	sessions, err := h.SessionsService.UserSessions(r.Context(), userID)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	resp := make([]mySessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, mySessionResponse{sessionResponse: newSessionResponse(s), Current: s.ID == currentID})
+	}
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// RevokeMySession завершает одну из сессий текущего пользователя.
+func (h UsersHandler) RevokeMySession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+	sessionID, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	err := h.SessionsService.RevokeUserSession(r.Context(), userID, sessionID)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeMySessions завершает все сессии текущего пользователя, включая текущую ("выйти везде").
+func (h UsersHandler) RevokeMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+
+	_, err := h.SessionsService.RevokeUserSessions(r.Context(), userID)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, middlewares.ExpiredSessionCookie())
+	http.SetCookie(w, middlewares.ExpiredRefreshTokenCookie())
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	mock_api "movies-auth/users/internal/tests/api_mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// authStub принимает любую сессию как сессию 2 пользователя 1.
+type authStub struct{}
+
+func (authStub) ValidateSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	return domain.Session{ID: 2, Key: key, UserID: 1}, nil
+}
+
+func (authStub) VerifyAccessToken(ctx context.Context, token string) (domain.Session, error) {
+	return domain.Session{}, domain.ErrInvalidToken
+}
+
+func (authStub) CookieExpires(session domain.Session) time.Time {
+	return time.Now().Add(time.Hour)
+}
+
+func (authStub) TokensEnabled() bool {
+	return false
+}
+
+// tokenAuthStub принимает любой access токен как токен сессии 3 пользователя 1.
+type tokenAuthStub struct {
+	authStub
+}
+
+func (tokenAuthStub) VerifyAccessToken(ctx context.Context, token string) (domain.Session, error) {
+	return domain.Session{ID: 3, UserID: 1}, nil
+}
+
+func (tokenAuthStub) TokensEnabled() bool {
+	return true
+}
+
+func TestMySessions(t *testing.T) {
+	currentKey := uuid.New()
+
+	testCases := []struct {
+		name            string
+		method          string
+		target          string
+		mockSessionInit func(s *mock_api.MockSessionService)
+		wantStatusCode  int
+		wantCookies     int
+	}{
+		{
+			name:   "success_list",
+			method: http.MethodGet,
+			target: "/users/me/sessions",
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().UserSessions(gomock.Any(), 1).Return([]domain.Session{
+					{ID: 1, Key: uuid.New(), UserID: 1, UserAgent: "curl"},
+					{ID: 2, Key: uuid.New(), UserID: 1, UserAgent: "Mozilla/5.0"},
+				}, nil)
+			},
+			wantStatusCode: http.StatusOK,
+			wantCookies:    1,
+		},
+		{
+			name:   "fail_revoke_foreign_session",
+			method: http.MethodDelete,
+			target: "/users/me/sessions/5",
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().RevokeUserSession(gomock.Any(), 1, 5).Return(domain.ErrNotFound)
+			},
+			wantStatusCode: http.StatusNotFound,
+			wantCookies:    1,
+		},
+		{
+			name:   "success_revoke_all",
+			method: http.MethodDelete,
+			target: "/users/me/sessions",
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().RevokeUserSessions(gomock.Any(), 1).Return(2, nil)
+			},
+			wantStatusCode: http.StatusNoContent,
+			// продленная cookie сессии, затем истекшие cookie сессии и refresh токена
+			wantCookies: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ss := mock_api.NewMockSessionService(ctrl)
+			tc.mockSessionInit(ss)
+
+			h := NewUsersHandler(mock_api.NewMockUsersService(ctrl), ss)
+			r := chi.NewRouter()
+			r.Use(middlewares.Auth(authStub{}))
+			r.Get("/users/me/sessions", h.MySessions)
+			r.Delete("/users/me/sessions", h.RevokeMySessions)
+			r.Delete("/users/me/sessions/{id}", h.RevokeMySession)
+
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			req.AddCookie(middlewares.SessionCookie(currentKey, time.Now().Add(time.Hour)))
+			recorder := httptest.NewRecorder()
+
+			r.ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+			if len(recorder.Result().Cookies()) != tc.wantCookies {
+				t.Errorf("expected cookies: %d, got: %d", tc.wantCookies, len(recorder.Result().Cookies()))
+			}
+			if tc.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			var resp []mySessionResponse
+			err := json.NewDecoder(recorder.Body).Decode(&resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp) != 2 || resp[0].Current || !resp[1].Current || resp[1].UserAgent != "Mozilla/5.0" {
+				t.Errorf("unexpected sessions response: %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// intParam читает положительный числовой параметр пути, при ошибке отвечает 400.
+func intParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	value, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil || value <= 0 {
+		middlewares.WriteError(w, r, positiveIntError(name))
+		return 0, false
+	}
+
+	return value, true
+}
+
+// limitParam читает необязательный размер страницы из параметра запроса limit, при ошибке отвечает 400.
+func limitParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	param := r.URL.Query().Get("limit")
+	if param == "" {
+		return 0, true
+	}
+
+	limit, err := strconv.Atoi(param)
+	if err != nil || limit <= 0 {
+		middlewares.WriteError(w, r, positiveIntError("limit"))
+		return 0, false
+	}
+
+	return limit, true
+}
+
+func positiveIntError(field string) error {
+	return domain.NewValidationError(domain.FieldError{Field: field, Code: "invalid", Message: "must be a positive integer"})
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	respBytes, err := json.Marshal(v)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(respBytes)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"time"
+)
+
+// challengeResponse - ответ на вход пользователя со вторым фактором. Сессия откроется
+// после POST /users/login/2fa с этим токеном и кодом.
+type challengeResponse struct {
+	ChallengeToken string    `json:"challengeToken"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+type loginSecondFactorRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// twoFactorBeginRequest - текущий пароль, без него второй фактор не подключить одной украденной сессией.
+type twoFactorBeginRequest struct {
+	Password string `json:"password"`
+}
+
+func (req twoFactorBeginRequest) validate() error {
+	return validate(
+		field{name: "password", value: req.Password, rules: []rule{required(), length(0, maxPasswordLength)}},
+	)
+}
+
+type twoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+func (req twoFactorDisableRequest) validate() error {
+	return validate(
+		field{name: "password", value: req.Password, rules: []rule{required(), length(0, maxPasswordLength)}},
+		field{name: "code", value: req.Code, rules: []rule{required()}},
+	)
+}
+
+type twoFactorStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	Pending           bool `json:"pending"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+type twoFactorEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// LoginSecondFactor обменивает токен из ответа Login и код второго фактора на сессию.
+func (h UsersHandler) LoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var req loginSecondFactorRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.UsersService.CompleteLogin(r.Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCode) {
+			// неверный код на входе - неудачная аутентификация, а не ошибка запроса
+			middlewares.WriteErrorStatus(w, r, http.StatusUnauthorized, err)
+			return
+		}
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	h.openSession(w, r, user)
+}
+
+func (h UsersHandler) TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+
+	status, err := h.UsersService.TwoFactorStatus(r.Context(), userID)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, twoFactorStatusResponse(status))
+}
+
+// BeginTwoFactor создает новый секрет для приложения-аутентификатора после проверки текущего пароля.
+// Второй фактор включается только после подтверждения кодом в ConfirmTwoFactor.
+func (h UsersHandler) BeginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+	var req twoFactorBeginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	err := req.validate()
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	enrollment, err := h.UsersService.BeginTwoFactor(r.Context(), userID, req.Password)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, twoFactorEnrollmentResponse(enrollment))
+}
+
+// TwoFactorQRCode отдает QR-код начатого подключения в PNG.
+func (h UsersHandler) TwoFactorQRCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+
+	png, err := h.UsersService.TwoFactorQRCode(r.Context(), userID)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	// в QR-коде секрет, его не должны сохранять промежуточные кэши
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+// ConfirmTwoFactor включает второй фактор и единственный раз возвращает коды восстановления.
+func (h UsersHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+	var req twoFactorCodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	codes, err := h.UsersService.ConfirmTwoFactor(r.Context(), userID, req.Code)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor выключает второй фактор после проверки текущего пароля и кода.
+func (h UsersHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.GetUserID(r.Context())
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
+		return
+	}
+	var req twoFactorDisableRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	err := req.validate()
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	err = h.UsersService.DisableTwoFactor(r.Context(), userID, req.Password, req.Code)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetUserTwoFactor выключает второй фактор пользователя, потерявшего устройство и коды восстановления.
+func (h UsersHandler) ResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	err := h.UsersService.ResetTwoFactor(r.Context(), id)
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeChallenge отвечает 202 с токеном второго шага входа, если err - *domain.ChallengeError.
+func writeChallenge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var challenge *domain.ChallengeError
+	if !errors.As(err, &challenge) {
+		return false
+	}
+
+	writeJSON(w, r, http.StatusAccepted, challengeResponse{ChallengeToken: challenge.Token, ExpiresAt: challenge.ExpiresAt})
+	return true
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	mock_api "movies-auth/users/internal/tests/api_mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTwoFactor(t *testing.T) {
+	testCases := []struct {
+		name            string
+		method          string
+		target          string
+		body            string
+		mockUsersInit   func(u *mock_api.MockUsersService)
+		mockSessionInit func(s *mock_api.MockSessionService)
+		wantStatusCode  int
+		wantChallenge   bool
+	}{
+		{
+			name:   "success_login_challenge",
+			method: http.MethodPost,
+			target: "/users/login",
+			body:   `{"login":"user1","password":"password1"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().Login(gomock.Any(), gomock.Any()).
+					Return(domain.User{}, &domain.ChallengeError{Token: "challenge", ExpiresAt: time.Now().Add(time.Minute)})
+			},
+			wantStatusCode: http.StatusAccepted,
+			wantChallenge:  true,
+		},
+		{
+			name:   "success_login_second_factor",
+			method: http.MethodPost,
+			target: "/users/login/2fa",
+			body:   `{"challengeToken":"challenge","code":"123456"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().CompleteLogin(gomock.Any(), "challenge", "123456").Return(domain.User{ID: 1}, nil)
+			},
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().CreateSession(gomock.Any(), 1, gomock.Any()).Return(domain.Session{}, nil)
+				s.EXPECT().CookieExpires(gomock.Any()).Return(time.Now().Add(time.Minute))
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "fail_login_second_factor_invalid_code",
+			method: http.MethodPost,
+			target: "/users/login/2fa",
+			body:   `{"challengeToken":"challenge","code":"000000"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().CompleteLogin(gomock.Any(), "challenge", "000000").Return(domain.User{}, domain.ErrInvalidCode)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "fail_login_second_factor_expired_challenge",
+			method: http.MethodPost,
+			target: "/users/login/2fa",
+			body:   `{"challengeToken":"challenge","code":"123456"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().CompleteLogin(gomock.Any(), "challenge", "123456").Return(domain.User{}, domain.ErrInvalidToken)
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "success_begin",
+			method: http.MethodPost,
+			target: "/users/me/2fa",
+			body:   `{"password":"password1"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().BeginTwoFactor(gomock.Any(), 1, "password1").Return(domain.TOTPEnrollment{Secret: "secret"}, nil)
+			},
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "fail_begin_password_missing",
+			method:         http.MethodPost,
+			target:         "/users/me/2fa",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "fail_begin_invalid_password",
+			method: http.MethodPost,
+			target: "/users/me/2fa",
+			body:   `{"password":"wrong"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().BeginTwoFactor(gomock.Any(), 1, "wrong").Return(domain.TOTPEnrollment{}, domain.ErrInvalidPassword)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "fail_begin_already_enabled",
+			method: http.MethodPost,
+			target: "/users/me/2fa",
+			body:   `{"password":"password1"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().BeginTwoFactor(gomock.Any(), 1, "password1").Return(domain.TOTPEnrollment{}, domain.ErrConflict)
+			},
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:   "success_qr_code",
+			method: http.MethodGet,
+			target: "/users/me/2fa/qr",
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().TwoFactorQRCode(gomock.Any(), 1).Return([]byte("\x89PNG"), nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "fail_confirm_invalid_code",
+			method: http.MethodPost,
+			target: "/users/me/2fa/confirm",
+			body:   `{"code":"000000"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().ConfirmTwoFactor(gomock.Any(), 1, "000000").Return(nil, domain.ErrInvalidCode)
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "success_disable",
+			method: http.MethodPost,
+			target: "/users/me/2fa/disable",
+			body:   `{"password":"password1","code":"123456"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().DisableTwoFactor(gomock.Any(), 1, "password1", "123456").Return(nil)
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "fail_disable_password_missing",
+			method:         http.MethodPost,
+			target:         "/users/me/2fa/disable",
+			body:           `{"code":"123456"}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "fail_disable_not_enabled",
+			method: http.MethodPost,
+			target: "/users/me/2fa/disable",
+			body:   `{"password":"password1","code":"123456"}`,
+			mockUsersInit: func(u *mock_api.MockUsersService) {
+				u.EXPECT().DisableTwoFactor(gomock.Any(), 1, "password1", "123456").Return(domain.ErrNotFound)
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			us := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUsersInit != nil {
+				tc.mockUsersInit(us)
+			}
+			ss := mock_api.NewMockSessionService(ctrl)
+			if tc.mockSessionInit != nil {
+				tc.mockSessionInit(ss)
+			}
+
+			h := NewUsersHandler(us, ss)
+			r := chi.NewRouter()
+			r.Post("/users/login", h.Login)
+			r.Post("/users/login/2fa", h.LoginSecondFactor)
+			r.Group(func(r chi.Router) {
+				r.Use(middlewares.Auth(authStub{}))
+				r.Post("/users/me/2fa", h.BeginTwoFactor)
+				r.Get("/users/me/2fa/qr", h.TwoFactorQRCode)
+				r.Post("/users/me/2fa/confirm", h.ConfirmTwoFactor)
+				r.Post("/users/me/2fa/disable", h.DisableTwoFactor)
+			})
+
+			req := httptest.NewRequest(tc.method, tc.target, bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(middlewares.SessionCookie(uuid.New(), time.Now().Add(time.Hour)))
+			recorder := httptest.NewRecorder()
+
+			r.ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+			if !tc.wantChallenge {
+				return
+			}
+
+			var resp challengeResponse
+			err := json.NewDecoder(recorder.Body).Decode(&resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.ChallengeToken != "challenge" || resp.ExpiresAt.IsZero() {
+				t.Errorf("unexpected challenge response: %+v", resp)
+			}
+			if len(recorder.Result().Cookies()) != 0 {
+				t.Errorf("expected no session cookie before second factor")
+			}
+		})
+	}
+}
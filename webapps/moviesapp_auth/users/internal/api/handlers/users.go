@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"movies-auth/users/internal/api/middlewares"
 	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"movies-auth/users/internal/tokens"
 	"net/http"
 	"time"
 
@@ -17,12 +19,36 @@ import (
 type UsersService interface {
 	Login(ctx context.Context, user domain.User) (domain.User, error)
 	Create(ctx context.Context, user domain.User) (domain.User, error)
+	List(ctx context.Context, params domain.UsersListParams, cursor string) (domain.UsersPage, error)
+	ChangePassword(ctx context.Context, login string, password string, newPassword string, code string) (domain.User, error)
+	Get(ctx context.Context, id int) (domain.User, error)
+	Update(ctx context.Context, id int, update domain.UserUpdate) (domain.User, error)
+	SetDisabled(ctx context.Context, id int, disabled bool) (domain.User, error)
+	Delete(ctx context.Context, id int) error
+	RequiresEmailVerification(user domain.User) bool
+	RequestEmailVerification(ctx context.Context, login string) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, login string) error
+	ResetPassword(ctx context.Context, token string, newPassword string) (domain.User, error)
+	CompleteLogin(ctx context.Context, challenge string, code string) (domain.User, error)
+	TwoFactorStatus(ctx context.Context, userID int) (domain.TwoFactorStatus, error)
+	BeginTwoFactor(ctx context.Context, userID int, password string) (domain.TOTPEnrollment, error)
+	TwoFactorQRCode(ctx context.Context, userID int) ([]byte, error)
+	ConfirmTwoFactor(ctx context.Context, userID int, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID int, password string, code string) error
+	ResetTwoFactor(ctx context.Context, userID int) error
 }
 
 type SessionService interface {
-	DeleteSession(key uuid.UUID) error
-	CreateSession(userId int) (domain.Session, error)
-	ValidateSession(key uuid.UUID) (domain.Session, error)
+	DeleteSession(ctx context.Context, key uuid.UUID) error
+	CreateSession(ctx context.Context, userId int, device domain.Device) (domain.Session, error)
+	ValidateSession(ctx context.Context, key uuid.UUID) (domain.Session, error)
+	CookieExpires(session domain.Session) time.Time
+	Refresh(ctx context.Context, refreshToken string) (domain.Session, error)
+	JWKS() tokens.JWKS
+	UserSessions(ctx context.Context, userID int) ([]domain.Session, error)
+	RevokeUserSession(ctx context.Context, userID int, id int) error
+	RevokeUserSessions(ctx context.Context, userID int) (int, error)
 }
 
 type UsersHandler struct {
@@ -37,151 +63,371 @@ func NewUsersHandler(usersService UsersService, sessionsService SessionService)
 	}
 }
 
+type userResponse struct {
+	ID                  int       `json:"id"`
+	Login               string    `json:"login"`
+	PasswordExpires     time.Time `json:"passwordExpires"`
+	NotificationSent    bool      `json:"notificationSent"`
+	Email               string    `json:"email,omitempty"`
+	NotificationChannel string    `json:"notificationChannel,omitempty"`
+}
+
+type usersListResponse struct {
+	Users      []userResponse `json:"users"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+func newUserResponse(user domain.User) userResponse {
+	return userResponse{
+		ID:                  user.ID,
+		Login:               user.Login,
+		PasswordExpires:     user.PasswordExpires,
+		NotificationSent:    user.NotificationSent,
+		Email:               user.Email,
+		NotificationChannel: user.NotificationChannel,
+	}
+}
+
+func requestDevice(r *http.Request) domain.Device {
+	return domain.Device{UserAgent: r.UserAgent(), IP: middlewares.ClientIP(r)}
+}
+
+func logError(r *http.Request, err error) {
+	logging.FromContext(r.Context()).ErrorContext(r.Context(), "request failed", slog.Any("error", err))
+}
+
+// credentialsError не раскрывает клиенту, существует ли логин: неизвестный логин
+// получает тот же ответ, что и неверный пароль.
+func credentialsError(err error) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: %w", domain.ErrInvalidPassword, err)
+	}
+
+	return err
+}
+
+// newPasswordError относит ошибки политики паролей к полю newPassword запроса.
+func newPasswordError(err error) error {
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		for i := range validationErr.Fields {
+			if validationErr.Fields[i].Field == "password" {
+				validationErr.Fields[i].Field = "newPassword"
+			}
+		}
+	}
+
+	return err
+}
+
 //go:generate mockgen -source users.go -destination ../../tests/api_mocks/users.go package apimocks
 
+type registerRequest struct {
+	Login               string `json:"login"`
+	Password            string `json:"password"`
+	Email               string `json:"email"`
+	NotificationChannel string `json:"notificationChannel"`
+}
+
+// validate проверяет форму регистрации. Требования политики паролей проверяет UsersService.
+func (req registerRequest) validate() error {
+	return validate(
+		field{name: "login", value: req.Login, rules: []rule{required(), length(minLoginLength, maxLoginLength), loginCharset()}},
+		field{name: "password", value: req.Password, rules: []rule{required(), length(0, maxPasswordLength)}},
+		field{name: "email", value: req.Email, rules: []rule{length(0, maxEmailLength), email()}},
+	)
+}
+
+type loginRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// validate проверяет форму входа. Состав символов логина не проверяется: логин либо существует, либо нет.
+func (req loginRequest) validate() error {
+	return validate(
+		field{name: "login", value: req.Login, rules: []rule{required(), length(0, maxLoginLength)}},
+		field{name: "password", value: req.Password, rules: []rule{required(), length(0, maxPasswordLength)}},
+	)
+}
+
 func (h UsersHandler) Register(w http.ResponseWriter, r *http.Request) {
-	if r.Header.Get("Content-Type") != "application/json" {
-		http.Error(w, "not supported content type", http.StatusUnsupportedMediaType)
+	var req registerRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-
-	var user domain.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := req.validate()
 	if err != nil {
-		http.Error(w, "error", http.StatusBadRequest)
+		middlewares.WriteError(w, r, err)
 		return
 	}
 
-	createdUser, err := h.UsersService.Create(r.Context(), user)
+	createdUser, err := h.UsersService.Create(r.Context(), domain.User{
+		Login:               req.Login,
+		Password:            req.Password,
+		Email:               req.Email,
+		NotificationChannel: req.NotificationChannel,
+	})
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrConflict):
-			http.Error(w, "user already exist", http.StatusConflict)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
+		middlewares.WriteError(w, r, err)
+		return
+	}
 
-		log.Println(err)
+	if h.UsersService.RequiresEmailVerification(createdUser) {
+		// сессия откроется при входе после подтверждения email
+		writeJSON(w, r, http.StatusCreated, newUserResponse(createdUser))
 		return
 	}
 
-	userSession, err := h.SessionsService.CreateSession(createdUser.ID)
+	userSession, err := h.SessionsService.CreateSession(r.Context(), createdUser.ID, requestDevice(r))
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "unexpected error", http.StatusInternalServerError)
+		middlewares.WriteError(w, r, err)
 		return
 	}
 
-	sessionCookie := &http.Cookie{
-		Name:     "session",
-		HttpOnly: true,
-		Expires:  time.Now().Add(time.Minute * 5),
-		Value:    userSession.Key.String(),
+	h.setSessionCookies(w, userSession)
+	writeJSON(w, r, http.StatusCreated, newUserResponse(createdUser))
+}
+
+func (h UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	err := req.validate()
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
 	}
 
-	userBytes, err := json.Marshal(createdUser)
+	loggedUser, err := h.UsersService.Login(r.Context(), domain.User{Login: req.Login, Password: req.Password})
+	if writeChallenge(w, r, err) {
+		return
+	}
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "error", http.StatusInternalServerError)
+		middlewares.WriteError(w, r, credentialsError(err))
 		return
 	}
 
-	http.SetCookie(w, sessionCookie)
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	w.Write(userBytes)
+	h.openSession(w, r, loggedUser)
 }
 
-func (h UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
-	if r.Header.Get("Content-Type") != "application/json" {
-		http.Error(w, "not supported content type", http.StatusUnsupportedMediaType)
+// openSession открывает сессию вошедшего пользователя и в режиме токенов отдает токены в теле ответа.
+func (h UsersHandler) openSession(w http.ResponseWriter, r *http.Request, user domain.User) {
+	userSession, err := h.SessionsService.CreateSession(r.Context(), user.ID, requestDevice(r))
+	if err != nil {
+		middlewares.WriteError(w, r, err)
 		return
 	}
 
-	var user domain.User
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		http.Error(w, "error", http.StatusBadRequest)
+	h.setSessionCookies(w, userSession)
+	if userSession.AccessToken != "" {
+		h.writeTokens(w, r, userSession)
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type changePasswordRequest struct {
+	Login       string `json:"login"`
+	Password    string `json:"password"`
+	NewPassword string `json:"newPassword"`
+	// Code - код второго фактора, обязателен, если второй фактор включен
+	Code string `json:"code"`
+}
 
-	loggedUser, err := h.UsersService.Login(r.Context(), user)
+func (req changePasswordRequest) validate() error {
+	return validate(
+		field{name: "login", value: req.Login, rules: []rule{required(), length(0, maxLoginLength)}},
+		field{name: "password", value: req.Password, rules: []rule{required(), length(0, maxPasswordLength)}},
+		field{name: "newPassword", value: req.NewPassword, rules: []rule{required(), length(0, maxPasswordLength)}},
+	)
+}
+
+// ChangePassword меняет пароль по логину и текущему паролю, в том числе истекшему,
+// и открывает новую сессию.
+func (h UsersHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req changePasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	err := req.validate()
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "error", http.StatusBadRequest)
+		middlewares.WriteError(w, r, err)
 		return
 	}
 
-	userSession, err := h.SessionsService.CreateSession(loggedUser.ID)
+	user, err := h.UsersService.ChangePassword(r.Context(), req.Login, req.Password, req.NewPassword, req.Code)
+	if errors.Is(err, domain.ErrInvalidCode) {
+		// неверный код при смене пароля - неудачная аутентификация, как и на входе
+		middlewares.WriteErrorStatus(w, r, http.StatusUnauthorized, err)
+		return
+	}
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "unexpected error", http.StatusInternalServerError)
+		middlewares.WriteError(w, r, newPasswordError(credentialsError(err)))
 		return
 	}
 
-	sessionCookie := &http.Cookie{
-		Name:     "session",
-		HttpOnly: true,
-		Expires:  time.Now().Add(time.Minute * 5),
-		Value:    userSession.Key.String(),
+	userSession, err := h.SessionsService.CreateSession(r.Context(), user.ID, requestDevice(r))
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
 	}
 
-	http.SetCookie(w, sessionCookie)
-	w.WriteHeader(http.StatusOK)
+	h.setSessionCookies(w, userSession)
+	writeJSON(w, r, http.StatusOK, newUserResponse(user))
 }
 
-func (h UsersHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	sessKey := r.Context().Value(middlewares.SessionKey).(uuid.UUID)
-	w.Write([]byte(sessKey.String()))
-	w.WriteHeader(http.StatusOK)
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type tokensResponse struct {
+	AccessToken  string    `json:"accessToken"`
+	TokenType    string    `json:"tokenType"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	RefreshToken string    `json:"refreshToken"`
+}
+
+// Refresh обменивает refresh токен из cookie или тела запроса на новую пару токенов.
+func (h UsersHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var refreshToken string
+	if refreshCookie, err := r.Cookie(middlewares.RefreshCookieName); err == nil {
+		refreshToken = refreshCookie.Value
+	} else if r.Header.Get("Content-Type") == "application/json" {
+		var req refreshRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		refreshToken = req.RefreshToken
+	}
+	if refreshToken == "" {
+		middlewares.WriteError(w, r, domain.NewValidationError(
+			domain.FieldError{Field: "refreshToken", Code: "required", Message: "is required"}))
+		return
+	}
 
-	log.Println(sessKey.String())
-	err := h.SessionsService.DeleteSession(sessKey)
+	session, err := h.SessionsService.Refresh(r.Context(), refreshToken)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "unexpected error", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, domain.ErrNotFound), errors.Is(err, domain.ErrInvalidToken),
+			errors.Is(err, domain.ErrSessionExpired), errors.Is(err, domain.ErrTokenReused):
+			http.SetCookie(w, middlewares.ExpiredSessionCookie())
+			http.SetCookie(w, middlewares.ExpiredRefreshTokenCookie())
+			middlewares.WriteErrorStatus(w, r, http.StatusUnauthorized, err)
+		default:
+			middlewares.WriteError(w, r, err)
+		}
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	h.setSessionCookies(w, session)
+	h.writeTokens(w, r, session)
 }
 
-func (h UsersHandler) Session(w http.ResponseWriter, r *http.Request) {
-	sessionKeyParam := chi.URLParam(r, "key")
-	if sessionKeyParam == "" {
-		log.Println("session key required")
-		http.Error(w, "session key required", http.StatusBadRequest)
+// JWKS отдает открытые ключи, которыми другие сервисы проверяют access токены.
+func (h UsersHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.SessionsService.JWKS())
+}
+
+// setSessionCookies выставляет cookie с ключом сессии или, в режиме токенов, с access и refresh токенами.
+func (h UsersHandler) setSessionCookies(w http.ResponseWriter, session domain.Session) {
+	if session.AccessToken == "" {
+		http.SetCookie(w, middlewares.SessionCookie(session.Key, h.SessionsService.CookieExpires(session)))
+		return
+	}
+
+	http.SetCookie(w, middlewares.AccessTokenCookie(session.AccessToken, session.AccessExpiresAt))
+	http.SetCookie(w, middlewares.RefreshTokenCookie(session.RefreshToken, h.SessionsService.CookieExpires(session)))
+}
+
+func (h UsersHandler) writeTokens(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	writeJSON(w, r, http.StatusOK, tokensResponse{
+		AccessToken:  session.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    session.AccessExpiresAt,
+		RefreshToken: session.RefreshToken,
+	})
+}
+
+func (h UsersHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessKey, ok := r.Context().Value(middlewares.SessionKey).(uuid.UUID)
+	if !ok {
+		middlewares.WriteError(w, r, domain.ErrUnauthorized)
 		return
 	}
 
-	sessionKey, err := uuid.Parse(sessionKeyParam)
+	var err error
+	if sessKey == uuid.Nil {
+		// в режиме токенов ключа сессии нет, сессия завершается по id из access токена
+		userID, _ := middlewares.GetUserID(r.Context())
+		sessionID, _ := middlewares.GetSessionID(r.Context())
+		err = h.SessionsService.RevokeUserSession(r.Context(), userID, sessionID)
+	} else {
+		err = h.SessionsService.DeleteSession(r.Context(), sessKey)
+	}
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "invalid session key", http.StatusInternalServerError)
+		middlewares.WriteError(w, r, err)
 		return
 	}
 
-	session, err := h.SessionsService.ValidateSession(sessionKey)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h UsersHandler) Session(w http.ResponseWriter, r *http.Request) {
+	sessionKey, err := uuid.Parse(chi.URLParam(r, "key"))
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		middlewares.WriteError(w, r, domain.NewValidationError(
+			domain.FieldError{Field: "key", Code: "invalid", Message: "must be a session key"}))
 		return
 	}
 
-	sessionBytes, err := json.Marshal(session)
+	session, err := h.SessionsService.ValidateSession(r.Context(), sessionKey)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "error", http.StatusInternalServerError)
+		middlewares.WriteAuthError(w, r, err)
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.Write(sessionBytes)
+	writeJSON(w, r, http.StatusOK, session)
 }
 
 func (h UsersHandler) List(w http.ResponseWriter, r *http.Request) {
-	param := r.URL.Query().Get("param1")
-	log.Println(param)
+	query := r.URL.Query()
 
-	w.Write([]byte("list of users"))
-	w.WriteHeader(http.StatusOK)
+	params := domain.UsersListParams{
+		LoginPrefix: query.Get("login"),
+		SortBy:      query.Get("sort"),
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		params.Desc = true
+	default:
+		middlewares.WriteError(w, r, domain.NewValidationError(
+			domain.FieldError{Field: "order", Code: "invalid", Message: "must be asc or desc"}))
+		return
+	}
+
+	limit, ok := limitParam(w, r)
+	if !ok {
+		return
+	}
+	params.Limit = limit
+
+	page, err := h.UsersService.List(r.Context(), params, query.Get("cursor"))
+	if err != nil {
+		middlewares.WriteError(w, r, err)
+		return
+	}
+
+	resp := usersListResponse{
+		Users:      make([]userResponse, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+	}
+	for _, u := range page.Users {
+		resp.Users = append(resp.Users, newUserResponse(u))
+	}
+
+	writeJSON(w, r, http.StatusOK, resp)
 }
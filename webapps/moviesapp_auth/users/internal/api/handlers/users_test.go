@@ -2,14 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"movies-auth/users/internal/api/middlewares"
 	"movies-auth/users/internal/domain"
 	mock_api "movies-auth/users/internal/tests/api_mocks"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"go.uber.org/mock/gomock"
 )
 
@@ -24,9 +30,10 @@ func TestCreate(t *testing.T) {
 		name                string
 		fields              fields
 		mockUserServiceInit func(s *mock_api.MockUsersService)
+		mockSessionInit     func(s *mock_api.MockSessionService)
 		header              http.Header
 		wantStatusCode      int
-		wantErrMessage      string
+		wantErrCode         string
 		wantError           bool
 	}{
 		{
@@ -39,12 +46,12 @@ func TestCreate(t *testing.T) {
 				},
 			},
 			wantStatusCode: http.StatusUnsupportedMediaType,
-			wantErrMessage: "not supported content type\n",
+			wantErrCode:    "unsupported_media_type",
 			wantError:      true,
 		},
 		{
 			name:   "fail_conflict",
-			fields: fields{},
+			fields: fields{login: "user1", password: "12345678"},
 			mockUserServiceInit: func(s *mock_api.MockUsersService) {
 				s.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.User{}, domain.ErrConflict)
 			},
@@ -54,12 +61,12 @@ func TestCreate(t *testing.T) {
 				},
 			},
 			wantStatusCode: http.StatusConflict,
-			wantErrMessage: "user already exist\n",
+			wantErrCode:    "already_exists",
 			wantError:      true,
 		},
 		{
 			name:   "fail_conflict",
-			fields: fields{},
+			fields: fields{login: "user1", password: "12345678"},
 			mockUserServiceInit: func(s *mock_api.MockUsersService) {
 				s.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.User{}, domain.ErrConflict)
 			},
@@ -69,12 +76,12 @@ func TestCreate(t *testing.T) {
 				},
 			},
 			wantStatusCode: http.StatusConflict,
-			wantErrMessage: "user already exist\n",
+			wantErrCode:    "already_exists",
 			wantError:      true,
 		},
 		{
 			name:   "fail_internal_error",
-			fields: fields{},
+			fields: fields{login: "user1", password: "12345678"},
 			mockUserServiceInit: func(s *mock_api.MockUsersService) {
 				s.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.User{}, errors.New("unexpected error"))
 			},
@@ -84,7 +91,7 @@ func TestCreate(t *testing.T) {
 				},
 			},
 			wantStatusCode: http.StatusInternalServerError,
-			wantErrMessage: "unexpected error\n",
+			wantErrCode:    "internal",
 			wantError:      true,
 		},
 		{
@@ -98,6 +105,33 @@ func TestCreate(t *testing.T) {
 					Login:    "user1",
 					Password: "12345678",
 				}, nil)
+				s.EXPECT().RequiresEmailVerification(gomock.Any()).Return(false)
+			},
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Session{}, nil)
+				s.EXPECT().CookieExpires(gomock.Any()).Return(time.Now().Add(time.Minute))
+			},
+			header: http.Header{
+				"Content-Type": []string{
+					"application/json",
+				},
+			},
+			wantStatusCode: http.StatusCreated,
+			wantErrCode:    "internal",
+			wantError:      false,
+		},
+		{
+			name: "success_verification_required",
+			fields: fields{
+				login:    "user1",
+				password: "12345678",
+			},
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.User{
+					Login: "user1",
+					Email: "user1@example.com",
+				}, nil)
+				s.EXPECT().RequiresEmailVerification(gomock.Any()).Return(true)
 			},
 			header: http.Header{
 				"Content-Type": []string{
@@ -105,7 +139,6 @@ func TestCreate(t *testing.T) {
 				},
 			},
 			wantStatusCode: http.StatusCreated,
-			wantErrMessage: "unexpected error\n",
 			wantError:      false,
 		},
 	}
@@ -121,9 +154,12 @@ func TestCreate(t *testing.T) {
 			}
 
 			ss := mock_api.NewMockSessionService(ctrl)
+			if tc.mockSessionInit != nil {
+				tc.mockSessionInit(ss)
+			}
 
 			h := NewUsersHandler(su, ss)
-			payload := domain.User{
+			payload := registerRequest{
 				Login:    tc.fields.login,
 				Password: tc.fields.password,
 			}
@@ -139,11 +175,8 @@ func TestCreate(t *testing.T) {
 			}
 
 			if tc.wantError {
-				respBody := recorder.Body.Bytes()
-				msg := string(respBody)
-
-				if msg != tc.wantErrMessage {
-					t.Errorf("expected error message: %s, got: %s", tc.wantErrMessage, msg)
+				if code := problemCode(t, recorder); code != tc.wantErrCode {
+					t.Errorf("expected error code: %s, got: %s", tc.wantErrCode, code)
 				}
 				return
 			}
@@ -157,3 +190,516 @@ func TestCreate(t *testing.T) {
 		})
 	}
 }
+
+func TestList(t *testing.T) {
+	testCases := []struct {
+		name                string
+		query               string
+		mockUserServiceInit func(s *mock_api.MockUsersService)
+		wantStatusCode      int
+		wantErrCode         string
+		wantError           bool
+		wantLogins          []string
+		wantNextCursor      string
+	}{
+		{
+			name:                "fail_invalid_limit",
+			query:               "?limit=abc",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {},
+			wantStatusCode:      http.StatusBadRequest,
+			wantErrCode:         "invalid_params",
+			wantError:           true,
+		},
+		{
+			name:                "fail_invalid_order",
+			query:               "?order=up",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {},
+			wantStatusCode:      http.StatusBadRequest,
+			wantErrCode:         "invalid_params",
+			wantError:           true,
+		},
+		{
+			name:  "fail_invalid_params",
+			query: "?sort=password",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.UsersPage{}, domain.ErrInvalidParams)
+			},
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantError:      true,
+		},
+		{
+			name:  "fail_internal_error",
+			query: "",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.UsersPage{}, errors.New("unexpected error"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+			wantErrCode:    "internal",
+			wantError:      true,
+		},
+		{
+			name:  "success_list",
+			query: "?login=us&sort=login&order=desc&limit=2&cursor=abc",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				params := domain.UsersListParams{
+					LoginPrefix: "us",
+					SortBy:      domain.UsersSortByLogin,
+					Desc:        true,
+					Limit:       2,
+				}
+				s.EXPECT().List(gomock.Any(), params, "abc").Return(domain.UsersPage{
+					Users: []domain.User{
+						{ID: 2, Login: "user2", Password: "hash2"},
+						{ID: 1, Login: "user1", Password: "hash1"},
+					},
+					NextCursor: "next",
+				}, nil)
+			},
+			wantStatusCode: http.StatusOK,
+			wantLogins:     []string{"user2", "user1"},
+			wantNextCursor: "next",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := mock_api.NewMockUsersService(ctrl)
+			tc.mockUserServiceInit(su)
+			ss := mock_api.NewMockSessionService(ctrl)
+
+			h := NewUsersHandler(su, ss)
+			req := httptest.NewRequest(http.MethodGet, "/users/list"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+
+			h.List(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+
+			if tc.wantError {
+				if code := problemCode(t, recorder); code != tc.wantErrCode {
+					t.Errorf("expected error code: %s, got: %s", tc.wantErrCode, code)
+				}
+				return
+			}
+
+			if bytes.Contains(recorder.Body.Bytes(), []byte("password\"")) || bytes.Contains(recorder.Body.Bytes(), []byte("hash")) {
+				t.Errorf("expected no password in response, got: %s", recorder.Body.String())
+			}
+
+			var resp usersListResponse
+			err := json.NewDecoder(recorder.Result().Body).Decode(&resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Users) != len(tc.wantLogins) {
+				t.Fatalf("expected users: %v, got: %v", tc.wantLogins, resp.Users)
+			}
+			for i, u := range resp.Users {
+				if u.Login != tc.wantLogins[i] {
+					t.Errorf("expected user: %s, got: %s", tc.wantLogins[i], u.Login)
+				}
+			}
+
+			if resp.NextCursor != tc.wantNextCursor {
+				t.Errorf("expected next cursor: %s, got: %s", tc.wantNextCursor, resp.NextCursor)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	testCases := []struct {
+		name                string
+		mockUserServiceInit func(s *mock_api.MockUsersService)
+		mockSessionInit     func(s *mock_api.MockSessionService)
+		wantStatusCode      int
+		wantErrCode         string
+		wantError           bool
+	}{
+		{
+			name: "fail_invalid_password",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "old", "New-pass1", "").Return(domain.User{}, domain.ErrInvalidPassword)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantErrCode:    "invalid_credentials",
+			wantError:      true,
+		},
+		{
+			name: "fail_account_locked",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "old", "New-pass1", "").
+					Return(domain.User{}, &domain.RetryError{Err: domain.ErrAccountLocked, RetryAfter: time.Minute})
+			},
+			wantStatusCode: http.StatusTooManyRequests,
+			wantErrCode:    "account_locked",
+			wantError:      true,
+		},
+		{
+			name: "fail_second_factor_required",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "old", "New-pass1", "").Return(domain.User{}, domain.ErrSecondFactorRequired)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantErrCode:    "second_factor_required",
+			wantError:      true,
+		},
+		{
+			name: "fail_invalid_code",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "old", "New-pass1", "").Return(domain.User{}, domain.ErrInvalidCode)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantErrCode:    "invalid_code",
+			wantError:      true,
+		},
+		{
+			name: "fail_password_reused",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "old", "New-pass1", "").Return(domain.User{}, domain.ErrPasswordReused)
+			},
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "password_reused",
+			wantError:      true,
+		},
+		{
+			name: "fail_internal_error",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "old", "New-pass1", "").Return(domain.User{}, errors.New("unexpected error"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+			wantErrCode:    "internal",
+			wantError:      true,
+		},
+		{
+			name: "success_password_changed",
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "old", "New-pass1", "").Return(domain.User{ID: 1, Login: "user1"}, nil)
+			},
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().CreateSession(gomock.Any(), 1, gomock.Any()).Return(domain.Session{}, nil)
+				s.EXPECT().CookieExpires(gomock.Any()).Return(time.Now().Add(time.Minute))
+			},
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := mock_api.NewMockUsersService(ctrl)
+			tc.mockUserServiceInit(su)
+			ss := mock_api.NewMockSessionService(ctrl)
+			if tc.mockSessionInit != nil {
+				tc.mockSessionInit(ss)
+			}
+
+			h := NewUsersHandler(su, ss)
+			body, _ := json.Marshal(changePasswordRequest{Login: "user1", Password: "old", NewPassword: "New-pass1"})
+			req := httptest.NewRequest(http.MethodPost, "/users/password", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.ChangePassword(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+
+			if tc.wantError {
+				if code := problemCode(t, recorder); code != tc.wantErrCode {
+					t.Errorf("expected error code: %s, got: %s", tc.wantErrCode, code)
+				}
+				return
+			}
+
+			if len(recorder.Result().Cookies()) == 0 {
+				t.Errorf("expected session cookie")
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	testCases := []struct {
+		name            string
+		body            string
+		mockSessionInit func(s *mock_api.MockSessionService)
+		wantStatusCode  int
+		wantCookies     int
+	}{
+		{
+			name:           "fail_token_required",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "fail_token_reused",
+			body: `{"refreshToken":"old"}`,
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().Refresh(gomock.Any(), "old").Return(domain.Session{}, domain.ErrTokenReused)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantCookies:    2,
+		},
+		{
+			name: "fail_internal_error",
+			body: `{"refreshToken":"token"}`,
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().Refresh(gomock.Any(), "token").Return(domain.Session{}, errors.New("unexpected error"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "success_refreshed",
+			body: `{"refreshToken":"token"}`,
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().Refresh(gomock.Any(), "token").
+					Return(domain.Session{AccessToken: "access", RefreshToken: "refresh", AccessExpiresAt: time.Now().Add(time.Minute)}, nil)
+				s.EXPECT().CookieExpires(gomock.Any()).Return(time.Now().Add(time.Hour))
+			},
+			wantStatusCode: http.StatusOK,
+			wantCookies:    2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ss := mock_api.NewMockSessionService(ctrl)
+			if tc.mockSessionInit != nil {
+				tc.mockSessionInit(ss)
+			}
+
+			h := NewUsersHandler(mock_api.NewMockUsersService(ctrl), ss)
+			req := httptest.NewRequest(http.MethodPost, "/users/refresh", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.Refresh(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+			if len(recorder.Result().Cookies()) != tc.wantCookies {
+				t.Errorf("expected cookies: %d, got: %d", tc.wantCookies, len(recorder.Result().Cookies()))
+			}
+			if tc.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			var resp tokensResponse
+			err := json.NewDecoder(recorder.Body).Decode(&resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.AccessToken != "access" || resp.RefreshToken != "refresh" || resp.TokenType != "Bearer" {
+				t.Errorf("unexpected tokens response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	testCases := []struct {
+		name                string
+		body                string
+		mockUserServiceInit func(s *mock_api.MockUsersService)
+		wantStatusCode      int
+		wantErrCode         string
+	}{
+		{
+			name:           "fail_malformed_body",
+			body:           `{"login":`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "malformed_body",
+		},
+		{
+			name: "fail_unknown_login",
+			body: `{"login":"user1","password":"password1"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().Login(gomock.Any(), gomock.Any()).Return(domain.User{}, fmt.Errorf("failed to get user from storage: %w", domain.ErrNotFound))
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantErrCode:    "invalid_credentials",
+		},
+		{
+			name: "fail_password_expired",
+			body: `{"login":"user1","password":"password1"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().Login(gomock.Any(), gomock.Any()).Return(domain.User{}, fmt.Errorf("user 1: %w", domain.ErrPasswordExpired))
+			},
+			wantStatusCode: http.StatusForbidden,
+			wantErrCode:    "password_expired",
+		},
+		{
+			name: "fail_storage_error",
+			body: `{"login":"user1","password":"password1"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().Login(gomock.Any(), gomock.Any()).Return(domain.User{}, errors.New("connection refused"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+			wantErrCode:    "internal",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUserServiceInit != nil {
+				tc.mockUserServiceInit(su)
+			}
+
+			h := NewUsersHandler(su, mock_api.NewMockSessionService(ctrl))
+			req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.Login(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+			if code := problemCode(t, recorder); code != tc.wantErrCode {
+				t.Errorf("expected error code: %s, got: %s", tc.wantErrCode, code)
+			}
+		})
+	}
+}
+
+func TestSession(t *testing.T) {
+	key := uuid.New()
+
+	testCases := []struct {
+		name            string
+		key             string
+		mockSessionInit func(s *mock_api.MockSessionService)
+		wantStatusCode  int
+	}{
+		{
+			name:           "fail_invalid_key",
+			key:            "not-a-key",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "fail_expired",
+			key:  key.String(),
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().ValidateSession(gomock.Any(), key).Return(domain.Session{}, domain.ErrSessionExpired)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "fail_storage_error",
+			key:  key.String(),
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().ValidateSession(gomock.Any(), key).Return(domain.Session{}, errors.New("connection refused"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "success",
+			key:  key.String(),
+			mockSessionInit: func(s *mock_api.MockSessionService) {
+				s.EXPECT().ValidateSession(gomock.Any(), key).Return(domain.Session{Key: key, UserID: 1}, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ss := mock_api.NewMockSessionService(ctrl)
+			if tc.mockSessionInit != nil {
+				tc.mockSessionInit(ss)
+			}
+
+			h := NewUsersHandler(mock_api.NewMockUsersService(ctrl), ss)
+			r := chi.NewRouter()
+			r.Get("/users/sessions/{key}", h.Session)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/sessions/"+tc.key, nil)
+			recorder := httptest.NewRecorder()
+
+			r.ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key := uuid.New()
+	ss := mock_api.NewMockSessionService(ctrl)
+	ss.EXPECT().DeleteSession(gomock.Any(), key).Return(errors.New("connection refused"))
+
+	h := NewUsersHandler(mock_api.NewMockUsersService(ctrl), ss)
+	req := httptest.NewRequest(http.MethodPost, "/users/logout", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middlewares.SessionKey, key))
+	recorder := httptest.NewRecorder()
+
+	h.Logout(recorder, req)
+	if recorder.Result().StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected status code: %d, got: %d", http.StatusInternalServerError, recorder.Result().StatusCode)
+	}
+	if code := problemCode(t, recorder); code != "internal" {
+		t.Errorf("expected error code: internal, got: %s", code)
+	}
+}
+
+func TestLogoutTokenMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// ключа сессии в access токене нет, сессия завершается по id
+	ss := mock_api.NewMockSessionService(ctrl)
+	ss.EXPECT().RevokeUserSession(gomock.Any(), 1, 3).Return(nil)
+
+	h := NewUsersHandler(mock_api.NewMockUsersService(ctrl), ss)
+	r := chi.NewRouter()
+	r.Use(middlewares.Auth(tokenAuthStub{}))
+	r.Post("/users/logout", h.Logout)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/logout", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	recorder := httptest.NewRecorder()
+
+	r.ServeHTTP(recorder, req)
+	if recorder.Result().StatusCode != http.StatusOK {
+		t.Errorf("expected status code: %d, got: %d", http.StatusOK, recorder.Result().StatusCode)
+	}
+}
+
+// problemCode возвращает code из тела ответа с ошибкой.
+func problemCode(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	if ct := recorder.Header().Get("Content-Type"); ct != middlewares.ProblemContentType {
+		t.Fatalf("expected problem content type, got: %s", ct)
+	}
+
+	var problem middlewares.Problem
+	err := json.NewDecoder(recorder.Body).Decode(&problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return problem.Code
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodySize ограничивает тело JSON запросов: все запросы API - небольшие формы.
+const maxBodySize = 64 << 10
+
+const (
+	minLoginLength = 3
+	maxLoginLength = 32
+	// maxPasswordLength ограничивает стоимость хэширования пароля в символах. Ограничение алгоритма
+	// хэширования в байтах (72 для bcrypt) и политику паролей проверяет сервис
+	maxPasswordLength = 128
+	maxEmailLength    = 254
+)
+
+// decodeJSON читает тело запроса в JSON, при ошибке отвечает 415, 413 или 400.
+// Поля, которых нет в v, считаются ошибкой, чтобы клиент не передавал, например, id пользователя.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.Header.Get("Content-Type") != "application/json" {
+		middlewares.WriteProblem(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", "content type must be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after JSON object")
+	}
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return false
+	}
+
+	return true
+}
+
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		middlewares.WriteProblem(w, r, http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		middlewares.WriteError(w, r, domain.NewValidationError(domain.FieldError{
+			Field: typeErr.Field, Code: "type", Message: "must be a " + typeErr.Type.String(),
+		}))
+	default:
+		// у encoding/json нет отдельного типа ошибки для неизвестного поля
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			middlewares.WriteError(w, r, domain.NewValidationError(domain.FieldError{
+				Field: strings.Trim(name, `"`), Code: "unknown", Message: "is not allowed",
+			}))
+			return
+		}
+		if errors.Is(err, io.EOF) {
+			err = errors.New("empty body")
+		}
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "malformed_body", "request body is not valid JSON: "+err.Error())
+	}
+}
+
+// rule проверяет значение поля и возвращает нарушение или nil. Правила, кроме required,
+// не проверяют пустые значения, поэтому необязательные поля обходятся без отдельного условия.
+type rule func(value string) *domain.FieldError
+
+// field - поле запроса с правилами проверки. Правила проверяются по порядку до первого нарушения.
+type field struct {
+	name  string
+	value string
+	rules []rule
+}
+
+// validate проверяет поля запроса и возвращает *domain.ValidationError со всеми нарушениями.
+func validate(fields ...field) error {
+	var violations []domain.FieldError
+	for _, f := range fields {
+		for _, check := range f.rules {
+			violation := check(f.value)
+			if violation != nil {
+				violation.Field = f.name
+				violations = append(violations, *violation)
+				break
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return domain.NewValidationError(violations...)
+}
+
+func required() rule {
+	return func(value string) *domain.FieldError {
+		if strings.TrimSpace(value) == "" {
+			return &domain.FieldError{Code: "required", Message: "is required"}
+		}
+		return nil
+	}
+}
+
+// length ограничивает длину значения в символах, нулевой min не ограничивает длину снизу.
+func length(min int, max int) rule {
+	return func(value string) *domain.FieldError {
+		n := utf8.RuneCountInString(value)
+		switch {
+		case value == "":
+			return nil
+		case n < min:
+			return &domain.FieldError{Code: "too_short", Message: fmt.Sprintf("must be at least %d characters", min)}
+		case n > max:
+			return &domain.FieldError{Code: "too_long", Message: fmt.Sprintf("must be at most %d characters", max)}
+		}
+		return nil
+	}
+}
+
+// loginCharset допускает латинские буквы, цифры и символы . _ - внутри логина.
+func loginCharset() rule {
+	return func(value string) *domain.FieldError {
+		for i, c := range value {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			case (c == '.' || c == '_' || c == '-') && i > 0 && i < len(value)-1:
+			default:
+				return &domain.FieldError{Code: "charset",
+					Message: "must contain only latin letters, digits and . _ - not at the start or end"}
+			}
+		}
+		return nil
+	}
+}
+
+func email() rule {
+	return func(value string) *domain.FieldError {
+		if value == "" {
+			return nil
+		}
+		addr, err := mail.ParseAddress(value)
+		if err != nil || addr.Address != value {
+			return &domain.FieldError{Code: "invalid", Message: "must be an email address"}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"movies-auth/users/internal/api/middlewares"
+	"movies-auth/users/internal/domain"
+	mock_api "movies-auth/users/internal/tests/api_mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestRegisterValidation(t *testing.T) {
+	testCases := []struct {
+		name                string
+		body                string
+		mockUserServiceInit func(s *mock_api.MockUsersService)
+		wantStatusCode      int
+		wantErrCode         string
+		wantFields          map[string]string
+	}{
+		{
+			name:           "fail_login_required",
+			body:           `{"login":"","password":"password1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "required"},
+		},
+		{
+			name:           "fail_login_too_short",
+			body:           `{"login":"ab","password":"password1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "too_short"},
+		},
+		{
+			name:           "fail_login_too_long",
+			body:           `{"login":"` + strings.Repeat("a", maxLoginLength+1) + `","password":"password1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "too_long"},
+		},
+		{
+			name:           "fail_login_charset",
+			body:           `{"login":"user 1","password":"password1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "charset"},
+		},
+		{
+			name:           "fail_login_edge_symbol",
+			body:           `{"login":".user1","password":"password1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "charset"},
+		},
+		{
+			name:           "fail_password_required",
+			body:           `{"login":"user1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"password": "required"},
+		},
+		{
+			name:           "fail_password_too_long",
+			body:           `{"login":"user1","password":"` + strings.Repeat("p", maxPasswordLength+1) + `"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"password": "too_long"},
+		},
+		{
+			name: "fail_password_policy",
+			body: `{"login":"user1","password":"short"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.User{}, &domain.ValidationError{
+					Err:    domain.ErrWeakPassword,
+					Fields: []domain.FieldError{{Field: "password", Code: "policy", Message: "requires at least 8 characters"}},
+				})
+			},
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "weak_password",
+			wantFields:     map[string]string{"password": "policy"},
+		},
+		{
+			name:           "fail_invalid_email",
+			body:           `{"login":"user1","password":"password1","email":"user1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"email": "invalid"},
+		},
+		{
+			name:           "fail_all_fields",
+			body:           `{"login":"","password":"","email":"@"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "required", "password": "required", "email": "invalid"},
+		},
+		{
+			name:           "fail_unknown_field",
+			body:           `{"login":"user1","password":"password1","id":1}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"id": "unknown"},
+		},
+		{
+			name:           "fail_server_field",
+			body:           `{"login":"user1","password":"password1","notificationSent":true}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"notificationSent": "unknown"},
+		},
+		{
+			name:           "fail_field_type",
+			body:           `{"login":1,"password":"password1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "type"},
+		},
+		{
+			name:           "fail_malformed_body",
+			body:           `{"login":"user1"`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "malformed_body",
+		},
+		{
+			name:           "fail_trailing_data",
+			body:           `{"login":"user1","password":"password1"} {}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "malformed_body",
+		},
+		{
+			name:           "fail_body_too_large",
+			body:           `{"login":"user1","password":"` + strings.Repeat("p", maxBodySize) + `"}`,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+			wantErrCode:    "body_too_large",
+		},
+		{
+			name: "success_valid_form",
+			body: `{"login":"user.name-1","password":"password1","email":"user1@example.com","notificationChannel":"email"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().Create(gomock.Any(), domain.User{
+					Login:               "user.name-1",
+					Password:            "password1",
+					Email:               "user1@example.com",
+					NotificationChannel: domain.NotificationChannelEmail,
+				}).Return(domain.User{ID: 1, Login: "user.name-1"}, nil)
+				s.EXPECT().RequiresEmailVerification(gomock.Any()).Return(true)
+			},
+			wantStatusCode: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUserServiceInit != nil {
+				tc.mockUserServiceInit(su)
+			}
+
+			h := NewUsersHandler(su, mock_api.NewMockSessionService(ctrl))
+			req := httptest.NewRequest(http.MethodPost, "/users/register", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.Register(recorder, req)
+			checkValidationResponse(t, recorder, tc.wantStatusCode, tc.wantErrCode, tc.wantFields)
+		})
+	}
+}
+
+func TestLoginValidation(t *testing.T) {
+	testCases := []struct {
+		name                string
+		body                string
+		mockUserServiceInit func(s *mock_api.MockUsersService)
+		wantStatusCode      int
+		wantErrCode         string
+		wantFields          map[string]string
+	}{
+		{
+			name:           "fail_credentials_required",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "required", "password": "required"},
+		},
+		{
+			name:           "fail_login_too_long",
+			body:           `{"login":"` + strings.Repeat("a", maxLoginLength+1) + `","password":"password1"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "too_long"},
+		},
+		{
+			name:           "fail_unknown_field",
+			body:           `{"login":"user1","password":"password1","passwordExpires":"2030-01-01T00:00:00Z"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"passwordExpires": "unknown"},
+		},
+		{
+			name:           "fail_body_too_large",
+			body:           `{"login":"` + strings.Repeat("a", maxBodySize) + `"}`,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+			wantErrCode:    "body_too_large",
+		},
+		{
+			name: "success_legacy_login",
+			body: `{"login":"old login","password":"password1"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().Login(gomock.Any(), domain.User{Login: "old login", Password: "password1"}).
+					Return(domain.User{}, domain.ErrInvalidPassword)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantErrCode:    "invalid_credentials",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUserServiceInit != nil {
+				tc.mockUserServiceInit(su)
+			}
+
+			h := NewUsersHandler(su, mock_api.NewMockSessionService(ctrl))
+			req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.Login(recorder, req)
+			checkValidationResponse(t, recorder, tc.wantStatusCode, tc.wantErrCode, tc.wantFields)
+		})
+	}
+}
+
+func TestChangePasswordValidation(t *testing.T) {
+	testCases := []struct {
+		name                string
+		body                string
+		mockUserServiceInit func(s *mock_api.MockUsersService)
+		wantStatusCode      int
+		wantErrCode         string
+		wantFields          map[string]string
+	}{
+		{
+			name:           "fail_fields_required",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"login": "required", "password": "required", "newPassword": "required"},
+		},
+		{
+			name:           "fail_new_password_empty",
+			body:           `{"login":"user1","password":"password1","newPassword":" "}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"newPassword": "required"},
+		},
+		{
+			name:           "fail_new_password_too_long",
+			body:           `{"login":"user1","password":"password1","newPassword":"` + strings.Repeat("a", maxPasswordLength+1) + `"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"newPassword": "too_long"},
+		},
+		{
+			name: "success_code_optional",
+			body: `{"login":"user1","password":"password1","newPassword":"password2"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ChangePassword(gomock.Any(), "user1", "password1", "password2", "").
+					Return(domain.User{}, domain.ErrInvalidPassword)
+			},
+			wantStatusCode: http.StatusUnauthorized,
+			wantErrCode:    "invalid_credentials",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUserServiceInit != nil {
+				tc.mockUserServiceInit(su)
+			}
+
+			h := NewUsersHandler(su, mock_api.NewMockSessionService(ctrl))
+			req := httptest.NewRequest(http.MethodPost, "/users/password", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.ChangePassword(recorder, req)
+			checkValidationResponse(t, recorder, tc.wantStatusCode, tc.wantErrCode, tc.wantFields)
+		})
+	}
+}
+
+func TestResetPasswordValidation(t *testing.T) {
+	testCases := []struct {
+		name                string
+		body                string
+		mockUserServiceInit func(s *mock_api.MockUsersService)
+		wantStatusCode      int
+		wantErrCode         string
+		wantFields          map[string]string
+	}{
+		{
+			name:           "fail_fields_required",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"token": "required", "newPassword": "required"},
+		},
+		{
+			name:           "fail_new_password_too_long",
+			body:           `{"token":"token","newPassword":"` + strings.Repeat("a", maxPasswordLength+1) + `"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_params",
+			wantFields:     map[string]string{"newPassword": "too_long"},
+		},
+		{
+			name: "success_valid",
+			body: `{"token":"token","newPassword":"password2"}`,
+			mockUserServiceInit: func(s *mock_api.MockUsersService) {
+				s.EXPECT().ResetPassword(gomock.Any(), "token", "password2").Return(domain.User{}, domain.ErrInvalidToken)
+			},
+			wantStatusCode: http.StatusBadRequest,
+			wantErrCode:    "invalid_token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := mock_api.NewMockUsersService(ctrl)
+			if tc.mockUserServiceInit != nil {
+				tc.mockUserServiceInit(su)
+			}
+
+			h := NewUsersHandler(su, mock_api.NewMockSessionService(ctrl))
+			req := httptest.NewRequest(http.MethodPost, "/users/password/reset", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			h.ResetPassword(recorder, req)
+			checkValidationResponse(t, recorder, tc.wantStatusCode, tc.wantErrCode, tc.wantFields)
+		})
+	}
+}
+
+// checkValidationResponse сверяет код ответа, code ошибки и коды нарушений по полям.
+func checkValidationResponse(t *testing.T, recorder *httptest.ResponseRecorder, wantStatusCode int, wantErrCode string, wantFields map[string]string) {
+	t.Helper()
+
+	if recorder.Result().StatusCode != wantStatusCode {
+		t.Fatalf("expected status code: %d, got: %d, body: %s", wantStatusCode, recorder.Result().StatusCode, recorder.Body.String())
+	}
+	if wantErrCode == "" {
+		return
+	}
+
+	var problem middlewares.Problem
+	err := json.NewDecoder(recorder.Body).Decode(&problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problem.Code != wantErrCode {
+		t.Errorf("expected error code: %s, got: %s", wantErrCode, problem.Code)
+	}
+
+	gotFields := make(map[string]string, len(problem.Errors))
+	for _, f := range problem.Errors {
+		gotFields[f.Field] = f.Code
+	}
+	if len(gotFields) != len(wantFields) {
+		t.Fatalf("expected field errors: %v, got: %v", wantFields, gotFields)
+	}
+	for field, code := range wantFields {
+		if gotFields[field] != code {
+			t.Errorf("expected %s error for field %s, got: %q", code, field, gotFields[field])
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"movies-auth/users/internal/audit"
+	"net/http"
+)
+
+// Audit кладет в контекст адрес клиента, User-Agent и идентификатор запроса для журнала аудита.
+// Должен стоять после RequestID.
+func Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := audit.WithRequest(r.Context(), audit.Request{
+			IP:        ClientIP(r),
+			UserAgent: r.UserAgent(),
+			RequestID: GetRequestID(r.Context()),
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
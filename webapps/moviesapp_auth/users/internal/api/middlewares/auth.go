@@ -2,15 +2,21 @@ package middlewares
 
 import (
 	"context"
+	"errors"
+	"movies-auth/users/internal/audit"
 	"movies-auth/users/internal/domain"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type SessionService interface {
-	ValidateSession(key uuid.UUID) (domain.Session, error)
+	ValidateSession(ctx context.Context, key uuid.UUID) (domain.Session, error)
+	VerifyAccessToken(ctx context.Context, token string) (domain.Session, error)
+	TokensEnabled() bool
+	CookieExpires(session domain.Session) time.Time
 }
 
 type sessionKey string
@@ -20,30 +26,112 @@ var SessionKey sessionKey = "sessionKey"
 func Auth(ss SessionService) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if strings.Contains(r.URL.Path, "login") || strings.Contains(r.URL.Path, "register") || strings.Contains(r.URL.Path, "sessions") {
+			if publicPath(r.URL.Path) {
 				next.ServeHTTP(w, r)
+				return
 			}
 
-			sessionCookie, err := r.Cookie("session")
-			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
+			credential := sessionCredential(r)
+			if credential == "" {
+				WriteError(w, r, domain.ErrUnauthorized)
 				return
 			}
 
-			sessionKey, err := uuid.Parse(sessionCookie.Value)
-			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
+			sessionKey, err := uuid.Parse(credential)
+			if err != nil || ss.TokensEnabled() {
+				// в режиме токенов принимается только access токен, даже если учетные данные похожи на ключ сессии
+				session, err := ss.VerifyAccessToken(r.Context(), credential)
+				if err != nil {
+					WriteAuthError(w, r, err)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(withSession(r.Context(), session)))
 				return
 			}
 
-			session, err := ss.ValidateSession(sessionKey)
+			session, err := ss.ValidateSession(r.Context(), sessionKey)
 			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
+				if errors.Is(err, domain.ErrSessionExpired) {
+					http.SetCookie(w, ExpiredSessionCookie())
+				}
+				WriteAuthError(w, r, err)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), SessionKey, session.Key)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			// сессия продлена, продлеваем и cookie
+			http.SetCookie(w, SessionCookie(session.Key, ss.CookieExpires(session)))
+
+			next.ServeHTTP(w, r.WithContext(withSession(r.Context(), session)))
 		})
 	}
 }
+
+// WriteAuthError отвечает 401 на отклоненные сессии и токены, сохраняя код ошибки,
+// и 500 на сбои их проверки.
+func WriteAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		WriteErrorStatus(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	WriteError(w, r, err)
+}
+
+type userIDKey struct{}
+
+type sessionIDKey struct{}
+
+// withSession сохраняет в контексте пользователя, id, ключ и права проверенной сессии.
+// В режиме токенов ключ сессии не известен и остается нулевым.
+func withSession(ctx context.Context, session domain.Session) context.Context {
+	ctx = setUserID(ctx, session.UserID)
+	ctx = audit.WithActor(ctx, session.UserID)
+	ctx = context.WithValue(ctx, userIDKey{}, session.UserID)
+	ctx = context.WithValue(ctx, sessionIDKey{}, session.ID)
+	ctx = context.WithValue(ctx, SessionKey, session.Key)
+
+	return setAccess(ctx, session)
+}
+
+// GetUserID возвращает id пользователя сессии, проверенной Auth.
+func GetUserID(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(int)
+
+	return userID, ok
+}
+
+// GetSessionID возвращает id сессии, проверенной Auth.
+func GetSessionID(ctx context.Context) (int, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey{}).(int)
+
+	return sessionID, ok
+}
+
+// publicPath сообщает, доступен ли маршрут без сессии. Путь сравнивается целиком,
+// чтобы, например, /admin/users/{id}/sessions не считался открытым.
+func publicPath(path string) bool {
+	switch path {
+	case "/users/login", "/users/login/2fa", "/users/register", "/users/password", "/users/refresh",
+		"/users/email/verify", "/users/email/verify/request", "/users/password/reset", "/users/password/reset/request":
+		return true
+	}
+
+	// проверка сессий другими сервисами и открытые ключи токенов
+	return strings.HasPrefix(path, "/users/sessions/")
+}
+
+// sessionCredential возвращает ключ сессии или access токен из заголовка Authorization или cookie сессии.
+func sessionCredential(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+
+	sessionCookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+
+	return sessionCookie.Value
+}
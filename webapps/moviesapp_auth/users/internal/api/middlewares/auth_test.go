@@ -0,0 +1,160 @@
+package middlewares
+
+import (
+	"context"
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionServiceStub принимает только ключ key и access токен token.
+type sessionServiceStub struct {
+	key    uuid.UUID
+	token  string
+	tokens bool
+}
+
+func (s sessionServiceStub) ValidateSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	if key != s.key {
+		return domain.Session{}, domain.ErrNotFound
+	}
+	return domain.Session{Key: key, UserID: 1}, nil
+}
+
+func (s sessionServiceStub) VerifyAccessToken(ctx context.Context, token string) (domain.Session, error) {
+	if token != s.token {
+		return domain.Session{}, domain.ErrInvalidToken
+	}
+	return domain.Session{Key: s.key, UserID: 1}, nil
+}
+
+func (s sessionServiceStub) CookieExpires(session domain.Session) time.Time {
+	return time.Now().Add(time.Hour)
+}
+
+func (s sessionServiceStub) TokensEnabled() bool {
+	return s.tokens
+}
+
+func TestAuth(t *testing.T) {
+	key := uuid.New()
+
+	testCases := []struct {
+		name           string
+		tokens         bool
+		credential     string
+		wantStatusCode int
+	}{
+		{
+			name:           "success_session_key",
+			credential:     key.String(),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "success_access_token",
+			tokens:         true,
+			credential:     "token",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "fail_session_key_in_token_mode",
+			tokens:         true,
+			credential:     key.String(),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "fail_no_credential",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ss := sessionServiceStub{key: key, token: "token", tokens: tc.tokens}
+			handler := Auth(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID, ok := GetUserID(r.Context())
+				if !ok || userID != 1 {
+					t.Errorf("expected user id: %d, got: %d", 1, userID)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			if tc.credential != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.credential)
+			}
+			recorder := httptest.NewRecorder()
+
+			handler.ServeHTTP(recorder, req)
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestPublicPath(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		wantPublic bool
+	}{
+		{
+			name:       "success_login",
+			path:       "/users/login",
+			wantPublic: true,
+		},
+		{
+			name:       "success_refresh",
+			path:       "/users/refresh",
+			wantPublic: true,
+		},
+		{
+			name:       "success_password_reset",
+			path:       "/users/password/reset",
+			wantPublic: true,
+		},
+		{
+			name:       "success_jwks",
+			path:       "/users/sessions/jwks",
+			wantPublic: true,
+		},
+		{
+			name:       "success_session_validate",
+			path:       "/users/sessions/9b2f5c3e-0d4a-4b7e-9a51-7c3f1f0e2d11",
+			wantPublic: true,
+		},
+		{
+			name:       "fail_users_list",
+			path:       "/users/list",
+			wantPublic: false,
+		},
+		{
+			name:       "fail_logout",
+			path:       "/users/logout",
+			wantPublic: false,
+		},
+		{
+			name:       "fail_admin_sessions",
+			path:       "/admin/users/1/sessions",
+			wantPublic: false,
+		},
+		{
+			name:       "fail_admin_login_suffix",
+			path:       "/admin/users/login",
+			wantPublic: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			public := publicPath(tc.path)
+			if public != tc.wantPublic {
+				t.Errorf("expected public path: %v, got: %v", tc.wantPublic, public)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SessionCookieName = "session"
+	// RefreshCookieName - cookie с refresh токеном, отправляется только на эндпоинт обновления
+	RefreshCookieName = "refresh_token"
+	RefreshCookiePath = "/users/refresh"
+)
+
+func SessionCookie(key uuid.UUID, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		HttpOnly: true,
+		Expires:  expires,
+		Value:    key.String(),
+	}
+}
+
+// AccessTokenCookie - cookie сессии с access токеном вместо ключа в режиме токенов.
+func AccessTokenCookie(token string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		HttpOnly: true,
+		Expires:  expires,
+		Value:    token,
+	}
+}
+
+func RefreshTokenCookie(token string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     RefreshCookieName,
+		Path:     RefreshCookiePath,
+		HttpOnly: true,
+		Expires:  expires,
+		Value:    token,
+	}
+}
+
+func ExpiredRefreshTokenCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     RefreshCookieName,
+		Path:     RefreshCookiePath,
+		HttpOnly: true,
+		MaxAge:   -1,
+	}
+}
+
+func ExpiredSessionCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1,
+	}
+}
@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"net/http"
+	"strconv"
+)
+
+// ProblemContentType - тип тела ответов с ошибкой (problem details, RFC 9457).
+const ProblemContentType = "application/problem+json"
+
+// Problem - тело ответа с ошибкой. Code - стабильный код ошибки, по которому клиенты
+// различают ошибки, Errors - ошибки отдельных полей запроса.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Code      string              `json:"code"`
+	Detail    string              `json:"detail,omitempty"`
+	RequestID string              `json:"requestId,omitempty"`
+	Errors    []ProblemFieldError `json:"errors,omitempty"`
+}
+
+type ProblemFieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var kindStatus = map[domain.ErrorKind]int{
+	domain.KindValidation:   http.StatusBadRequest,
+	domain.KindUnauthorized: http.StatusUnauthorized,
+	domain.KindForbidden:    http.StatusForbidden,
+	domain.KindNotFound:     http.StatusNotFound,
+	domain.KindConflict:     http.StatusConflict,
+	domain.KindLocked:       http.StatusTooManyRequests,
+}
+
+// WriteError отвечает ошибкой err. Код ответа и code берутся из первой *domain.Error в цепочке err,
+// остальные ошибки считаются внутренними: клиент видит только общий ответ 500, а подробности уходят в лог.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	WriteErrorStatus(w, r, 0, err)
+}
+
+// WriteErrorStatus - WriteError с кодом ответа status для ошибок, смысл которых зависит от запроса,
+// например неверный код второго фактора при входе. Нулевой status выбирается по категории ошибки.
+func WriteErrorStatus(w http.ResponseWriter, r *http.Request, status int, err error) {
+	problem := Problem{Status: http.StatusInternalServerError, Code: "internal", Detail: "unexpected error"}
+
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		if s, ok := kindStatus[domainErr.Kind]; ok {
+			problem.Status = s
+			problem.Code = domainErr.Code
+			problem.Detail = domainErr.Message
+		}
+	}
+	if status != 0 {
+		problem.Status = status
+	}
+
+	var validationErr *domain.ValidationError
+	if problem.Status < http.StatusInternalServerError && errors.As(err, &validationErr) {
+		for _, f := range validationErr.Fields {
+			problem.Errors = append(problem.Errors, ProblemFieldError(f))
+		}
+	}
+
+	var retryErr *domain.RetryError
+	if errors.As(err, &retryErr) {
+		seconds := int(math.Ceil(retryErr.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	logger := logging.FromContext(r.Context())
+	if problem.Status >= http.StatusInternalServerError {
+		logger.ErrorContext(r.Context(), "request failed", slog.Any("error", err))
+	} else {
+		logger.InfoContext(r.Context(), "request rejected", slog.String("code", problem.Code), slog.Any("error", err))
+	}
+
+	writeProblem(w, r, problem)
+}
+
+// WriteProblem отвечает ошибкой протокола, не связанной с предметной областью,
+// например неподдерживаемым типом содержимого.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	writeProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+	problem.RequestID = GetRequestID(r.Context())
+
+	// Problem всегда сериализуется, ошибку можно не проверять
+	body, _ := json.Marshal(problem)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	w.Write(body)
+}
@@ -0,0 +1,105 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteError(t *testing.T) {
+	testCases := []struct {
+		name           string
+		status         int
+		err            error
+		wantStatusCode int
+		wantCode       string
+		wantFields     []ProblemFieldError
+		wantRetryAfter string
+	}{
+		{
+			name:           "fail_not_found",
+			err:            fmt.Errorf("user 1: %w", domain.ErrNotFound),
+			wantStatusCode: http.StatusNotFound,
+			wantCode:       "not_found",
+		},
+		{
+			name: "fail_validation_fields",
+			err: fmt.Errorf("failed to create user: %w", domain.NewValidationError(
+				domain.FieldError{Field: "login", Code: "required", Message: "must not be empty"})),
+			wantStatusCode: http.StatusBadRequest,
+			wantCode:       "invalid_params",
+			wantFields:     []ProblemFieldError{{Field: "login", Code: "required", Message: "must not be empty"}},
+		},
+		{
+			name:           "fail_account_locked",
+			err:            &domain.RetryError{Err: domain.ErrAccountLocked, RetryAfter: 1500 * time.Millisecond},
+			wantStatusCode: http.StatusTooManyRequests,
+			wantCode:       "account_locked",
+			wantRetryAfter: "2",
+		},
+		{
+			name:           "fail_status_override",
+			status:         http.StatusUnauthorized,
+			err:            domain.ErrInvalidCode,
+			wantStatusCode: http.StatusUnauthorized,
+			wantCode:       "invalid_code",
+		},
+		{
+			name:           "fail_internal",
+			err:            fmt.Errorf("failed to get user from storage: %w", context.DeadlineExceeded),
+			wantStatusCode: http.StatusInternalServerError,
+			wantCode:       "internal",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				WriteErrorStatus(w, r, tc.status, tc.err)
+			})
+			handler = RequestID(handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, "req1")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Fatalf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+			contentType := recorder.Result().Header.Get("Content-Type")
+			if contentType != ProblemContentType {
+				t.Errorf("expected content type: %s, got: %s", ProblemContentType, contentType)
+			}
+			retryAfter := recorder.Result().Header.Get("Retry-After")
+			if retryAfter != tc.wantRetryAfter {
+				t.Errorf("expected retry after: %q, got: %q", tc.wantRetryAfter, retryAfter)
+			}
+
+			var problem Problem
+			err := json.NewDecoder(recorder.Body).Decode(&problem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if problem.Status != tc.wantStatusCode || problem.Code != tc.wantCode || problem.RequestID != "req1" {
+				t.Errorf("expected problem: %d %s req1, got: %+v", tc.wantStatusCode, tc.wantCode, problem)
+			}
+			if len(problem.Errors) != len(tc.wantFields) {
+				t.Fatalf("expected field errors: %+v, got: %+v", tc.wantFields, problem.Errors)
+			}
+			for i := range tc.wantFields {
+				if problem.Errors[i] != tc.wantFields[i] {
+					t.Errorf("expected field error: %+v, got: %+v", tc.wantFields[i], problem.Errors[i])
+				}
+			}
+			if tc.wantCode == "internal" && problem.Detail != "unexpected error" {
+				t.Errorf("expected internal error details to be hidden, got: %q", problem.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"movies-auth/users/internal/logging"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// requestLog собирает данные о запросе, которые становятся известны внутренним обработчикам.
+type requestLog struct {
+	userID int
+}
+
+type requestLogKey struct{}
+
+// setUserID сообщает Logger, какой пользователь выполнил запрос.
+func setUserID(ctx context.Context, userID int) context.Context {
+	if rl, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		rl.userID = userID
+	}
+
+	return logging.With(ctx, slog.Int("user_id", userID))
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.size += n
+	return n, err
+}
+
+// Logger кладет в контекст логгер запроса с его идентификатором и
+// после обработки пишет строку о запросе. Должен стоять после RequestID.
+func Logger(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			rl := &requestLog{}
+			ctx := context.WithValue(r.Context(), requestLogKey{}, rl)
+			ctx = logging.WithLogger(ctx, logger.With(slog.String("request_id", GetRequestID(ctx))))
+			rec := &responseRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int("size", rec.size),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if rl.userID != 0 {
+				attrs = append(attrs, slog.Int("user_id", rl.userID))
+			}
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logging.FromContext(ctx).LogAttrs(ctx, level, "request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestLogger(t *testing.T) {
+	testCases := []struct {
+		name          string
+		requestID     string
+		wantRequestID bool
+	}{
+		{
+			name:          "success_request_id_from_header",
+			requestID:     "test-request-id",
+			wantRequestID: true,
+		},
+		{
+			name:      "success_request_id_generated",
+			requestID: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			r := chi.NewRouter()
+			r.Use(RequestID, Logger(logger))
+			r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte("body"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if tc.requestID != "" {
+				req.Header.Set(RequestIDHeader, tc.requestID)
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			respID := recorder.Header().Get(RequestIDHeader)
+			if respID == "" {
+				t.Fatal("expected request id in response, got: empty")
+			}
+			if tc.wantRequestID && respID != tc.requestID {
+				t.Errorf("expected request id: %v, got: %v", tc.requestID, respID)
+			}
+
+			var entry map[string]any
+			err := json.Unmarshal(buf.Bytes(), &entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry["request_id"] != respID {
+				t.Errorf("expected logged request id: %v, got: %v", respID, entry["request_id"])
+			}
+			if entry["route"] != "/users/{id}" {
+				t.Errorf("expected logged route: %v, got: %v", "/users/{id}", entry["route"])
+			}
+			if entry["status"] != float64(http.StatusTeapot) {
+				t.Errorf("expected logged status: %v, got: %v", http.StatusTeapot, entry["status"])
+			}
+			if entry["size"] != float64(len("body")) {
+				t.Errorf("expected logged size: %v, got: %v", len("body"), entry["size"])
+			}
+		})
+	}
+}
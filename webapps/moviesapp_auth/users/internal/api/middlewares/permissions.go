@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"net/http"
+)
+
+type accessKey struct{}
+
+func setAccess(ctx context.Context, session domain.Session) context.Context {
+	return context.WithValue(ctx, accessKey{}, domain.Access{Roles: session.Roles, Permissions: session.Permissions})
+}
+
+// GetAccess возвращает роли и права сессии, проверенной Auth.
+func GetAccess(ctx context.Context) (domain.Access, bool) {
+	access, ok := ctx.Value(accessKey{}).(domain.Access)
+
+	return access, ok
+}
+
+// RequirePermission пропускает запрос, только если у сессии есть право permission.
+// Должен стоять после Auth.
+func RequirePermission(permission string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access, ok := GetAccess(r.Context())
+			if !ok {
+				WriteError(w, r, domain.ErrUnauthorized)
+				return
+			}
+
+			if !access.HasPermission(permission) {
+				logging.FromContext(r.Context()).WarnContext(r.Context(), "permission denied",
+					slog.String("permission", permission), slog.Any("roles", access.Roles))
+				WriteError(w, r, domain.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
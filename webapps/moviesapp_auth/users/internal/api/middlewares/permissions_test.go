@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"movies-auth/users/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirePermission(t *testing.T) {
+	handler := RequirePermission(domain.PermissionUsersRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name           string
+		session        *domain.Session
+		wantStatusCode int
+	}{
+		{
+			name:           "fail_not_authenticated",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "fail_missing_permission",
+			session:        &domain.Session{Roles: []string{domain.RoleViewer}, Permissions: []string{domain.PermissionMoviesRead}},
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "success_allowed",
+			session: &domain.Session{Roles: []string{domain.RoleAdmin},
+				Permissions: []string{domain.PermissionMoviesRead, domain.PermissionUsersRead}},
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/list", nil)
+			if tc.session != nil {
+				req = req.WithContext(setAccess(req.Context(), *tc.session))
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Errorf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+		})
+	}
+}
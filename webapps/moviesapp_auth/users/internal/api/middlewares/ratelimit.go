@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) error
+}
+
+// RateLimit ограничивает число запросов с одного IP-адреса.
+// Адрес берется из соединения, заголовки прокси не учитываются.
+func RateLimit(limiter RateLimiter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := limiter.Allow(r.Context(), "ip:"+ClientIP(r))
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP возвращает адрес клиента из соединения.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
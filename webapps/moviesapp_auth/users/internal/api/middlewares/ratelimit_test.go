@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"movies-auth/users/internal/ratelimit"
+	"movies-auth/users/internal/storage/inmemory"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(inmemory.NewStorage(), 2, time.Minute)
+	handler := RateLimit(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name           string
+		remoteAddr     string
+		wantStatusCode int
+	}{
+		{
+			name:           "success_first_request",
+			remoteAddr:     "10.0.0.1:1000",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "success_other_port",
+			remoteAddr:     "10.0.0.1:2000",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "fail_limit_exceeded",
+			remoteAddr:     "10.0.0.1:3000",
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "success_other_address",
+			remoteAddr:     "10.0.0.2:1000",
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/login", nil)
+			req.RemoteAddr = tc.remoteAddr
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Result().StatusCode != tc.wantStatusCode {
+				t.Fatalf("expected status code: %d, got: %d", tc.wantStatusCode, recorder.Result().StatusCode)
+			}
+			if tc.wantStatusCode != http.StatusTooManyRequests {
+				return
+			}
+
+			// лимит сбрасывается с окном, поэтому ждать нужно не больше минуты
+			retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+			if err != nil || retryAfter < 1 || retryAfter > 60 {
+				t.Errorf("expected retry after: 1-60 seconds, got: %q", recorder.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
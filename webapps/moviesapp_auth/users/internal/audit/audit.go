@@ -0,0 +1,77 @@
+// Package audit записывает журнал событий безопасности: регистрации, входы, выходы,
+// отклоненные сессии, смены паролей и действия администраторов.
+// Данные запроса (адрес, User-Agent, идентификатор) и исполнитель берутся из контекста,
+// поэтому сервисы указывают только действие, объект и результат.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"time"
+)
+
+type Store interface {
+	InsertAuditEvent(ctx context.Context, event domain.AuditEvent) (domain.AuditEvent, error)
+}
+
+// Request - данные HTTP запроса, попадающие в записи журнала.
+type Request struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type requestKey struct{}
+
+type actorKey struct{}
+
+// WithRequest возвращает контекст с данными запроса.
+func WithRequest(ctx context.Context, request Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+// WithActor возвращает контекст с пользователем, от имени которого выполняется запрос.
+func WithActor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorID возвращает пользователя, от имени которого выполняется запрос.
+func ActorID(ctx context.Context) int {
+	userID, _ := ctx.Value(actorKey{}).(int)
+
+	return userID
+}
+
+type Recorder struct {
+	store Store
+}
+
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{store: store}
+}
+
+// Record дополняет событие данными запроса и исполнителем из контекста и сохраняет его.
+// Ошибка записи только логируется: недоступность журнала не должна прерывать вход пользователей.
+func (r *Recorder) Record(ctx context.Context, event domain.AuditEvent) {
+	if event.ActorID == 0 {
+		event.ActorID = ActorID(ctx)
+	}
+	if request, ok := ctx.Value(requestKey{}).(Request); ok {
+		event.IP = request.IP
+		event.UserAgent = request.UserAgent
+		event.RequestID = request.RequestID
+	}
+	event.UserAgent = domain.TruncateUserAgent(event.UserAgent)
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	// запись нужна и для отмененного запроса, например если клиент закрыл соединение после входа
+	_, err := r.store.InsertAuditEvent(context.WithoutCancel(ctx), event)
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "failed to record audit event",
+			slog.String("action", event.Action), slog.String("outcome", event.Outcome), slog.Any("error", err))
+	}
+}
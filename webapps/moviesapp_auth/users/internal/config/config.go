@@ -1,15 +1,36 @@
 package config
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 type Config struct {
-	ServerConfig ServerConfig `mapstructure:"server"`
-	DBConfig     DBConfig     `mapstructure:"db"`
+	ServerConfig  ServerConfig  `mapstructure:"server"`
+	Storage       Storage       `mapstructure:"storage"`
+	DBConfig      DBConfig      `mapstructure:"db"`
+	Passwords     Passwords     `mapstructure:"passwords"`
+	Sessions      Sessions      `mapstructure:"sessions"`
+	Movies        Movies        `mapstructure:"movies"`
+	Log           Log           `mapstructure:"log"`
+	Notifications Notifications `mapstructure:"notifications"`
+	PassCheck     PassCheck     `mapstructure:"passcheck"`
+	Login         Login         `mapstructure:"login"`
+	Tokens        Tokens        `mapstructure:"tokens"`
+	Accounts      Accounts      `mapstructure:"accounts"`
 }
 
 type ServerConfig struct {
 	Host string `mapstructure:"host"`
 	Port int    `mapstructure:"port"`
+	// ShutdownTimeout - сколько ждать остановки сервера и воркеров
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+// Storage - тип хранилища: postgres (по умолчанию) или inmemory.
+type Storage struct {
+	Type string `mapstructure:"type"`
 }
 
 type DBConfig struct {
@@ -18,6 +39,128 @@ type DBConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	DBName   string `mapstructure:"dbname"`
+	// AutoMigrate применяет миграции при запуске сервера
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+}
+
+// Passwords - алгоритм хэширования и политика паролей. Нулевой MaxAge - пароли не истекают.
+type Passwords struct {
+	Algorithm     string        `mapstructure:"algorithm"`
+	MinLength     int           `mapstructure:"min_length"`
+	RequireUpper  bool          `mapstructure:"require_upper"`
+	RequireLower  bool          `mapstructure:"require_lower"`
+	RequireDigit  bool          `mapstructure:"require_digit"`
+	RequireSymbol bool          `mapstructure:"require_symbol"`
+	MaxAge        time.Duration `mapstructure:"max_age"`
+	History       int           `mapstructure:"history"`
+}
+
+// Sessions - срок жизни сессий и режим выдачи: cookie (по умолчанию) с ключом сессии
+// или token с access и refresh токенами.
+type Sessions struct {
+	Lifetime     time.Duration `mapstructure:"lifetime"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	ReapInterval time.Duration `mapstructure:"reap_interval"`
+	Mode         string        `mapstructure:"mode"`
+}
+
+// Tokens - ключи подписи access токенов. SigningKey - идентификатор ключа, которым подписываются
+// новые токены, остальные ключи только проверяют ранее выданные токены.
+// AccessTTL ограничивает, сколько отозванный токен принимают другие реплики и сервис movies.
+type Tokens struct {
+	Issuer     string        `mapstructure:"issuer"`
+	AccessTTL  time.Duration `mapstructure:"access_ttl"`
+	SigningKey string        `mapstructure:"signing_key"`
+	Keys       []TokenKey    `mapstructure:"keys"`
+}
+
+// TokenKey - ключ HS256 или EdDSA. Secret - секрет HS256 или seed Ed25519 в base64.
+type TokenKey struct {
+	ID        string `mapstructure:"id"`
+	Algorithm string `mapstructure:"algorithm"`
+	Secret    string `mapstructure:"secret"`
+}
+
+// Movies - настройки уведомления сервиса movies о завершенных сессиях.
+// Пустой BaseURL отключает уведомления. InternalToken можно задать переменной окружения INTERNAL_TOKEN,
+// допустимость его значения проверяет принимающий сервис movies.
+type Movies struct {
+	BaseURL       string        `mapstructure:"base_url"`
+	InternalToken string        `mapstructure:"internal_token"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+}
+
+// Log - уровень (debug, info, warn, error) и формат (json, text) логов.
+type Log struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+// Accounts - подтверждение email и сброс пароля. Письма с токенами уходят через канал уведомлений
+// MailerChannel, пустой канал отключает оба сценария. RequireVerified запрещает вход с неподтвержденным email.
+type Accounts struct {
+	MailerChannel   string        `mapstructure:"mailer_channel"`
+	RequireVerified bool          `mapstructure:"require_verified"`
+	VerificationTTL time.Duration `mapstructure:"verification_ttl"`
+	ResetTTL        time.Duration `mapstructure:"reset_ttl"`
+}
+
+// Notifications - каналы уведомлений. Канал включен, если заданы его настройки.
+type Notifications struct {
+	DefaultChannel string  `mapstructure:"default_channel"`
+	SMTP           SMTP    `mapstructure:"smtp"`
+	Webhook        Webhook `mapstructure:"webhook"`
+	LogFile        LogFile `mapstructure:"logfile"`
+}
+
+type SMTP struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+type Webhook struct {
+	URL     string        `mapstructure:"url"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type LogFile struct {
+	Path string `mapstructure:"path"`
+}
+
+// PassCheck - настройки воркера уведомлений об истекших паролях.
+type PassCheck struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Interval     time.Duration `mapstructure:"interval"`
+	WorkersCount int           `mapstructure:"workers_count"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	BaseDelay    time.Duration `mapstructure:"base_delay"`
+	MaxDelay     time.Duration `mapstructure:"max_delay"`
+}
+
+// Login - защита входа от перебора паролей. Попытки входа ограничиваются по логину и по IP
+// в окне Window, после MaxFailures неудачных попыток подряд учетная запись блокируется на LockoutDuration.
+// Нулевые значения отключают соответствующее ограничение.
+// TOTPIssuer - название сервиса в приложении-аутентификаторе, ChallengeTTL - срок второго шага входа.
+type Login struct {
+	LoginLimit      int           `mapstructure:"login_limit"`
+	IPLimit         int           `mapstructure:"ip_limit"`
+	Window          time.Duration `mapstructure:"window"`
+	MaxFailures     int           `mapstructure:"max_failures"`
+	LockoutDuration time.Duration `mapstructure:"lockout_duration"`
+	TOTPIssuer      string        `mapstructure:"totp_issuer"`
+	ChallengeTTL    time.Duration `mapstructure:"challenge_ttl"`
+}
+
+// Validate проверяет, что для включенных уведомлений задан общий с сервисом movies токен.
+func (m Movies) Validate() error {
+	if m.BaseURL != "" && m.InternalToken == "" {
+		return errors.New("movies.internal_token is required when movies.base_url is set")
+	}
+
+	return nil
 }
 
 func (dbConf DBConfig) ConnectionString() string {
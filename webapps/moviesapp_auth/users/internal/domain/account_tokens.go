@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+const (
+	AccountTokenVerifyEmail   = "verify_email"
+	AccountTokenResetPassword = "reset_password"
+	// AccountTokenLoginChallenge выдается после верного пароля, если включен второй фактор
+	AccountTokenLoginChallenge = "login_challenge"
+)
+
+// AccountToken - одноразовый токен подтверждения email, сброса пароля или входа со вторым фактором.
+// Сам токен отдается пользователю, в хранилище попадает только его хэш.
+// Email - адрес, на который отправлен токен: подтверждение действует, только пока адрес не изменился.
+type AccountToken struct {
+	ID        int
+	UserID    int
+	Purpose   string
+	TokenHash string
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Действия журнала аудита.
+const (
+	AuditRegister             = "register"
+	AuditLogin                = "login"
+	AuditLoginSecondFactor    = "login_2fa"
+	AuditLogout               = "logout"
+	AuditSessionInvalid       = "session_invalid"
+	AuditSessionRefresh       = "session_refresh"
+	AuditSessionRevoke        = "session_revoke"
+	AuditPasswordChange       = "password_change"
+	AuditPasswordReset        = "password_reset"
+	AuditPasswordNotification = "password_notification"
+	AuditEmailVerify          = "email_verify"
+	AuditTwoFactorEnable      = "2fa_enable"
+	AuditTwoFactorDisable     = "2fa_disable"
+	AuditUserUpdate           = "user_update"
+	AuditUserDisable          = "user_disable"
+	AuditUserEnable           = "user_enable"
+	AuditUserDelete           = "user_delete"
+	AuditRolesChange          = "roles_change"
+)
+
+// Результаты действий. AuditChallenge - пароль верный, но вход ждет второй фактор.
+const (
+	AuditSuccess   = "success"
+	AuditFailure   = "failure"
+	AuditChallenge = "challenge"
+)
+
+// AuditActorSystem - исполнитель действий, которые сервис выполняет сам, например рассылки воркеров.
+const AuditActorSystem = "system"
+
+// AuditEvent - запись журнала аудита. Записи только добавляются и не меняются.
+// ActorID - пользователь, выполнивший действие, 0 если он не установлен, например при неудачном входе.
+// Actor - логин, с которым выполнялось действие, если он известен. Target - объект действия вида "user:1" или "session:2".
+// Reason уточняет причину неудачи.
+type AuditEvent struct {
+	ID        int64
+	ActorID   int
+	Actor     string
+	Action    string
+	Target    string
+	IP        string
+	UserAgent string
+	RequestID string
+	Outcome   string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// AuditFilter - условия выборки журнала. Нулевые значения не ограничивают выборку.
+// From включается в диапазон, To - нет. Записи возвращаются от старых к новым, начиная после AfterID.
+type AuditFilter struct {
+	From    time.Time
+	To      time.Time
+	ActorID int
+	Actor   string
+	Action  string
+	AfterID int64
+	Limit   int
+}
+
+type AuditPage struct {
+	Events     []AuditEvent
+	NextCursor string
+}
+
+func UserTarget(id int) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+func SessionTarget(id int) string {
+	return fmt.Sprintf("session:%d", id)
+}
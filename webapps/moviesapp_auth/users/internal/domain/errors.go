@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"strings"
+)
+
+// ErrorKind - категория ошибки. По ней API выбирает код ответа, поэтому сервисам достаточно
+// обернуть ошибку нужной категории через %w.
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	// KindLocked - действие временно запрещено: превышен лимит попыток или учетная запись заблокирована
+	KindLocked
+)
+
+// Error - ошибка предметной области. Code - стабильный код для клиентов API,
+// он не меняется вместе с текстом ошибки.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(kind ErrorKind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+var ErrNotFound = newError(KindNotFound, "not_found", "not found")
+var ErrConflict = newError(KindConflict, "already_exists", "already exists")
+var ErrInvalidPassword = newError(KindUnauthorized, "invalid_credentials", "invalid login or password")
+var ErrSessionExpired = newError(KindUnauthorized, "session_expired", "session expired")
+var ErrInvalidParams = newError(KindValidation, "invalid_params", "invalid params")
+var ErrWeakPassword = newError(KindValidation, "weak_password", "weak password")
+var ErrPasswordReused = newError(KindValidation, "password_reused", "password was used recently")
+var ErrPasswordExpired = newError(KindForbidden, "password_expired", "password expired")
+var ErrInvalidToken = newError(KindValidation, "invalid_token", "invalid token")
+var ErrTokenReused = newError(KindUnauthorized, "token_reused", "refresh token reused")
+var ErrTooManyRequests = newError(KindLocked, "too_many_requests", "too many requests")
+var ErrAccountLocked = newError(KindLocked, "account_locked", "account locked")
+var ErrUserDisabled = newError(KindForbidden, "user_disabled", "user disabled")
+var ErrEmailNotVerified = newError(KindForbidden, "email_not_verified", "email not verified")
+var ErrSecondFactorRequired = newError(KindUnauthorized, "second_factor_required", "second factor required")
+var ErrInvalidCode = newError(KindValidation, "invalid_code", "invalid one-time code")
+var ErrUnauthorized = newError(KindUnauthorized, "unauthorized", "authentication required")
+var ErrForbidden = newError(KindForbidden, "permission_denied", "permission denied")
+
+// ErrLeaseLost - задание очереди захвачено другим обработчиком после истечения lease.
+var ErrLeaseLost = newError(KindConflict, "lease_lost", "notification lease lost")
+
+// FieldError - ошибка значения одного поля запроса. Code - стабильный код правила, например "required".
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationError - ошибка входных данных с подробностями по полям.
+// Err задает категорию и код ошибки, по умолчанию ErrInvalidParams.
+type ValidationError struct {
+	Err    error
+	Fields []FieldError
+}
+
+func NewValidationError(fields ...FieldError) *ValidationError {
+	return &ValidationError{Err: ErrInvalidParams, Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Unwrap().Error())
+	for i, f := range e.Fields {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(f.Field + " " + f.Message)
+	}
+
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() error {
+	if e.Err == nil {
+		return ErrInvalidParams
+	}
+
+	return e.Err
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NotificationJobPending    = "pending"
+	NotificationJobProcessing = "processing"
+	NotificationJobDone       = "done"
+	// NotificationJobDead - задание исчерпало попытки доставки и больше не выполняется
+	NotificationJobDead = "dead"
+)
+
+// NotificationJob - задание на доставку уведомления в очереди (outbox).
+// IdempotencyKey уникален, повторная постановка того же уведомления игнорируется.
+// LockedUntil - до какого момента задание закреплено за обработчиком после захвата,
+// ClaimToken - токен захвата, без которого задание нельзя завершить, вернуть в очередь или отложить в dead.
+type NotificationJob struct {
+	ID             int
+	UserID         int
+	Kind           string
+	IdempotencyKey string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LockedUntil    time.Time
+	ClaimToken     uuid.UUID
+	LastError      string
+	CreatedAt      time.Time
+	// User заполняется при захвате задания
+	User User
+}
@@ -0,0 +1,45 @@
+package domain
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// DefaultRole назначается пользователю при регистрации.
+const DefaultRole = RoleViewer
+
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersWrite  = "users:write"
+	PermissionMoviesRead  = "movies:read"
+	PermissionMoviesWrite = "movies:write"
+	PermissionAuditRead   = "audit:read"
+)
+
+// DefaultRolePermissions - роли и их права, которые создают миграции 0008_rbac и 0013_audit_events.
+// Хранилище в памяти использует их вместо таблиц role_permissions.
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin:  {PermissionAuditRead, PermissionMoviesRead, PermissionMoviesWrite, PermissionUsersRead, PermissionUsersWrite},
+	RoleEditor: {PermissionMoviesRead, PermissionMoviesWrite},
+	RoleViewer: {PermissionMoviesRead},
+}
+
+// Access - роли пользователя и объединение их прав, оба списка отсортированы.
+// Disabled - пользователь отключен администратором и не может пользоваться сессиями.
+type Access struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Disabled    bool     `json:"-"`
+}
+
+// HasPermission сообщает, входит ли permission в права.
+func (a Access) HasPermission(permission string) bool {
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
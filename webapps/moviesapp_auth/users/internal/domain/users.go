@@ -1,7 +1,8 @@
 package domain
 
 import (
-	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,16 +12,163 @@ type User struct {
 	ID               int       `json:"id"`
 	Login            string    `json:"login"`
 	Password         string    `json:"password"`
+	PasswordAlgo     string    `json:"-"`
 	PasswordExpires  time.Time `json:"passwordExpires"`
 	NotificationSent bool      `json:"notificationSent"`
+	Email            string    `json:"email"`
+	// EmailVerifiedAt - когда пользователь подтвердил email, нулевое значение у неподтвержденных
+	EmailVerifiedAt time.Time `json:"emailVerifiedAt"`
+	// NotificationChannel - предпочитаемый канал уведомлений, пустой означает канал по умолчанию
+	NotificationChannel string `json:"notificationChannel"`
+	// FailedLogins - неудачные попытки входа подряд с последнего успешного входа или блокировки
+	FailedLogins int       `json:"-"`
+	LockedUntil  time.Time `json:"lockedUntil"`
+	// DisabledAt - когда администратор отключил пользователя, нулевое значение у активных.
+	// Удаление мягкое: удаленный пользователь не находится, но его логин остается занятым
+	DisabledAt time.Time `json:"disabledAt"`
+	DeletedAt  time.Time `json:"-"`
+	// TOTPSecret - секрет второго фактора, TOTPEnabledAt - когда второй фактор включен.
+	// Секрет без TOTPEnabledAt означает незавершенное подключение. TOTPLastCounter - шаг последнего
+	// принятого кода, более ранние коды не принимаются
+	TOTPSecret      string    `json:"-"`
+	TOTPEnabledAt   time.Time `json:"-"`
+	TOTPLastCounter int64     `json:"-"`
+	// Roles заполняется только там, где роли нужны явно, например в API администратора
+	Roles []string `json:"roles"`
 }
 
+// TOTPEnrollment - секрет второго фактора для приложения-аутентификатора: вручную или через otpauth URI.
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// TwoFactorStatus - состояние второго фактора пользователя. Pending - подключение начато, но не подтверждено кодом.
+type TwoFactorStatus struct {
+	Enabled           bool
+	Pending           bool
+	RecoveryCodesLeft int
+}
+
+// UserUpdate - изменения пользователя администратором, nil поля не меняются.
+type UserUpdate struct {
+	Email               *string
+	NotificationChannel *string
+	Roles               []string
+}
+
+const (
+	NotificationChannelEmail   = "email"
+	NotificationChannelWebhook = "webhook"
+	NotificationChannelLogFile = "logfile"
+)
+
 type Session struct {
-	ID        int       `json:"id"`
-	Key       uuid.UUID `json:"key"`
-	UserID    int       `json:"userId"`
-	StartedAt time.Time `json:"startedAt"`
+	ID         int       `json:"id"`
+	Key        uuid.UUID `json:"key"`
+	UserID     int       `json:"userId"`
+	StartedAt  time.Time `json:"startedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	// UserAgent и IP - устройство, с которого начата сессия
+	UserAgent string `json:"userAgent"`
+	IP        string `json:"ip"`
+	// RefreshTokenHash - хэш действующего refresh токена, пустой в режиме cookie
+	RefreshTokenHash string `json:"-"`
+	// AccessToken и RefreshToken выдаются клиенту в режиме токенов и не хранятся
+	AccessToken     string    `json:"-"`
+	AccessExpiresAt time.Time `json:"-"`
+	RefreshToken    string    `json:"-"`
+	// Roles и Permissions загружаются при проверке сессии и попадают в access токен
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// Device - клиент, открывающий сессию.
+type Device struct {
+	UserAgent string
+	IP        string
 }
 
-var ErrNotFound = errors.New("not found")
-var ErrConflict = errors.New("already exists")
+// MaxUserAgentLength ограничивает длину сохраняемого User-Agent, заголовок задает клиент.
+const MaxUserAgentLength = 512
+
+// TruncateUserAgent обрезает userAgent до MaxUserAgentLength байт, не разрывая символы UTF-8.
+func TruncateUserAgent(userAgent string) string {
+	if len(userAgent) <= MaxUserAgentLength {
+		return userAgent
+	}
+
+	return strings.ToValidUTF8(userAgent[:MaxUserAgentLength], "")
+}
+
+const (
+	UsersSortByID              = "id"
+	UsersSortByLogin           = "login"
+	UsersSortByPasswordExpires = "passwordExpires"
+)
+
+// UsersListParams - параметры выборки страницы пользователей.
+// After - последний пользователь предыдущей страницы, nil для первой страницы.
+type UsersListParams struct {
+	LoginPrefix string
+	SortBy      string
+	Desc        bool
+	Limit       int
+	After       *User
+}
+
+// PasswordHistoryEntry - один из установленных пользователем паролей.
+type PasswordHistoryEntry struct {
+	Hash      string
+	Algorithm string
+	CreatedAt time.Time
+}
+
+const (
+	LockoutEventLocked   = "locked"
+	LockoutEventUnlocked = "unlocked"
+)
+
+// LockoutEvent - запись о блокировке или разблокировке учетной записи.
+type LockoutEvent struct {
+	ID        int
+	UserID    int
+	Event     string
+	Reason    string
+	CreatedAt time.Time
+}
+
+type UsersPage struct {
+	Users      []User
+	NextCursor string
+}
+
+// RetryError - ошибка, после которой запрос можно повторить не раньше, чем через RetryAfter.
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err, e.RetryAfter)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// ChallengeError - пароль верный, но для входа нужен второй фактор. Token вместе с кодом
+// второго фактора обменивается на сессию до ExpiresAt.
+type ChallengeError struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (e *ChallengeError) Error() string {
+	return ErrSecondFactorRequired.Error()
+}
+
+func (e *ChallengeError) Unwrap() error {
+	return ErrSecondFactorRequired
+}
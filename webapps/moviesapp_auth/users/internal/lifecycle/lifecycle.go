@@ -0,0 +1,157 @@
+// Package lifecycle запускает компоненты сервера и останавливает их в правильном порядке.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+var ErrStoppedUnexpectedly = errors.New("component stopped unexpectedly")
+
+const DefaultShutdownTimeout = 30 * time.Second
+
+type component struct {
+	name string
+	// run блокируется, пока компонент работает; nil для ресурсов, которые нужно только закрыть
+	run  func() error
+	stop func(ctx context.Context) error
+	done chan error
+}
+
+// Manager запускает компоненты и при остановке завершает их в порядке, обратном добавлению:
+// сначала добавляются ресурсы (хранилище), затем воркеры и в конце HTTP-сервер,
+// тогда сервер дорабатывает запросы раньше, чем закроются воркеры и хранилище.
+type Manager struct {
+	shutdownTimeout time.Duration
+	components      []*component
+}
+
+// NewManager создает менеджер, ожидающий остановки компонентов не дольше shutdownTimeout.
+func NewManager(shutdownTimeout time.Duration) *Manager {
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+
+	return &Manager{
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+// AddServer добавляет HTTP-сервер. При остановке он перестает принимать соединения и дожидается текущих запросов.
+func (m *Manager) AddServer(name string, srv *http.Server) {
+	m.components = append(m.components, &component{
+		name: name,
+		run: func() error {
+			err := srv.ListenAndServe()
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		},
+		stop: srv.Shutdown,
+	})
+}
+
+// AddWorker добавляет фоновый воркер. run должна вернуться после отмены переданного контекста;
+// ошибка, возвращенная после отмены, считается штатной остановкой, если это stopErr.
+func (m *Manager) AddWorker(name string, run func(ctx context.Context) error, stopErr error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.components = append(m.components, &component{
+		name: name,
+		run: func() error {
+			err := run(ctx)
+			if ctx.Err() != nil && (err == nil || errors.Is(err, stopErr)) {
+				return nil
+			}
+			return err
+		},
+		stop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
+
+// AddCloser добавляет ресурс, который нужно закрыть при остановке.
+func (m *Manager) AddCloser(name string, close func() error) {
+	m.components = append(m.components, &component{
+		name: name,
+		stop: func(context.Context) error {
+			return close()
+		},
+	})
+}
+
+// Run запускает компоненты и ждет отмены ctx или неожиданной остановки любого из них,
+// после чего останавливает все компоненты. Возвращает ошибки всех компонентов.
+func (m *Manager) Run(ctx context.Context) error {
+	failed := make(chan *component, len(m.components))
+	for _, c := range m.components {
+		if c.run == nil {
+			continue
+		}
+
+		c.done = make(chan error, 1)
+		go func(c *component) {
+			err := c.run()
+			c.done <- err
+			failed <- c
+		}(c)
+		slog.Info("component started", slog.String("component", c.name))
+	}
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+	case c := <-failed:
+		// компонент остановился сам, до начала остановки сервера
+		err := <-c.done
+		if err == nil {
+			err = ErrStoppedUnexpectedly
+		}
+		c.done = nil
+		slog.Error("component failed", slog.String("component", c.name), slog.Any("error", err))
+		errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+	}
+
+	return errors.Join(append(errs, m.shutdown()...)...)
+}
+
+func (m *Manager) shutdown() []error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(m.components) - 1; i >= 0; i-- {
+		c := m.components[i]
+		err := m.stop(ctx, c)
+		if err != nil {
+			slog.Error("component shutdown failed", slog.String("component", c.name), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		slog.Info("component stopped", slog.String("component", c.name))
+	}
+
+	return errs
+}
+
+func (m *Manager) stop(ctx context.Context, c *component) error {
+	stopErr := c.stop(ctx)
+
+	// компонент уже завершился или его нечего ждать
+	if c.done == nil {
+		return stopErr
+	}
+
+	select {
+	case err := <-c.done:
+		return errors.Join(stopErr, err)
+	case <-ctx.Done():
+		return errors.Join(stopErr, fmt.Errorf("waiting for stop: %w", ctx.Err()))
+	}
+}
@@ -0,0 +1,83 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"movies-auth/users/internal/lifecycle"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errStopped = errors.New("stopped")
+
+type stopRecorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *stopRecorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.order = append(r.order, name)
+}
+
+func (r *stopRecorder) worker(name string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		r.record(name)
+		return errStopped
+	}
+}
+
+func TestManagerShutdownOrder(t *testing.T) {
+	rec := &stopRecorder{}
+	m := lifecycle.NewManager(time.Second)
+	m.AddCloser("storage", func() error {
+		rec.record("storage")
+		return nil
+	})
+	m.AddWorker("reaper", rec.worker("reaper"), errStopped)
+	m.AddWorker("passcheck", rec.worker("passcheck"), errStopped)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := m.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "passcheck,reaper,storage"
+	if got := strings.Join(rec.order, ","); got != want {
+		t.Errorf("expected stop order: %s, got: %s", want, got)
+	}
+}
+
+func TestManagerReportsErrors(t *testing.T) {
+	rec := &stopRecorder{}
+	m := lifecycle.NewManager(time.Second)
+	m.AddCloser("storage", func() error {
+		return errors.New("close failed")
+	})
+	m.AddWorker("reaper", rec.worker("reaper"), errStopped)
+	m.AddWorker("broken", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, errStopped)
+
+	err := m.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, want := range []string{"broken: boom", "storage: close failed"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got: %v", want, err)
+		}
+	}
+	if len(rec.order) != 1 || rec.order[0] != "reaper" {
+		t.Errorf("expected remaining workers stopped, got: %v", rec.order)
+	}
+}
@@ -0,0 +1,361 @@
+// Package metrics - минимальная реализация метрик в текстовом формате Prometheus.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets - границы гистограмм по умолчанию, в секундах.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+// Registry хранит метрики и отдает их в текстовом формате Prometheus.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		err := c.write(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add увеличивает счетчик, отрицательные значения недопустимы.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.value
+}
+
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(v float64) {
+	g.mu.Lock()
+	g.value += v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.value
+}
+
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.count
+}
+
+// family - метрика со всеми своими сериями, различающимися значениями меток.
+type family[T any] struct {
+	name     string
+	help     string
+	typ      string
+	labels   []string
+	newValue func() *T
+	writeFn  func(w io.Writer, name string, labels string, value *T) error
+
+	mu     sync.Mutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	labelValues []string
+	value       *T
+}
+
+func (f *family[T]) with(values []string) *T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series[T]{labelValues: append([]string(nil), values...), value: f.newValue()}
+		f.series[key] = s
+	}
+
+	return s.value
+}
+
+func (f *family[T]) write(w io.Writer) error {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	all := make([]*series[T], 0, len(keys))
+	for _, k := range keys {
+		all = append(all, f.series[k])
+	}
+	f.mu.Unlock()
+
+	if len(all) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+	if err != nil {
+		return err
+	}
+	for _, s := range all {
+		err = f.writeFn(w, f.name, formatLabels(f.labels, s.labelValues), s.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type CounterVec struct {
+	f *family[Counter]
+}
+
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	f := &family[Counter]{
+		name:     name,
+		help:     help,
+		typ:      "counter",
+		labels:   labels,
+		newValue: func() *Counter { return &Counter{} },
+		writeFn: func(w io.Writer, name string, labels string, c *Counter) error {
+			_, err := fmt.Fprintf(w, "%s%s %s\n", name, braces(labels), formatFloat(c.Value()))
+			return err
+		},
+		series: make(map[string]*series[Counter]),
+	}
+	r.register(f)
+
+	return &CounterVec{f: f}
+}
+
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.f.with(labelValues)
+}
+
+func (r *Registry) NewCounter(name string, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+type GaugeVec struct {
+	f *family[Gauge]
+}
+
+func (r *Registry) NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	f := &family[Gauge]{
+		name:     name,
+		help:     help,
+		typ:      "gauge",
+		labels:   labels,
+		newValue: func() *Gauge { return &Gauge{} },
+		writeFn: func(w io.Writer, name string, labels string, g *Gauge) error {
+			_, err := fmt.Fprintf(w, "%s%s %s\n", name, braces(labels), formatFloat(g.Value()))
+			return err
+		},
+		series: make(map[string]*series[Gauge]),
+	}
+	r.register(f)
+
+	return &GaugeVec{f: f}
+}
+
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.f.with(labelValues)
+}
+
+func (r *Registry) NewGauge(name string, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+type HistogramVec struct {
+	f *family[Histogram]
+}
+
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	f := &family[Histogram]{
+		name:   name,
+		help:   help,
+		typ:    "histogram",
+		labels: labels,
+		newValue: func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		},
+		writeFn: writeHistogram,
+		series:  make(map[string]*series[Histogram]),
+	}
+	r.register(f)
+
+	return &HistogramVec{f: f}
+}
+
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.f.with(labelValues)
+}
+
+func writeHistogram(w io.Writer, name string, labels string, h *Histogram) error {
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, upper := range h.buckets {
+		_, err := fmt.Fprintf(w, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, formatFloat(upper), counts[i])
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n%s_sum%s %s\n%s_count%s %d\n",
+		name, labels, sep, count, name, braces(labels), formatFloat(sum), name, braces(labels), count)
+
+	return err
+}
+
+func formatLabels(names []string, values []string) string {
+	pairs := make([]string, len(names))
+	for i := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", names[i], escapeLabel(values[i]))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
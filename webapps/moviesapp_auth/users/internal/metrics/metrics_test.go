@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounterVec("test_requests_total", "Test requests.", "code")
+	gauge := reg.NewGauge("test_queue_depth", "Test queue.")
+	histogram := reg.NewHistogramVec("test_duration_seconds", "Test duration.", []float64{0.1, 1}, "route")
+
+	counter.With("200").Inc()
+	counter.With("200").Inc()
+	counter.With(`5"0\0`).Add(3)
+	gauge.Set(7)
+	histogram.With("/users").Observe(0.05)
+	histogram.With("/users").Observe(0.5)
+	histogram.With("/users").Observe(5)
+
+	var buf bytes.Buffer
+	err := reg.Write(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP test_requests_total Test requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 2
+test_requests_total{code="5\"0\\0"} 3
+# HELP test_queue_depth Test queue.
+# TYPE test_queue_depth gauge
+test_queue_depth 7
+# HELP test_duration_seconds Test duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/users",le="0.1"} 1
+test_duration_seconds_bucket{route="/users",le="1"} 2
+test_duration_seconds_bucket{route="/users",le="+Inf"} 3
+test_duration_seconds_sum{route="/users"} 5.55
+test_duration_seconds_count{route="/users"} 3
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestLabelsMismatch(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounterVec("test_total", "Test.", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on label count mismatch")
+		}
+	}()
+	counter.With("only-one")
+}
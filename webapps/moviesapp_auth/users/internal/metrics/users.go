@@ -0,0 +1,45 @@
+package metrics
+
+import "net/http"
+
+var registry = NewRegistry()
+
+// Handler отдает метрики сервиса users.
+func Handler() http.Handler {
+	return registry.Handler()
+}
+
+// Результаты для меток result.
+const (
+	ResultSuccess   = "success"
+	ResultFailure   = "failure"
+	ResultExpired   = "expired"
+	ResultThrottled = "throttled"
+	// ResultChallenge - пароль верный, вход продолжится после второго фактора
+	ResultChallenge = "challenge"
+	ResultRetry     = "retry"
+	ResultDead      = "dead"
+)
+
+var (
+	HTTPRequestDuration = registry.NewHistogramVec("users_http_request_duration_seconds",
+		"HTTP request latency by route.", DefBuckets, "method", "route", "status")
+
+	AuthAttempts = registry.NewCounterVec("users_auth_attempts_total",
+		"Authentication attempts by operation and result.", "operation", "result")
+
+	SessionValidations = registry.NewCounterVec("users_session_validations_total",
+		"Session validations by result.", "result")
+
+	DBQueryDuration = registry.NewHistogramVec("users_db_query_duration_seconds",
+		"Database query latency.", DefBuckets, "operation", "status")
+
+	PassCheckQueueDepth = registry.NewGauge("users_passcheck_queue_depth",
+		"Notification jobs ready for delivery at the start of a pass check run.")
+
+	AccountLockouts = registry.NewCounter("users_account_lockouts_total",
+		"Accounts locked after too many failed login attempts.")
+
+	NotificationsSent = registry.NewCounterVec("users_notifications_sent_total",
+		"Password expiration notifications by result.", "result")
+)
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogFileNotifier дописывает уведомления строками в файл.
+type LogFileNotifier struct {
+	mu        sync.Mutex
+	file      *os.File
+	templates templates
+}
+
+func NewLogFileNotifier(path string) (*LogFileNotifier, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open notifications log: %w", err)
+	}
+
+	return &LogFileNotifier{
+		file:      file,
+		templates: loadTemplates("logfile"),
+	}, nil
+}
+
+func (l *LogFileNotifier) Notify(ctx context.Context, n Notification) error {
+	_, line, err := l.templates.render(n)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = fmt.Fprintf(l.file, "%s key=%s %s\n", time.Now().UTC().Format(time.RFC3339), n.IdempotencyKey, line)
+
+	return err
+}
+
+func (l *LogFileNotifier) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,168 @@
+package notifications_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/notifications"
+	"movies-auth/users/internal/tests/notifytest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var expiredUser = domain.User{
+	ID:              7,
+	Login:           "user1",
+	Email:           "user1@example.com",
+	PasswordExpires: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+func passwordExpired(user domain.User) notifications.Notification {
+	return notifications.Notification{Kind: notifications.KindPasswordExpired, User: user}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	server := notifytest.NewSMTPServer(t)
+	notifier := notifications.NewSMTPNotifier(server.Host, server.Port, "", "", "noreply@example.com")
+
+	err := notifier.Notify(context.Background(), passwordExpired(expiredUser))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("expected 1 mail, got: %d", len(mails))
+	}
+	mail := mails[0]
+	if mail.From != "noreply@example.com" || len(mail.To) != 1 || mail.To[0] != "user1@example.com" {
+		t.Errorf("expected mail from noreply@example.com to user1@example.com, got: %+v", mail)
+	}
+	if !strings.Contains(mail.Data, "Subject: Your password has expired") {
+		t.Errorf("expected subject in mail, got: %s", mail.Data)
+	}
+	if !strings.Contains(mail.Data, "Your password expired on 2024-01-02") {
+		t.Errorf("expected rendered body in mail, got: %s", mail.Data)
+	}
+
+	noEmail := expiredUser
+	noEmail.Email = ""
+	err = notifier.Notify(context.Background(), passwordExpired(noEmail))
+	if !errors.Is(err, notifications.ErrNoAddress) {
+		t.Errorf("expected error: %v, got: %v", notifications.ErrNoAddress, err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		wantErr    bool
+	}{
+		{
+			name:       "success_delivered",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "fail_rejected",
+			statusCode: http.StatusInternalServerError,
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var payload map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&payload)
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			notifier := notifications.NewWebhookNotifier(server.URL, time.Second)
+			err := notifier.Notify(context.Background(), passwordExpired(expiredUser))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if payload["event"] != notifications.KindPasswordExpired || payload["login"] != "user1" {
+				t.Errorf("expected payload of %s for user1, got: %v", notifications.KindPasswordExpired, payload)
+			}
+			if text, _ := payload["text"].(string); !strings.Contains(text, "user1 expired on 2024-01-02") {
+				t.Errorf("expected rendered text, got: %v", text)
+			}
+		})
+	}
+}
+
+func TestLogFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	notifier, err := notifications.NewLogFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = notifier.Notify(context.Background(), passwordExpired(expiredUser))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = notifier.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `password expired: user_id=7 login="user1"`) {
+		t.Errorf("expected notification in log, got: %s", content)
+	}
+}
+
+type recordingNotifier struct {
+	got []notifications.Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n notifications.Notification) error {
+	r.got = append(r.got, n)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	email := &recordingNotifier{}
+	logfile := &recordingNotifier{}
+	dispatcher := notifications.NewDispatcher(domain.NotificationChannelLogFile, map[string]notifications.Notifier{
+		domain.NotificationChannelEmail:   email,
+		domain.NotificationChannelLogFile: logfile,
+	})
+
+	prefersEmail := expiredUser
+	prefersEmail.NotificationChannel = domain.NotificationChannelEmail
+	prefersWebhook := expiredUser
+	prefersWebhook.NotificationChannel = domain.NotificationChannelWebhook
+
+	ctx := context.Background()
+	err := dispatcher.Notify(ctx, passwordExpired(prefersEmail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dispatcher.Notify(ctx, passwordExpired(expiredUser))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dispatcher.Notify(ctx, passwordExpired(prefersWebhook))
+	if !errors.Is(err, notifications.ErrChannelNotConfigured) {
+		t.Errorf("expected error: %v, got: %v", notifications.ErrChannelNotConfigured, err)
+	}
+
+	if len(email.got) != 1 || len(logfile.got) != 1 {
+		t.Errorf("expected notifications per channel: 1, got email: %d, logfile: %d", len(email.got), len(logfile.got))
+	}
+}
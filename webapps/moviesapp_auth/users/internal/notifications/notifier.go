@@ -0,0 +1,129 @@
+// Package notifications доставляет пользователям уведомления по выбранному ими каналу.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"movies-auth/users/internal/domain"
+	"path"
+	"strings"
+	"text/template"
+)
+
+var (
+	ErrChannelNotConfigured = errors.New("notification channel not configured")
+	ErrNoAddress            = errors.New("no delivery address")
+	ErrUnknownTemplate      = errors.New("unknown notification template")
+)
+
+const (
+	KindPasswordExpired = "password_expired"
+	// KindEmailVerification и KindPasswordReset передают одноразовый токен в Data["token"]
+	// и срок его действия в Data["expires"]
+	KindEmailVerification = "email_verification"
+	KindPasswordReset     = "password_reset"
+)
+
+// Notification - уведомление вида Kind для пользователя User.
+// IdempotencyKey одинаков у повторных доставок одного уведомления, получатель может по нему отбросить дубли.
+// Data - дополнительные значения, доступные в шаблонах.
+type Notification struct {
+	Kind           string
+	User           domain.User
+	IdempotencyKey string
+	Data           map[string]string
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+//go:embed templates
+var templatesFS embed.FS
+
+// templates - шаблоны уведомлений канала по видам уведомлений.
+// Тело письма - основной шаблон файла, тема задается блоком {{define "subject"}}.
+type templates map[string]*template.Template
+
+func loadTemplates(channel string) templates {
+	dir := path.Join("templates", channel)
+	entries, err := templatesFS.ReadDir(dir)
+	if err != nil {
+		panic(fmt.Sprintf("notifications: read templates %s: %s", dir, err))
+	}
+
+	ts := make(templates, len(entries))
+	for _, e := range entries {
+		kind := strings.TrimSuffix(e.Name(), ".tmpl")
+		ts[kind] = template.Must(template.ParseFS(templatesFS, path.Join(dir, e.Name())))
+	}
+
+	return ts
+}
+
+func (ts templates) render(n Notification) (subject string, body string, err error) {
+	t, ok := ts[n.Kind]
+	if !ok {
+		return "", "", fmt.Errorf("%s: %w", n.Kind, ErrUnknownTemplate)
+	}
+
+	var buf bytes.Buffer
+	if st := t.Lookup("subject"); st != nil {
+		err = st.Execute(&buf, n)
+		if err != nil {
+			return "", "", err
+		}
+		subject = strings.TrimSpace(buf.String())
+		buf.Reset()
+	}
+
+	err = t.Execute(&buf, n)
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, strings.TrimSpace(buf.String()), nil
+}
+
+// Dispatcher отправляет уведомление через канал, выбранный пользователем,
+// или через канал по умолчанию, если пользователь его не выбрал.
+type Dispatcher struct {
+	defaultChannel string
+	notifiers      map[string]Notifier
+}
+
+func NewDispatcher(defaultChannel string, notifiers map[string]Notifier) *Dispatcher {
+	return &Dispatcher{
+		defaultChannel: defaultChannel,
+		notifiers:      notifiers,
+	}
+}
+
+// Channel возвращает отправителя канала, если канал настроен.
+func (d *Dispatcher) Channel(channel string) (Notifier, bool) {
+	notifier, ok := d.notifiers[channel]
+
+	return notifier, ok
+}
+
+func (d *Dispatcher) Notify(ctx context.Context, n Notification) error {
+	channel := n.User.NotificationChannel
+	if channel == "" {
+		channel = d.defaultChannel
+	}
+
+	notifier, ok := d.notifiers[channel]
+	if !ok {
+		return fmt.Errorf("channel %q: %w", channel, ErrChannelNotConfigured)
+	}
+
+	err := notifier.Notify(ctx, n)
+	if err != nil {
+		return fmt.Errorf("notify via %s: %w", channel, err)
+	}
+
+	return nil
+}
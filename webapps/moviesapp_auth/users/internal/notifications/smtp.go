@@ -0,0 +1,118 @@
+package notifications
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// SMTPNotifier отправляет уведомления письмом на адрес пользователя.
+type SMTPNotifier struct {
+	host      string
+	addr      string
+	from      string
+	auth      smtp.Auth
+	templates templates
+}
+
+// NewSMTPNotifier создает отправителя писем через SMTP-сервер host:port.
+// Пустой username отключает аутентификацию.
+func NewSMTPNotifier(host string, port int, username string, password string, from string) *SMTPNotifier {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPNotifier{
+		host:      host,
+		addr:      net.JoinHostPort(host, strconv.Itoa(port)),
+		from:      from,
+		auth:      auth,
+		templates: loadTemplates("email"),
+	}
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.User.Email == "" {
+		return fmt.Errorf("user %d has no email: %w", n.User.ID, ErrNoAddress)
+	}
+
+	subject, body, err := s.templates.render(n)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: s.host})
+		if err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		err = c.Auth(s.auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(s.from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(n.User.Email)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(s.message(n.User.Email, n.IdempotencyKey, subject, body))
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (s *SMTPNotifier) message(to string, idempotencyKey string, subject string, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	if idempotencyKey != "" {
+		// повторная доставка получает тот же Message-ID, почтовые клиенты склеивают дубли
+		fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", idempotencyKey, s.host)
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
@@ -0,0 +1,70 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier отправляет уведомления POST-запросом с JSON на заданный адрес.
+type WebhookNotifier struct {
+	url       string
+	client    *http.Client
+	templates templates
+}
+
+type webhookPayload struct {
+	Event  string `json:"event"`
+	UserID int    `json:"userId"`
+	Login  string `json:"login"`
+	Text   string `json:"text"`
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:       url,
+		client:    &http.Client{Timeout: timeout},
+		templates: loadTemplates("webhook"),
+	}
+}
+
+func (wh *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	_, text, err := wh.templates.render(n)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(webhookPayload{
+		Event:  n.Kind,
+		UserID: n.User.ID,
+		Login:  n.User.Login,
+		Text:   text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.IdempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", n.IdempotencyKey)
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
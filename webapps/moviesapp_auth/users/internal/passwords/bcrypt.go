@@ -0,0 +1,62 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const DefaultBcryptCost = 12
+
+// BcryptMaxLength - bcrypt учитывает только первые 72 байта пароля.
+const BcryptMaxLength = 72
+
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) BcryptHasher {
+	return BcryptHasher{
+		cost: cost,
+	}
+}
+
+func (h BcryptHasher) Algorithm() string {
+	return AlgorithmBcrypt
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	if len(password) > BcryptMaxLength {
+		return "", fmt.Errorf("%w: bcrypt accepts at most %d bytes", ErrTooLong, BcryptMaxLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (h BcryptHasher) Compare(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+
+	return err
+}
+
+func (h BcryptHasher) MaxLength() int {
+	return BcryptMaxLength
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+
+	return cost != h.cost
+}
@@ -0,0 +1,101 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var ErrMismatch = errors.New("password mismatch")
+var ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+var ErrTooLong = errors.New("password too long")
+
+// Hasher хэширует пароли одним конкретным алгоритмом.
+// Соль генерируется на каждый вызов Hash и хранится внутри закодированного хэша.
+type Hasher interface {
+	Algorithm() string
+	Hash(password string) (string, error)
+	Compare(hash, password string) error
+	// NeedsRehash сообщает, что хэш получен с устаревшими параметрами алгоритма.
+	NeedsRehash(hash string) bool
+	// MaxLength - наибольшая длина пароля в байтах, которую принимает Hash, 0 - без ограничения.
+	MaxLength() int
+}
+
+// Manager хэширует новые пароли текущим алгоритмом и проверяет пароли,
+// захэшированные любым из зарегистрированных алгоритмов.
+type Manager struct {
+	current Hasher
+	hashers map[string]Hasher
+}
+
+func NewManager(current Hasher, legacy ...Hasher) *Manager {
+	m := &Manager{
+		current: current,
+		hashers: map[string]Hasher{current.Algorithm(): current},
+	}
+	for _, h := range legacy {
+		m.hashers[h.Algorithm()] = h
+	}
+
+	return m
+}
+
+// NewDefaultManager возвращает менеджер с алгоритмом algorithm в качестве текущего,
+// остальные поддерживаемые алгоритмы используются только для проверки старых хэшей.
+func NewDefaultManager(algorithm string) (*Manager, error) {
+	bcryptHasher := NewBcryptHasher(DefaultBcryptCost)
+	argonHasher := NewArgon2idHasher(DefaultArgon2idParams)
+
+	switch algorithm {
+	case AlgorithmBcrypt:
+		return NewManager(bcryptHasher, argonHasher), nil
+	case AlgorithmArgon2id, "":
+		return NewManager(argonHasher, bcryptHasher), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+func (m *Manager) Algorithm() string {
+	return m.current.Algorithm()
+}
+
+// MaxLength возвращает наибольшую длину нового пароля в байтах для текущего алгоритма, 0 - без ограничения.
+func (m *Manager) MaxLength() int {
+	return m.current.MaxLength()
+}
+
+// Hash возвращает хэш пароля и имя алгоритма, которым он получен.
+func (m *Manager) Hash(password string) (string, string, error) {
+	hash, err := m.current.Hash(password)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hash, m.current.Algorithm(), nil
+}
+
+// Verify проверяет пароль по хэшу, полученному алгоритмом algorithm.
+// needsRehash равен true, если хэш следует пересчитать текущим алгоритмом.
+func (m *Manager) Verify(algorithm, hash, password string) (needsRehash bool, err error) {
+	h, ok := m.hashers[algorithm]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+
+	err = h.Compare(hash, password)
+	if err != nil {
+		return false, err
+	}
+
+	if algorithm != m.current.Algorithm() {
+		return true, nil
+	}
+
+	return h.NeedsRehash(hash), nil
+}
@@ -0,0 +1,160 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2idParams = Argon2idParams{
+	Memory:     1024,
+	Iterations: 1,
+	Threads:    1,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+func TestManagerVerify(t *testing.T) {
+	bcryptHasher := NewBcryptHasher(bcrypt.MinCost)
+	argonHasher := NewArgon2idHasher(testArgon2idParams)
+
+	legacyHash, err := bcryptHasher.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentHash, err := argonHasher.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakHash, err := NewArgon2idHasher(Argon2idParams{Memory: 512, Iterations: 1, Threads: 1, SaltLength: 16, KeyLength: 32}).Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(argonHasher, bcryptHasher)
+
+	testCases := []struct {
+		name            string
+		algorithm       string
+		hash            string
+		password        string
+		wantNeedsRehash bool
+		wantErr         error
+	}{
+		{
+			name:      "success_current_algorithm",
+			algorithm: AlgorithmArgon2id,
+			hash:      currentHash,
+			password:  "secret",
+		},
+		{
+			name:            "success_legacy_algorithm",
+			algorithm:       AlgorithmBcrypt,
+			hash:            legacyHash,
+			password:        "secret",
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "success_outdated_params",
+			algorithm:       AlgorithmArgon2id,
+			hash:            weakHash,
+			password:        "secret",
+			wantNeedsRehash: true,
+		},
+		{
+			name:      "fail_mismatch_current",
+			algorithm: AlgorithmArgon2id,
+			hash:      currentHash,
+			password:  "wrong",
+			wantErr:   ErrMismatch,
+		},
+		{
+			name:      "fail_mismatch_legacy",
+			algorithm: AlgorithmBcrypt,
+			hash:      legacyHash,
+			password:  "wrong",
+			wantErr:   ErrMismatch,
+		},
+		{
+			name:      "fail_unknown_algorithm",
+			algorithm: "md5",
+			hash:      currentHash,
+			password:  "secret",
+			wantErr:   ErrUnknownAlgorithm,
+		},
+		{
+			name:      "fail_invalid_hash",
+			algorithm: AlgorithmArgon2id,
+			hash:      "$argon2id$broken",
+			password:  "secret",
+			wantErr:   ErrInvalidHash,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			needsRehash, err := m.Verify(tc.algorithm, tc.hash, tc.password)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if needsRehash != tc.wantNeedsRehash {
+				t.Errorf("expected needs rehash: %t, got: %t", tc.wantNeedsRehash, needsRehash)
+			}
+		})
+	}
+}
+
+func TestHashSalted(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2idParams)
+
+	first, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Errorf("expected different hashes for the same password, got: %s", first)
+	}
+}
+
+func TestManagerMaxLength(t *testing.T) {
+	testCases := []struct {
+		name          string
+		manager       *Manager
+		wantMaxLength int
+	}{
+		{
+			name:          "bcrypt",
+			manager:       NewManager(NewBcryptHasher(bcrypt.MinCost), NewArgon2idHasher(testArgon2idParams)),
+			wantMaxLength: BcryptMaxLength,
+		},
+		{
+			name:    "argon2id",
+			manager: NewManager(NewArgon2idHasher(testArgon2idParams), NewBcryptHasher(bcrypt.MinCost)),
+		},
+	}
+
+	password := strings.Repeat("p", BcryptMaxLength+1)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.manager.MaxLength() != tc.wantMaxLength {
+				t.Fatalf("expected max length: %d, got: %d", tc.wantMaxLength, tc.manager.MaxLength())
+			}
+
+			_, _, err := tc.manager.Hash(password)
+			if tc.wantMaxLength > 0 && !errors.Is(err, ErrTooLong) {
+				t.Errorf("expected error: %v, got: %v", ErrTooLong, err)
+			}
+			if tc.wantMaxLength == 0 && err != nil {
+				t.Errorf("expected long password hashed, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrPolicyViolation = errors.New("password does not satisfy policy")
+
+// Policy - требования к паролям пользователей.
+// MaxAge - срок действия пароля, 0 - пароль не истекает.
+// HistorySize - сколько последних паролей, включая текущий, нельзя использовать повторно.
+type Policy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	MaxAge        time.Duration
+	HistorySize   int
+}
+
+var DefaultPolicy = Policy{
+	MinLength:    8,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+	MaxAge:       90 * 24 * time.Hour,
+	HistorySize:  5,
+}
+
+// Validate проверяет длину и классы символов пароля. Ошибка перечисляет все нарушенные требования.
+func (p Policy) Validate(password string) error {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	var violations []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "a symbol")
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: requires %s", ErrPolicyViolation, strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+// NeverExpires - срок действия пароля, если политика его не ограничивает. Это дата в далеком будущем,
+// а не отсутствие срока, чтобы сортировка и курсоры по сроку работали одинаково для всех пользователей.
+var NeverExpires = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// Expires возвращает срок действия пароля, установленного в момент now,
+// или NeverExpires, если политика не задает срок.
+func (p Policy) Expires(now time.Time) time.Time {
+	if p.MaxAge <= 0 {
+		return NeverExpires
+	}
+
+	return now.Add(p.MaxAge)
+}
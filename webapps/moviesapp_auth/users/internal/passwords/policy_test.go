@@ -0,0 +1,82 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	policy := Policy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	testCases := []struct {
+		name           string
+		password       string
+		wantErrMessage string
+	}{
+		{
+			name:     "success_valid",
+			password: "Secret-123",
+		},
+		{
+			name:           "fail_too_short",
+			password:       "Se-1",
+			wantErrMessage: "at least 8 characters",
+		},
+		{
+			name:           "fail_missing_classes",
+			password:       "secretsecret",
+			wantErrMessage: "an uppercase letter, a digit, a symbol",
+		},
+		{
+			name:     "success_unicode_letters",
+			password: "Пароль-123",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.password)
+			if tc.wantErrMessage == "" {
+				if err != nil {
+					t.Errorf("expected error: %v, got: %v", nil, err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrPolicyViolation) || !strings.Contains(err.Error(), tc.wantErrMessage) {
+				t.Errorf("expected error message: %s, got: %v", tc.wantErrMessage, err)
+			}
+		})
+	}
+}
+
+func TestPolicyExpires(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name        string
+		maxAge      time.Duration
+		wantExpires time.Time
+	}{
+		{
+			name:        "success_max_age",
+			maxAge:      24 * time.Hour,
+			wantExpires: now.Add(24 * time.Hour),
+		},
+		{
+			name:        "success_never_expires",
+			wantExpires: NeverExpires,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expires := Policy{MaxAge: tc.maxAge}.Expires(now)
+			if !expires.Equal(tc.wantExpires) {
+				t.Errorf("expected expires: %v, got: %v", tc.wantExpires, expires)
+			}
+		})
+	}
+}
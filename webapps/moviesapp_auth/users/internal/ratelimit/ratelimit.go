@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"movies-auth/users/internal/domain"
+	"time"
+)
+
+// Store - хранилище счетчиков попыток. Реализуется хранилищами inmemory и db.
+type Store interface {
+	// IncrementRateLimit увеличивает счетчик key и возвращает его значение и время сброса.
+	// Если окно счетчика истекло к моменту now, начинается новое окно длиной window.
+	IncrementRateLimit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error)
+	// DeleteRateLimit удаляет счетчик key, отсутствующий счетчик не считается ошибкой.
+	DeleteRateLimit(ctx context.Context, key string) error
+}
+
+// Limiter разрешает не больше limit попыток по ключу в фиксированном окне window.
+type Limiter struct {
+	store  Store
+	limit  int
+	window time.Duration
+}
+
+func NewLimiter(store Store, limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		store:  store,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow учитывает попытку по ключу key. При превышении лимита возвращает *domain.RetryError
+// с ошибкой domain.ErrTooManyRequests и временем до сброса окна.
+func (l *Limiter) Allow(ctx context.Context, key string) error {
+	now := time.Now().UTC()
+	count, resetAt, err := l.store.IncrementRateLimit(ctx, key, l.window, now)
+	if err != nil {
+		return fmt.Errorf("failed to increment rate limit: %w", err)
+	}
+
+	if count > l.limit {
+		return &domain.RetryError{
+			Err:        fmt.Errorf("rate limit %q exceeded: %w", key, domain.ErrTooManyRequests),
+			RetryAfter: resetAt.Sub(now),
+		}
+	}
+
+	return nil
+}
+
+// Reset сбрасывает счетчик key, например после успешного входа: лимит по логину должен
+// ограничивать подбор пароля, а не частые входы владельца учетной записи.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	err := l.store.DeleteRateLimit(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"movies-auth/users/internal/domain"
+	"testing"
+	"time"
+)
+
+// fakeStore - счетчики с фиксированным окном в памяти. Окно ключа можно завершить, сдвинув resetAt.
+type fakeStore struct {
+	counts  map[string]int
+	resetAt map[string]time.Time
+	err     error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		counts:  make(map[string]int),
+		resetAt: make(map[string]time.Time),
+	}
+}
+
+func (s *fakeStore) IncrementRateLimit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	if s.err != nil {
+		return 0, time.Time{}, s.err
+	}
+
+	if !s.resetAt[key].After(now) {
+		s.counts[key] = 0
+		s.resetAt[key] = now.Add(window)
+	}
+	s.counts[key]++
+
+	return s.counts[key], s.resetAt[key], nil
+}
+
+func (s *fakeStore) DeleteRateLimit(ctx context.Context, key string) error {
+	delete(s.counts, key)
+	delete(s.resetAt, key)
+
+	return s.err
+}
+
+func TestLimiterAllow(t *testing.T) {
+	testCases := []struct {
+		name     string
+		attempts int
+		wantErr  error
+	}{
+		{
+			name:     "success_below_limit",
+			attempts: 2,
+		},
+		{
+			name:     "success_at_limit",
+			attempts: 3,
+		},
+		{
+			name:     "fail_above_limit",
+			attempts: 4,
+			wantErr:  domain.ErrTooManyRequests,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLimiter(newFakeStore(), 3, time.Minute)
+
+			var err error
+			for i := 0; i < tc.attempts; i++ {
+				err = l.Allow(context.Background(), "login:user1")
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			// у других ключей свой счетчик
+			err = l.Allow(context.Background(), "login:user2")
+			if err != nil {
+				t.Errorf("expected other key allowed, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	store := newFakeStore()
+	l := NewLimiter(store, 1, time.Minute)
+
+	err := l.Allow(context.Background(), "ip:10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.resetAt["ip:10.0.0.1"] = time.Now().Add(20 * time.Second)
+
+	err = l.Allow(context.Background(), "ip:10.0.0.1")
+	var retryErr *domain.RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("expected retry error, got: %v", err)
+	}
+	if retryErr.RetryAfter <= 19*time.Second || retryErr.RetryAfter > 20*time.Second {
+		t.Errorf("expected retry after about: %v, got: %v", 20*time.Second, retryErr.RetryAfter)
+	}
+}
+
+func TestLimiterWindowReset(t *testing.T) {
+	store := newFakeStore()
+	l := NewLimiter(store, 1, time.Minute)
+
+	err := l.Allow(context.Background(), "login:user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Allow(context.Background(), "login:user1")
+	if !errors.Is(err, domain.ErrTooManyRequests) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrTooManyRequests, err)
+	}
+
+	// окно закончилось, попытки считаются заново
+	store.resetAt["login:user1"] = time.Now().Add(-time.Millisecond)
+	err = l.Allow(context.Background(), "login:user1")
+	if err != nil {
+		t.Errorf("expected attempt allowed in new window, got: %v", err)
+	}
+	if store.counts["login:user1"] != 1 {
+		t.Errorf("expected attempts in new window: %d, got: %d", 1, store.counts["login:user1"])
+	}
+}
+
+func TestLimiterReset(t *testing.T) {
+	store := newFakeStore()
+	l := NewLimiter(store, 1, time.Minute)
+
+	err := l.Allow(context.Background(), "login:user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Reset(context.Background(), "login:user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Allow(context.Background(), "login:user1")
+	if err != nil {
+		t.Errorf("expected attempt allowed after reset, got: %v", err)
+	}
+}
+
+func TestLimiterStoreError(t *testing.T) {
+	store := newFakeStore()
+	store.err = errors.New("connection refused")
+	l := NewLimiter(store, 1, time.Minute)
+
+	err := l.Allow(context.Background(), "login:user1")
+	if err == nil || errors.Is(err, domain.ErrTooManyRequests) {
+		t.Errorf("expected store error, got: %v", err)
+	}
+}
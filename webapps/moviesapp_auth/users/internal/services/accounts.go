@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"movies-auth/users/internal/notifications"
+	"time"
+)
+
+// Mailer доставляет пользователю письма с одноразовыми токенами.
+type Mailer interface {
+	Notify(ctx context.Context, n notifications.Notification) error
+}
+
+// AccountVerification - подтверждение email и сброс пароля одноразовыми токенами.
+// Без Mailer письма не отправляются. RequireVerified запрещает вход, пока email не подтвержден.
+type AccountVerification struct {
+	Mailer          Mailer
+	RequireVerified bool
+	VerificationTTL time.Duration
+	ResetTTL        time.Duration
+}
+
+const (
+	// accountTokenSize - число случайных байт одноразового токена.
+	accountTokenSize = 32
+	// accountMailTimeout ограничивает создание токена и отправку письма в фоне.
+	accountMailTimeout = 30 * time.Second
+)
+
+// RequiresEmailVerification сообщает, что пользователь не сможет войти, пока не подтвердит email.
+func (s *UsersService) RequiresEmailVerification(user domain.User) bool {
+	return s.checkEmailVerified(user) != nil
+}
+
+func (s *UsersService) checkEmailVerified(user domain.User) error {
+	if s.Verification.RequireVerified && user.EmailVerifiedAt.IsZero() {
+		return fmt.Errorf("user %d: %w", user.ID, domain.ErrEmailNotVerified)
+	}
+
+	return nil
+}
+
+// RequestEmailVerification повторно отправляет письмо для подтверждения email.
+func (s *UsersService) RequestEmailVerification(ctx context.Context, login string) error {
+	return s.requestAccountToken(ctx, login, "email verification", func(user domain.User) bool {
+		return user.EmailVerifiedAt.IsZero()
+	}, s.sendEmailVerification)
+}
+
+// VerifyEmail подтверждает email по токену из письма.
+func (s *UsersService) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.verifyEmail(ctx, token)
+	recordAudit(ctx, s.Audit, userAuditEvent(domain.AuditEmailVerify, "", domain.User{ID: userID}), err)
+
+	return err
+}
+
+// verifyEmail возвращает пользователя, которому выдан токен, если токен найден.
+func (s *UsersService) verifyEmail(ctx context.Context, token string) (int, error) {
+	accountToken, err := s.findAccountToken(ctx, domain.AccountTokenVerifyEmail, token)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	err = s.spendAccountToken(ctx, accountToken, now)
+	if err != nil {
+		return accountToken.UserID, err
+	}
+
+	err = s.Storage.SetEmailVerified(ctx, accountToken.UserID, accountToken.Email, now)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			// email сменился после отправки письма
+			return accountToken.UserID, fmt.Errorf("email of user %d changed: %w", accountToken.UserID, domain.ErrInvalidToken)
+		}
+		return accountToken.UserID, fmt.Errorf("failed to verify email of user %d: %w", accountToken.UserID, err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "email verified", slog.Int("user_id", accountToken.UserID))
+
+	return accountToken.UserID, nil
+}
+
+// RequestPasswordReset отправляет письмо со сбросом пароля на email пользователя.
+func (s *UsersService) RequestPasswordReset(ctx context.Context, login string) error {
+	return s.requestAccountToken(ctx, login, "password reset", nil, func(ctx context.Context, user domain.User) error {
+		return s.sendAccountToken(ctx, user, domain.AccountTokenResetPassword, notifications.KindPasswordReset, s.Verification.ResetTTL)
+	})
+}
+
+// ResetPassword устанавливает новый пароль по токену из письма. Токен тратится, только если
+// новый пароль подходит, а письмо с ним заодно подтверждает email. Сессии пользователя нужно завершить отдельно.
+func (s *UsersService) ResetPassword(ctx context.Context, token string, newPassword string) (domain.User, error) {
+	user, err := s.resetPassword(ctx, token, newPassword)
+	recordAudit(ctx, s.Audit, userAuditEvent(domain.AuditPasswordReset, user.Login, user), err)
+
+	return user, err
+}
+
+func (s *UsersService) resetPassword(ctx context.Context, token string, newPassword string) (domain.User, error) {
+	accountToken, err := s.findAccountToken(ctx, domain.AccountTokenResetPassword, token)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := s.Storage.GetUser(ctx, accountToken.UserID)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to get user %d: %w", accountToken.UserID, err)
+	}
+	if !user.DisabledAt.IsZero() {
+		return domain.User{}, fmt.Errorf("user %d: %w", user.ID, domain.ErrUserDisabled)
+	}
+
+	err = s.checkNewPassword(ctx, user, newPassword)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	now := time.Now().UTC()
+	err = s.spendAccountToken(ctx, accountToken, now)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	err = s.storePassword(ctx, user, newPassword)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	logger := logging.FromContext(ctx).With(slog.Int("user_id", user.ID))
+	logger.InfoContext(ctx, "password reset")
+	if user.FailedLogins > 0 || !user.LockedUntil.IsZero() {
+		s.resetLoginFailures(ctx, user)
+	}
+	if user.EmailVerifiedAt.IsZero() {
+		err = s.Storage.SetEmailVerified(ctx, user.ID, accountToken.Email, now)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			logger.ErrorContext(ctx, "failed to verify email", slog.Any("error", err))
+		}
+	}
+
+	return s.Storage.GetUser(ctx, user.ID)
+}
+
+// requestAccountToken отправляет письмо what пользователю login, если ему можно отправить
+// одноразовый токен и wanted (если задан) его пропускает. Ограничение частоты писем действует
+// и для несуществующих логинов.
+// Ответ не зависит от того, существует ли пользователь: для любого логина выполняется один и тот же
+// поиск, а токен создается и письмо отправляется в фоне, поэтому ни ответ, ни время ответа
+// не раскрывают логины. Письмо, не отправленное из-за остановки сервиса, можно запросить повторно.
+func (s *UsersService) requestAccountToken(ctx context.Context, login string, what string,
+	wanted func(user domain.User) bool, send func(ctx context.Context, user domain.User) error) error {
+	if s.Protection.Limiter != nil {
+		err := s.Protection.Limiter.Allow(ctx, "mail:"+login)
+		if err != nil {
+			return err
+		}
+	}
+
+	user, err := s.Storage.GetUserByID(ctx, login)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user from storage: %w", err)
+	}
+	if user.Email == "" || !user.DisabledAt.IsZero() || (wanted != nil && !wanted(user)) {
+		return nil
+	}
+
+	logger := logging.FromContext(ctx).With(slog.Int("user_id", user.ID))
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), accountMailTimeout)
+		defer cancel()
+
+		err := send(sendCtx, user)
+		if err != nil {
+			logger.ErrorContext(sendCtx, "failed to send "+what, slog.Any("error", err))
+		}
+	}()
+
+	return nil
+}
+
+func (s *UsersService) sendEmailVerification(ctx context.Context, user domain.User) error {
+	return s.sendAccountToken(ctx, user, domain.AccountTokenVerifyEmail, notifications.KindEmailVerification, s.Verification.VerificationTTL)
+}
+
+// sendAccountToken создает токен с назначением purpose и отправляет его письмом на email пользователя.
+func (s *UsersService) sendAccountToken(ctx context.Context, user domain.User, purpose string, kind string, ttl time.Duration) error {
+	if s.Verification.Mailer == nil {
+		return errors.New("mailer not configured")
+	}
+
+	token, accountToken, err := s.issueAccountToken(ctx, user, purpose, ttl)
+	if err != nil {
+		return err
+	}
+
+	return s.Verification.Mailer.Notify(ctx, notifications.Notification{
+		Kind:           kind,
+		User:           user,
+		IdempotencyKey: fmt.Sprintf("%s:%d", purpose, accountToken.ID),
+		Data: map[string]string{
+			"token":   token,
+			"expires": accountToken.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// issueAccountToken создает одноразовый токен с назначением purpose, действующий ttl.
+// Прежние токены пользователя с тем же назначением перестают действовать.
+func (s *UsersService) issueAccountToken(ctx context.Context, user domain.User, purpose string, ttl time.Duration) (string, domain.AccountToken, error) {
+	secret := make([]byte, accountTokenSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", domain.AccountToken{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	accountToken, err := s.Storage.InsertAccountToken(ctx, domain.AccountToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Email:     user.Email,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return "", domain.AccountToken{}, fmt.Errorf("failed to save token: %w", err)
+	}
+
+	return token, accountToken, nil
+}
+
+func (s *UsersService) findAccountToken(ctx context.Context, purpose string, token string) (domain.AccountToken, error) {
+	accountToken, err := s.Storage.GetAccountToken(ctx, purpose, hashToken(token))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.AccountToken{}, fmt.Errorf("unknown %s token: %w", purpose, domain.ErrInvalidToken)
+		}
+		return domain.AccountToken{}, fmt.Errorf("failed to get %s token: %w", purpose, err)
+	}
+
+	return accountToken, nil
+}
+
+// spendAccountToken отмечает токен использованным. Использованный или истекший токен недействителен.
+func (s *UsersService) spendAccountToken(ctx context.Context, accountToken domain.AccountToken, now time.Time) error {
+	err := s.Storage.UseAccountToken(ctx, accountToken.ID, now)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("%s token used or expired: %w", accountToken.Purpose, domain.ErrInvalidToken)
+		}
+		return fmt.Errorf("failed to use %s token: %w", accountToken.Purpose, err)
+	}
+
+	return nil
+}
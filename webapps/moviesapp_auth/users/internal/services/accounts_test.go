@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/notifications"
+	"movies-auth/users/internal/passwords"
+	"movies-auth/users/internal/storage/inmemory"
+	"movies-auth/users/internal/tests/notifytest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var mailTokenRe = regexp.MustCompile(`code to [^:]+: (\S+)`)
+
+func newTestVerificationService(t *testing.T) (*UsersService, *notifytest.SMTPServer) {
+	server := notifytest.NewSMTPServer(t)
+	verification := AccountVerification{
+		Mailer:          notifications.NewSMTPNotifier(server.Host, server.Port, "", "", "noreply@example.com"),
+		RequireVerified: true,
+		VerificationTTL: time.Hour,
+		ResetTTL:        time.Hour,
+	}
+	policy := passwords.Policy{MinLength: 8, HistorySize: 2}
+
+	return NewUsersService(inmemory.NewStorage(), passwords.NewManager(passwords.NewBcryptHasher(4)), policy,
+		LoginProtection{}, verification), server
+}
+
+// nextMailToken ждет следующее принятое письмо и возвращает токен из него.
+// Письма по запросам отправляются в фоне.
+func nextMailToken(t *testing.T, server *notifytest.SMTPServer) string {
+	t.Helper()
+
+	var mail notifytest.Mail
+	select {
+	case mail = <-server.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected mail with token")
+	}
+	match := mailTokenRe.FindStringSubmatch(mail.Data)
+	if match == nil {
+		t.Fatalf("expected token in mail, got: %s", mail.Data)
+	}
+
+	return match[1]
+}
+
+func TestUsersServiceVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	s, server := newTestVerificationService(t)
+
+	_, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if !errors.Is(err, domain.ErrInvalidParams) {
+		t.Errorf("expected error for user without email: %v, got: %v", domain.ErrInvalidParams, err)
+	}
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1", Email: "user1@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.RequiresEmailVerification(user) {
+		t.Errorf("expected new user to require email verification")
+	}
+	firstToken := nextMailToken(t, server)
+
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if !errors.Is(err, domain.ErrEmailNotVerified) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrEmailNotVerified, err)
+	}
+
+	// повторный запрос заменяет токен из первого письма
+	err = s.RequestEmailVerification(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := nextMailToken(t, server)
+
+	err = s.VerifyEmail(ctx, firstToken)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected replaced token rejected, got: %v", err)
+	}
+	err = s.VerifyEmail(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.VerifyEmail(ctx, token)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected used token rejected, got: %v", err)
+	}
+
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Errorf("expected verified user to log in, got: %v", err)
+	}
+
+	err = s.RequestEmailVerification(ctx, "unknown")
+	if err != nil {
+		t.Errorf("expected unknown login ignored, got error: %v", err)
+	}
+	select {
+	case mail := <-server.Received():
+		t.Errorf("expected no mail for unknown login, got: %+v", mail)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestUsersServiceResetPassword(t *testing.T) {
+	ctx := context.Background()
+	s, server := newTestVerificationService(t)
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1", Email: "user1@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// письмо с подтверждением email при регистрации
+	nextMailToken(t, server)
+
+	err = s.RequestPasswordReset(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := nextMailToken(t, server)
+
+	testCases := []struct {
+		name        string
+		token       string
+		newPassword string
+		wantErr     error
+	}{
+		{
+			name:        "fail_unknown_token",
+			token:       "token",
+			newPassword: "password2",
+			wantErr:     domain.ErrInvalidToken,
+		},
+		{
+			name:        "fail_weak_password",
+			token:       token,
+			newPassword: "short",
+			wantErr:     domain.ErrWeakPassword,
+		},
+		{
+			name:        "fail_password_reused",
+			token:       token,
+			newPassword: "password1",
+			wantErr:     domain.ErrPasswordReused,
+		},
+		{
+			name:        "success_reset",
+			token:       token,
+			newPassword: "password2",
+		},
+		{
+			name:        "fail_token_used",
+			token:       token,
+			newPassword: "password3",
+			wantErr:     domain.ErrInvalidToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reset, err := s.ResetPassword(ctx, tc.token, tc.newPassword)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+			if reset.ID != user.ID || reset.EmailVerifiedAt.IsZero() {
+				t.Errorf("expected reset user with verified email, got: %+v", reset)
+			}
+		})
+	}
+
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password2"})
+	if err != nil {
+		t.Errorf("expected login with new password, got: %v", err)
+	}
+}
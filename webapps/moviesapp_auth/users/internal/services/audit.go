@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"movies-auth/users/internal/domain"
+	"strconv"
+)
+
+const (
+	DefaultAuditPageSize = 100
+	MaxAuditPageSize     = 1000
+)
+
+// Auditor записывает события журнала аудита, дополняя их данными запроса из контекста.
+type Auditor interface {
+	Record(ctx context.Context, event domain.AuditEvent)
+}
+
+type AuditStorage interface {
+	ListAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, error)
+}
+
+// AuditService читает журнал аудита для администраторов.
+type AuditService struct {
+	Storage AuditStorage
+}
+
+func NewAuditService(storage AuditStorage) *AuditService {
+	return &AuditService{Storage: storage}
+}
+
+// List возвращает страницу журнала от старых записей к новым, начиная с позиции cursor.
+// Пустой cursor означает первую страницу.
+func (s *AuditService) List(ctx context.Context, filter domain.AuditFilter, cursor string) (domain.AuditPage, error) {
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = DefaultAuditPageSize
+	case filter.Limit < 0 || filter.Limit > MaxAuditPageSize:
+		return domain.AuditPage{}, fmt.Errorf("limit must be between 1 and %d: %w", MaxAuditPageSize, domain.ErrInvalidParams)
+	}
+
+	filter, err := checkAuditFilter(filter, cursor)
+	if err != nil {
+		return domain.AuditPage{}, err
+	}
+
+	// запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	pageSize := filter.Limit
+	filter.Limit++
+	events, err := s.Storage.ListAuditEvents(ctx, filter)
+	if err != nil {
+		return domain.AuditPage{}, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	page := domain.AuditPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		page.NextCursor = strconv.FormatInt(page.Events[pageSize-1].ID, 10)
+	}
+
+	return page, nil
+}
+
+// Export передает fn все подходящие под filter записи от старых к новым, читая журнал страницами.
+// Выгрузка прерывается первой ошибкой fn.
+func (s *AuditService) Export(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	filter, err := checkAuditFilter(filter, "")
+	if err != nil {
+		return err
+	}
+	filter.Limit = MaxAuditPageSize
+
+	for {
+		events, err := s.Storage.ListAuditEvents(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to list audit events: %w", err)
+		}
+
+		for _, event := range events {
+			err = fn(event)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(events) < filter.Limit {
+			return nil
+		}
+		filter.AfterID = events[len(events)-1].ID
+	}
+}
+
+func checkAuditFilter(filter domain.AuditFilter, cursor string) (domain.AuditFilter, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return domain.AuditFilter{}, fmt.Errorf("from must be before to: %w", domain.ErrInvalidParams)
+	}
+
+	if cursor != "" {
+		afterID, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || afterID <= 0 {
+			return domain.AuditFilter{}, fmt.Errorf("malformed cursor: %w", domain.ErrInvalidParams)
+		}
+		filter.AfterID = afterID
+	}
+
+	return filter, nil
+}
+
+// recordAudit записывает событие с результатом, определенным по err. Без журнала ничего не делает.
+func recordAudit(ctx context.Context, auditor Auditor, event domain.AuditEvent, err error) {
+	if auditor == nil {
+		return
+	}
+
+	event.Outcome, event.Reason = auditOutcome(err)
+	auditor.Record(ctx, event)
+}
+
+// auditOutcome возвращает результат действия и стабильный код причины неудачи для журнала.
+func auditOutcome(err error) (string, string) {
+	if err == nil {
+		return domain.AuditSuccess, ""
+	}
+	if errors.Is(err, domain.ErrSecondFactorRequired) {
+		return domain.AuditChallenge, ""
+	}
+
+	// порядок важен: блокировка передается как ошибка ограничения попыток
+	reasons := []struct {
+		err    error
+		reason string
+	}{
+		{domain.ErrAccountLocked, "locked"},
+		{domain.ErrTooManyRequests, "throttled"},
+		{domain.ErrInvalidPassword, "invalid_password"},
+		{domain.ErrPasswordExpired, "password_expired"},
+		{domain.ErrUserDisabled, "user_disabled"},
+		{domain.ErrEmailNotVerified, "email_not_verified"},
+		{domain.ErrInvalidCode, "invalid_code"},
+		{domain.ErrTokenReused, "token_reused"},
+		{domain.ErrInvalidToken, "invalid_token"},
+		{domain.ErrSessionExpired, "expired"},
+		{domain.ErrWeakPassword, "weak_password"},
+		{domain.ErrPasswordReused, "password_reused"},
+		{domain.ErrConflict, "conflict"},
+		{domain.ErrInvalidParams, "invalid_params"},
+		{domain.ErrNotFound, "not_found"},
+	}
+	for _, r := range reasons {
+		if errors.Is(err, r.err) {
+			return domain.AuditFailure, r.reason
+		}
+	}
+
+	return domain.AuditFailure, "error"
+}
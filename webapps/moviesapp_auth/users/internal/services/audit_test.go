@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"movies-auth/users/internal/audit"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/passwords"
+	"movies-auth/users/internal/storage/inmemory"
+	"testing"
+	"time"
+)
+
+func TestUsersServiceAudit(t *testing.T) {
+	s, storage := newTestUsersService(passwords.Policy{}, LoginProtection{MaxFailures: 2, LockoutDuration: time.Minute})
+	s.Audit = audit.NewRecorder(storage)
+	ctx := audit.WithRequest(context.Background(), audit.Request{IP: "10.0.0.1", UserAgent: "curl", RequestID: "req1"})
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "wrong"})
+	if !errors.Is(err, domain.ErrInvalidPassword) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrInvalidPassword, err)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.SetDisabled(audit.WithActor(ctx, 7), user.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := storage.ListAuditEvents(ctx, domain.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.AuditEvent{
+		{ActorID: user.ID, Actor: "user1", Action: domain.AuditRegister, Outcome: domain.AuditSuccess},
+		{Actor: "user1", Action: domain.AuditLogin, Outcome: domain.AuditFailure, Reason: "invalid_password"},
+		{ActorID: user.ID, Actor: "user1", Action: domain.AuditLogin, Outcome: domain.AuditSuccess},
+		{ActorID: 7, Action: domain.AuditUserDisable, Outcome: domain.AuditSuccess},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got: %+v", len(want), events)
+	}
+	for i, e := range events {
+		w := want[i]
+		if e.ActorID != w.ActorID || e.Actor != w.Actor || e.Action != w.Action || e.Outcome != w.Outcome || e.Reason != w.Reason {
+			t.Errorf("expected event: %+v, got: %+v", w, e)
+		}
+		if e.IP != "10.0.0.1" || e.UserAgent != "curl" || e.RequestID != "req1" || e.CreatedAt.IsZero() {
+			t.Errorf("expected request data in event, got: %+v", e)
+		}
+	}
+	if events[3].Target != domain.UserTarget(user.ID) {
+		t.Errorf("expected target: %s, got: %s", domain.UserTarget(user.ID), events[3].Target)
+	}
+}
+
+func TestAuditServiceList(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemory.NewStorage()
+	recorder := audit.NewRecorder(storage)
+	for i := 0; i < 5; i++ {
+		recorder.Record(ctx, domain.AuditEvent{Action: domain.AuditLogin, Outcome: domain.AuditSuccess})
+	}
+	recorder.Record(ctx, domain.AuditEvent{Action: domain.AuditLogout, Outcome: domain.AuditSuccess})
+
+	auditService := NewAuditService(storage)
+	filter := domain.AuditFilter{Action: domain.AuditLogin, Limit: 2}
+
+	var ids []int64
+	cursor := ""
+	for {
+		page, err := auditService.List(ctx, filter, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range page.Events {
+			ids = append(ids, e.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(ids) != 5 || ids[0] != 1 || ids[4] != 5 {
+		t.Errorf("expected login events 1..5, got: %v", ids)
+	}
+
+	var exported int
+	err := auditService.Export(ctx, domain.AuditFilter{}, func(domain.AuditEvent) error {
+		exported++
+		return nil
+	})
+	if err != nil || exported != 6 {
+		t.Errorf("expected 6 exported events, got: %d, %v", exported, err)
+	}
+
+	_, err = auditService.List(ctx, domain.AuditFilter{From: time.Now(), To: time.Now().Add(-time.Hour)}, "")
+	if !errors.Is(err, domain.ErrInvalidParams) {
+		t.Errorf("expected error for inverted range: %v, got: %v", domain.ErrInvalidParams, err)
+	}
+	_, err = auditService.List(ctx, domain.AuditFilter{}, "cursor")
+	if !errors.Is(err, domain.ErrInvalidParams) {
+		t.Errorf("expected error for malformed cursor: %v, got: %v", domain.ErrInvalidParams, err)
+	}
+}
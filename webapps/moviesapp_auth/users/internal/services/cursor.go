@@ -0,0 +1,64 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"movies-auth/users/internal/domain"
+	"time"
+)
+
+// usersCursor - непрозрачный для клиента курсор страницы пользователей.
+// Хранит порядок сортировки, чтобы курсор нельзя было применить к другой выборке.
+type usersCursor struct {
+	SortBy          string    `json:"s"`
+	Desc            bool      `json:"d"`
+	ID              int       `json:"i"`
+	Login           string    `json:"l,omitempty"`
+	PasswordExpires time.Time `json:"e,omitempty"`
+}
+
+func encodeUsersCursor(params domain.UsersListParams, last domain.User) (string, error) {
+	c := usersCursor{
+		SortBy: params.SortBy,
+		Desc:   params.Desc,
+		ID:     last.ID,
+	}
+
+	switch params.SortBy {
+	case domain.UsersSortByLogin:
+		c.Login = last.Login
+	case domain.UsersSortByPasswordExpires:
+		c.PasswordExpires = last.PasswordExpires
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeUsersCursor(params domain.UsersListParams, cursor string) (*domain.User, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", domain.ErrInvalidParams)
+	}
+
+	var c usersCursor
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", domain.ErrInvalidParams)
+	}
+
+	if c.SortBy != params.SortBy || c.Desc != params.Desc {
+		return nil, fmt.Errorf("cursor does not match sort order: %w", domain.ErrInvalidParams)
+	}
+
+	return &domain.User{
+		ID:              c.ID,
+		Login:           c.Login,
+		PasswordExpires: c.PasswordExpires,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// revocations - сессии, отозванные этим процессом, чьи access токены еще могут быть действительны.
+// Access токен проверяется без хранилища, и без этого списка отзыв вступал бы в силу только по истечении токена.
+// Запись хранится, пока не истекут все выданные до отзыва токены. Другие реплики отзыва не видят
+// и принимают токен до его истечения, поэтому AccessTTL должен быть коротким.
+type revocations struct {
+	mu       sync.Mutex
+	sessions map[int]time.Time
+}
+
+// revoke отзывает токены сессий ids до момента until.
+func (r *revocations) revoke(now time.Time, until time.Time, ids ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sessions == nil {
+		r.sessions = make(map[int]time.Time)
+	}
+	for id, expires := range r.sessions {
+		if !now.Before(expires) {
+			delete(r.sessions, id)
+		}
+	}
+	for _, id := range ids {
+		r.sessions[id] = until
+	}
+}
+
+// revoked сообщает, отозвана ли сессия id.
+func (r *revocations) revoked(id int, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until, ok := r.sessions[id]
+
+	return ok && now.Before(until)
+}
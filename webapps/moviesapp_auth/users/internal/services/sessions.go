@@ -1,46 +1,382 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
 	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"movies-auth/users/internal/metrics"
+	"movies-auth/users/internal/tokens"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type SessionsStorage interface {
-	DeleteSessionByKey(key uuid.UUID) error
-	GetSessionByKey(key uuid.UUID) (domain.Session, error)
-	InsertSession(session domain.Session) (domain.Session, error)
+	DeleteSessionByKey(ctx context.Context, key uuid.UUID) error
+	GetSessionByKey(ctx context.Context, key uuid.UUID) (domain.Session, error)
+	InsertSession(ctx context.Context, session domain.Session) (domain.Session, error)
+	UpdateSessionLastSeen(ctx context.Context, key uuid.UUID, lastSeenAt time.Time) error
+	RotateRefreshToken(ctx context.Context, key uuid.UUID, oldHash string, newHash string, lastSeenAt time.Time) error
+	GetUserAccess(ctx context.Context, userID int) (domain.Access, error)
+	GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error)
+	DeleteUserSession(ctx context.Context, userID int, id int) (uuid.UUID, error)
+	DeleteUserSessions(ctx context.Context, userID int) ([]domain.Session, error)
 }
 
+// AccessTokens подписывает и проверяет access токены.
+type AccessTokens interface {
+	Sign(claims tokens.Claims) (string, error)
+	Verify(token string, now time.Time) (tokens.Claims, error)
+	JWKS() tokens.JWKS
+}
+
+// SessionsInvalidator уведомляет другие сервисы о завершенных сессиях,
+// чтобы они сбросили закэшированные результаты проверки.
+type SessionsInvalidator interface {
+	InvalidateSession(ctx context.Context, key uuid.UUID) error
+}
+
+const (
+	invalidateTimeout = 5 * time.Second
+	// invalidateAttempts попыток уведомления с удвоением задержки: без уведомления другой сервис
+	// принимает завершенную сессию, пока не истечет его кэш
+	invalidateAttempts  = 5
+	invalidateBaseDelay = 100 * time.Millisecond
+)
+
 type SessionsService struct {
-	Storage SessionsStorage
+	Storage     SessionsStorage
+	Invalidator SessionsInvalidator
+	Lifetime    time.Duration
+	IdleTimeout time.Duration
+	// Tokens включает режим токенов, nil - режим cookie с ключом сессии
+	Tokens    AccessTokens
+	AccessTTL time.Duration
+	// Audit - журнал аудита, nil отключает запись событий
+	Audit Auditor
+
+	revoked revocations
 }
 
-func NewSessionService(storage SessionsStorage) *SessionsService {
+// NewSessionService создает сервис сессий. Сессия живет не дольше lifetime с момента создания
+// и истекает раньше, если к ней не обращались дольше idleTimeout.
+// invalidator может быть nil, если уведомлять некого.
+func NewSessionService(storage SessionsStorage, invalidator SessionsInvalidator, lifetime time.Duration, idleTimeout time.Duration) *SessionsService {
 	return &SessionsService{
-		Storage: storage,
+		Storage:     storage,
+		Invalidator: invalidator,
+		Lifetime:    lifetime,
+		IdleTimeout: idleTimeout,
 	}
 }
 
-func (s *SessionsService) CreateSession(userId int) (domain.Session, error) {
+// NewTokenSessionService создает сервис сессий в режиме токенов. Клиент получает access токен,
+// который живет accessTTL, и refresh токен, который меняется при каждом обновлении.
+// Access токен проверяется по подписи без обращения к хранилищу и здесь, и в сервисе movies (см. VerifyAccessToken).
+// Сессия в хранилище ограничивает срок жизни refresh токена так же, как в режиме cookie.
+func NewTokenSessionService(storage SessionsStorage, invalidator SessionsInvalidator, lifetime time.Duration, idleTimeout time.Duration,
+	accessTokens AccessTokens, accessTTL time.Duration) *SessionsService {
+	s := NewSessionService(storage, invalidator, lifetime, idleTimeout)
+	s.Tokens = accessTokens
+	s.AccessTTL = accessTTL
+
+	return s
+}
+
+// CreateSession создает сессию на устройстве device. В режиме токенов сессия содержит выданные клиенту токены.
+func (s *SessionsService) CreateSession(ctx context.Context, userId int, device domain.Device) (domain.Session, error) {
+	now := time.Now().UTC()
 	session := domain.Session{
-		Key:       uuid.New(),
-		UserID:    userId,
-		StartedAt: time.Now().UTC(),
+		Key:        uuid.New(),
+		UserID:     userId,
+		StartedAt:  now,
+		ExpiresAt:  now.Add(s.Lifetime),
+		LastSeenAt: now,
+		UserAgent:  domain.TruncateUserAgent(device.UserAgent),
+		IP:         device.IP,
 	}
 
-	newSession, err := s.Storage.InsertSession(session)
+	var refreshToken string
+	if s.Tokens != nil {
+		var err error
+		refreshToken, err = newRefreshToken(session.Key)
+		if err != nil {
+			return domain.Session{}, err
+		}
+		session.RefreshTokenHash = hashToken(refreshToken)
+	}
+
+	newSession, err := s.Storage.InsertSession(ctx, session)
 	if err != nil {
 		return domain.Session{}, fmt.Errorf("failed to create user session: %w", err)
 	}
 
+	err = s.loadAccess(ctx, &newSession)
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	if s.Tokens != nil {
+		return s.issueTokens(newSession, refreshToken, now)
+	}
+
 	return newSession, nil
 }
 
-func (s *SessionsService) ValidateSession(key uuid.UUID) (domain.Session, error) {
-	existingSession, err := s.Storage.GetSessionByKey(key)
+// Refresh выдает новую пару токенов в обмен на действующий refresh токен.
+// Повторное предъявление уже замененного токена означает его утечку: сессия завершается,
+// а вызывающий получает domain.ErrTokenReused.
+func (s *SessionsService) Refresh(ctx context.Context, refreshToken string) (domain.Session, error) {
+	session, err := s.refresh(ctx, refreshToken)
+	if err != nil {
+		// успешные обновления частые и ничего не меняют в правах, в журнал попадают только отказы
+		recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditSessionRefresh}, err)
+		metrics.AuthAttempts.With("refresh", metrics.ResultFailure).Inc()
+		return domain.Session{}, err
+	}
+	metrics.AuthAttempts.With("refresh", metrics.ResultSuccess).Inc()
+
+	return session, nil
+}
+
+func (s *SessionsService) refresh(ctx context.Context, refreshToken string) (domain.Session, error) {
+	if s.Tokens == nil {
+		return domain.Session{}, fmt.Errorf("refresh tokens are disabled: %w", domain.ErrInvalidToken)
+	}
+
+	key, err := refreshTokenSessionKey(refreshToken)
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	session, err := s.Storage.GetSessionByKey(ctx, key)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(s.CookieExpires(session)) {
+		return domain.Session{}, fmt.Errorf("session %d: %w", session.ID, domain.ErrSessionExpired)
+	}
+
+	oldHash := hashToken(refreshToken)
+	newToken, err := newRefreshToken(key)
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	err = s.Storage.RotateRefreshToken(ctx, key, oldHash, hashToken(newToken), now)
+	if errors.Is(err, domain.ErrNotFound) {
+		// токен уже был заменен: им воспользовался кто-то еще, завершаем сессию целиком
+		logging.FromContext(ctx).WarnContext(ctx, "refresh token reuse detected",
+			slog.Int("session_id", session.ID), slog.Int("user_id", session.UserID))
+		deleteErr := s.deleteSession(ctx, key)
+		if deleteErr != nil {
+			return domain.Session{}, fmt.Errorf("failed to revoke session %d: %w", session.ID, deleteErr)
+		}
+		s.revokeTokens(session.ID)
+		return domain.Session{}, fmt.Errorf("session %d: %w", session.ID, domain.ErrTokenReused)
+	}
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	session.LastSeenAt = now
+
+	// роли могли измениться, новый access токен получает актуальные права
+	err = s.loadAccess(ctx, &session)
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	return s.issueTokens(session, newToken, now)
+}
+
+// VerifyAccessToken проверяет подпись и срок access токена без обращения к хранилищу:
+// пользователь, сессия, роли и права берутся из токена. Сессии, отозванные этим процессом
+// (в том числе при отключении пользователя), отклоняются сразу, остальные токены действуют до истечения.
+// Для истекшего токена возвращает domain.ErrSessionExpired, для остальных ошибок - domain.ErrInvalidToken.
+func (s *SessionsService) VerifyAccessToken(ctx context.Context, token string) (domain.Session, error) {
+	session, err := s.verifyAccessToken(ctx, token)
+	s.auditValidation(ctx, err)
+	switch {
+	case err == nil:
+		metrics.SessionValidations.With(metrics.ResultSuccess).Inc()
+	case errors.Is(err, domain.ErrSessionExpired):
+		metrics.SessionValidations.With(metrics.ResultExpired).Inc()
+	default:
+		metrics.SessionValidations.With(metrics.ResultFailure).Inc()
+	}
+
+	return session, err
+}
+
+func (s *SessionsService) verifyAccessToken(ctx context.Context, token string) (domain.Session, error) {
+	if s.Tokens == nil {
+		return domain.Session{}, fmt.Errorf("access tokens are disabled: %w", domain.ErrInvalidToken)
+	}
+
+	claims, err := s.Tokens.Verify(token, time.Now())
+	if err != nil {
+		if errors.Is(err, tokens.ErrTokenExpired) {
+			return domain.Session{}, fmt.Errorf("%w: %w", domain.ErrSessionExpired, err)
+		}
+		return domain.Session{}, fmt.Errorf("%w: %w", domain.ErrInvalidToken, err)
+	}
+
+	sessionID, err := strconv.Atoi(claims.SessionID)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("invalid session id: %w", domain.ErrInvalidToken)
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("invalid subject: %w", domain.ErrInvalidToken)
+	}
+
+	if s.revoked.revoked(sessionID, time.Now()) {
+		return domain.Session{}, fmt.Errorf("session %d revoked: %w", sessionID, domain.ErrInvalidToken)
+	}
+
+	verified := domain.Session{
+		ID:              sessionID,
+		UserID:          userID,
+		StartedAt:       time.Unix(claims.IssuedAt, 0).UTC(),
+		ExpiresAt:       time.Unix(claims.ExpiresAt, 0).UTC(),
+		AccessToken:     token,
+		AccessExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+		Roles:           claims.Roles,
+		Permissions:     claims.Permissions,
+	}
+
+	return verified, nil
+}
+
+// TokensEnabled сообщает, что сервис работает в режиме токенов и принимает только access токены.
+func (s *SessionsService) TokensEnabled() bool {
+	return s.Tokens != nil
+}
+
+// JWKS возвращает открытые ключи для проверки access токенов, в режиме cookie список пуст.
+func (s *SessionsService) JWKS() tokens.JWKS {
+	if s.Tokens == nil {
+		return tokens.JWKS{Keys: []tokens.JWK{}}
+	}
+
+	return s.Tokens.JWKS()
+}
+
+// issueTokens подписывает access токен сессии. Токен не переживает саму сессию.
+func (s *SessionsService) issueTokens(session domain.Session, refreshToken string, now time.Time) (domain.Session, error) {
+	expires := now.Add(s.AccessTTL)
+	if sessionExpires := s.CookieExpires(session); sessionExpires.Before(expires) {
+		expires = sessionExpires
+	}
+
+	accessToken, err := s.Tokens.Sign(tokens.Claims{
+		Subject:     strconv.Itoa(session.UserID),
+		SessionID:   strconv.Itoa(session.ID),
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   expires.Unix(),
+		Roles:       session.Roles,
+		Permissions: session.Permissions,
+	})
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	session.AccessToken = accessToken
+	session.AccessExpiresAt = expires
+	session.RefreshToken = refreshToken
+
+	return session, nil
+}
+
+// refreshTokenSize - число случайных байт refresh токена.
+const refreshTokenSize = 32
+
+// newRefreshToken создает refresh токен вида "<ключ сессии>.<случайная строка>".
+// Ключ сессии позволяет найти сессию, в хранилище попадает только хэш токена.
+func newRefreshToken(key uuid.UUID) (string, error) {
+	secret := make([]byte, refreshTokenSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return key.String() + "." + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func refreshTokenSessionKey(token string) (uuid.UUID, error) {
+	keyPart, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.UUID{}, fmt.Errorf("malformed refresh token: %w", domain.ErrInvalidToken)
+	}
+
+	key, err := uuid.Parse(keyPart)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("malformed refresh token: %w", domain.ErrInvalidToken)
+	}
+
+	return key, nil
+}
+
+// hashToken хэширует refresh и одноразовые токены. Токены случайные и длинные,
+// поэтому достаточно быстрого хэша без соли.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateSession возвращает domain.ErrSessionExpired для истекших сессий.
+// Для действующей сессии продлевает время простоя (sliding renewal).
+// В режиме токенов ключ сессии не принимается: он входит в refresh токен и не должен
+// заменять access токен, поэтому возвращается domain.ErrInvalidToken.
+func (s *SessionsService) ValidateSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	session, err := s.validateSession(ctx, key)
+	s.auditValidation(ctx, err)
+	switch {
+	case err == nil:
+		metrics.SessionValidations.With(metrics.ResultSuccess).Inc()
+	case errors.Is(err, domain.ErrSessionExpired):
+		metrics.SessionValidations.With(metrics.ResultExpired).Inc()
+	default:
+		metrics.SessionValidations.With(metrics.ResultFailure).Inc()
+	}
+
+	return session, err
+}
+
+func (s *SessionsService) validateSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	if s.Tokens != nil {
+		return domain.Session{}, fmt.Errorf("session keys are disabled in token mode: %w", domain.ErrInvalidToken)
+	}
+
+	existingSession, err := s.Storage.GetSessionByKey(ctx, key)
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(s.CookieExpires(existingSession)) {
+		return domain.Session{}, fmt.Errorf("session %d: %w", existingSession.ID, domain.ErrSessionExpired)
+	}
+
+	err = s.Storage.UpdateSessionLastSeen(ctx, key, now)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("failed to renew session: %w", err)
+	}
+	existingSession.LastSeenAt = now
+
+	err = s.loadAccess(ctx, &existingSession)
 	if err != nil {
 		return domain.Session{}, err
 	}
@@ -48,11 +384,139 @@ func (s *SessionsService) ValidateSession(key uuid.UUID) (domain.Session, error)
 	return existingSession, nil
 }
 
-func (s *SessionsService) DeleteSession(key uuid.UUID) error {
-	err := s.Storage.DeleteSessionByKey(key)
+// loadAccess заполняет роли и права владельца сессии.
+// Для отключенного пользователя возвращает domain.ErrUserDisabled.
+func (s *SessionsService) loadAccess(ctx context.Context, session *domain.Session) error {
+	access, err := s.Storage.GetUserAccess(ctx, session.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get roles of user %d: %w", session.UserID, err)
+	}
+	if access.Disabled {
+		return fmt.Errorf("user %d: %w", session.UserID, domain.ErrUserDisabled)
+	}
+	session.Roles = access.Roles
+	session.Permissions = access.Permissions
+
+	return nil
+}
+
+// CookieExpires возвращает момент, когда сессия истечет, если к ней больше не обращаться.
+func (s *SessionsService) CookieExpires(session domain.Session) time.Time {
+	idleExpires := session.LastSeenAt.Add(s.IdleTimeout)
+	if idleExpires.After(session.ExpiresAt) {
+		return session.ExpiresAt
+	}
+
+	return idleExpires
+}
+
+// DeleteSession завершает сессию по запросу пользователя (выход).
+func (s *SessionsService) DeleteSession(ctx context.Context, key uuid.UUID) error {
+	err := s.deleteSession(ctx, key)
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditLogout}, err)
+
+	return err
+}
+
+func (s *SessionsService) deleteSession(ctx context.Context, key uuid.UUID) error {
+	err := s.Storage.DeleteSessionByKey(ctx, key)
 	if err != nil {
 		return err
 	}
 
+	if s.Invalidator != nil {
+		go s.invalidate(logging.FromContext(ctx), key)
+	}
+
 	return nil
 }
+
+// UserSessions возвращает сессии пользователя, включая истекшие, но еще не удаленные.
+func (s *SessionsService) UserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	sessions, err := s.Storage.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions of user %d: %w", userID, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeUserSession завершает сессию id пользователя userID.
+// Если сессия принадлежит другому пользователю, возвращает domain.ErrNotFound.
+func (s *SessionsService) RevokeUserSession(ctx context.Context, userID int, id int) error {
+	key, err := s.Storage.DeleteUserSession(ctx, userID, id)
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditSessionRevoke, Target: domain.SessionTarget(id)}, err)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session %d: %w", id, err)
+	}
+	s.revokeTokens(id)
+
+	if s.Invalidator != nil {
+		go s.invalidate(logging.FromContext(ctx), key)
+	}
+
+	return nil
+}
+
+// RevokeUserSessions завершает все сессии пользователя и возвращает их число.
+func (s *SessionsService) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	sessions, err := s.Storage.DeleteUserSessions(ctx, userID)
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditSessionRevoke, Target: domain.UserTarget(userID)}, err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions of user %d: %w", userID, err)
+	}
+
+	ids := make([]int, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	s.revokeTokens(ids...)
+
+	if s.Invalidator != nil {
+		logger := logging.FromContext(ctx)
+		for _, session := range sessions {
+			go s.invalidate(logger, session.Key)
+		}
+	}
+
+	return len(sessions), nil
+}
+
+// revokeTokens отклоняет access токены сессий ids до их истечения, в режиме cookie ничего не делает.
+func (s *SessionsService) revokeTokens(ids ...int) {
+	if s.Tokens == nil {
+		return
+	}
+
+	now := time.Now()
+	s.revoked.revoke(now, now.Add(s.AccessTTL), ids...)
+}
+
+// auditValidation записывает отклоненные сессии и токены. Успешные проверки идут на каждый запрос
+// и в журнал не попадают.
+func (s *SessionsService) auditValidation(ctx context.Context, err error) {
+	if err != nil {
+		recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditSessionInvalid}, err)
+	}
+}
+
+func (s *SessionsService) invalidate(logger *slog.Logger, key uuid.UUID) {
+	delay := invalidateBaseDelay
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), invalidateTimeout)
+		err := s.Invalidator.InvalidateSession(ctx, key)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt == invalidateAttempts {
+			logger.Error("session invalidation failed", slog.Int("attempts", attempt), slog.Any("error", err))
+			return
+		}
+
+		logger.Warn("session invalidation failed, retrying", slog.Int("attempt", attempt),
+			slog.Duration("delay", delay), slog.Any("error", err))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/storage/inmemory"
+	"movies-auth/users/internal/tokens"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type sessionsStorageStub struct {
+	session  domain.Session
+	lastSeen time.Time
+}
+
+func (s *sessionsStorageStub) DeleteSessionByKey(ctx context.Context, key uuid.UUID) error {
+	return nil
+}
+
+func (s *sessionsStorageStub) GetSessionByKey(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	return s.session, nil
+}
+
+func (s *sessionsStorageStub) InsertSession(ctx context.Context, session domain.Session) (domain.Session, error) {
+	return session, nil
+}
+
+func (s *sessionsStorageStub) UpdateSessionLastSeen(ctx context.Context, key uuid.UUID, lastSeenAt time.Time) error {
+	s.lastSeen = lastSeenAt
+	return nil
+}
+
+func (s *sessionsStorageStub) RotateRefreshToken(ctx context.Context, key uuid.UUID, oldHash string, newHash string, lastSeenAt time.Time) error {
+	return nil
+}
+
+func (s *sessionsStorageStub) GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	return []domain.Session{s.session}, nil
+}
+
+func (s *sessionsStorageStub) DeleteUserSession(ctx context.Context, userID int, id int) (uuid.UUID, error) {
+	return s.session.Key, nil
+}
+
+func (s *sessionsStorageStub) DeleteUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	return []domain.Session{s.session}, nil
+}
+
+func (s *sessionsStorageStub) GetUserAccess(ctx context.Context, userID int) (domain.Access, error) {
+	return domain.Access{Roles: []string{domain.RoleViewer}, Permissions: []string{domain.PermissionMoviesRead}}, nil
+}
+
+func TestValidateSession(t *testing.T) {
+	now := time.Now().UTC()
+
+	testCases := []struct {
+		name        string
+		session     domain.Session
+		wantErr     error
+		wantRenewed bool
+	}{
+		{
+			name: "success_renewed",
+			session: domain.Session{
+				ExpiresAt:  now.Add(time.Hour),
+				LastSeenAt: now.Add(-time.Minute),
+			},
+			wantRenewed: true,
+		},
+		{
+			name: "fail_absolute_lifetime",
+			session: domain.Session{
+				ExpiresAt:  now.Add(-time.Second),
+				LastSeenAt: now.Add(-time.Second),
+			},
+			wantErr: domain.ErrSessionExpired,
+		},
+		{
+			name: "fail_idle_timeout",
+			session: domain.Session{
+				ExpiresAt:  now.Add(time.Hour),
+				LastSeenAt: now.Add(-11 * time.Minute),
+			},
+			wantErr: domain.ErrSessionExpired,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &sessionsStorageStub{session: tc.session}
+			s := NewSessionService(storage, nil, 24*time.Hour, 10*time.Minute)
+
+			session, err := s.ValidateSession(context.Background(), uuid.New())
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if tc.wantRenewed {
+				if storage.lastSeen.IsZero() || !session.LastSeenAt.Equal(storage.lastSeen) {
+					t.Errorf("expected session to be renewed, got last seen: %v", session.LastSeenAt)
+				}
+				return
+			}
+
+			if !storage.lastSeen.IsZero() {
+				t.Errorf("expected expired session not to be renewed")
+			}
+		})
+	}
+}
+
+func TestCookieExpires(t *testing.T) {
+	now := time.Now().UTC()
+	s := NewSessionService(&sessionsStorageStub{}, nil, time.Hour, 10*time.Minute)
+
+	idle := s.CookieExpires(domain.Session{ExpiresAt: now.Add(time.Hour), LastSeenAt: now})
+	if !idle.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("expected idle expiry: %v, got: %v", now.Add(10*time.Minute), idle)
+	}
+
+	absolute := s.CookieExpires(domain.Session{ExpiresAt: now.Add(time.Minute), LastSeenAt: now})
+	if !absolute.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected absolute expiry: %v, got: %v", now.Add(time.Minute), absolute)
+	}
+}
+
+func newTestTokenSessionService(t *testing.T) (*SessionsService, int) {
+	t.Helper()
+
+	key, err := tokens.NewEdDSAKey("key-1", bytes.Repeat([]byte("k"), ed25519.SeedSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := tokens.NewManager("users", key.ID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := inmemory.NewStorage()
+	user, err := storage.Insert(context.Background(), domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewTokenSessionService(storage, nil, time.Hour, 10*time.Minute, manager, 5*time.Minute), user.ID
+}
+
+func TestTokenSession(t *testing.T) {
+	ctx := context.Background()
+	s, userID := newTestTokenSessionService(t)
+
+	session, err := s.CreateSession(ctx, userID, domain.Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.AccessToken == "" || session.RefreshToken == "" {
+		t.Fatalf("expected tokens issued, got: %+v", session)
+	}
+	if session.AccessExpiresAt.After(time.Now().Add(5 * time.Minute)) {
+		t.Errorf("expected access token ttl, got expiry: %v", session.AccessExpiresAt)
+	}
+
+	verified, err := s.VerifyAccessToken(ctx, session.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.ID != session.ID || verified.UserID != userID {
+		t.Errorf("unexpected verified session: %+v", verified)
+	}
+
+	_, err = s.VerifyAccessToken(ctx, session.AccessToken+"x")
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidToken, err)
+	}
+
+	// ключ сессии входит в refresh токен, поэтому не попадает в access токен и не заменяет его
+	claims, err := s.Tokens.Verify(session.AccessToken, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SessionID != strconv.Itoa(session.ID) {
+		t.Errorf("expected session id: %d, got: %s", session.ID, claims.SessionID)
+	}
+	_, err = s.ValidateSession(ctx, session.Key)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidToken, err)
+	}
+
+	refreshed, err := s.Refresh(ctx, session.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Key != session.Key || refreshed.RefreshToken == session.RefreshToken || refreshed.AccessToken == "" {
+		t.Errorf("expected rotated tokens for the same session, got: %+v", refreshed)
+	}
+
+	// повторное использование замененного токена завершает сессию
+	_, err = s.Refresh(ctx, session.RefreshToken)
+	if !errors.Is(err, domain.ErrTokenReused) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrTokenReused, err)
+	}
+	_, err = s.Refresh(ctx, refreshed.RefreshToken)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected revoked session, got: %v", err)
+	}
+}
+
+func TestTokenSessionPermissions(t *testing.T) {
+	ctx := context.Background()
+	s, userID := newTestTokenSessionService(t)
+
+	session, err := s.CreateSession(ctx, userID, domain.Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := s.VerifyAccessToken(ctx, session.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(verified.Permissions, []string{domain.PermissionMoviesRead}) {
+		t.Errorf("expected viewer permissions, got: %v", verified.Permissions)
+	}
+
+	// новые роли попадают в токен при обновлении
+	err = s.Storage.(*inmemory.Storage).SetUserRoles(ctx, userID, []string{domain.RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := s.Refresh(ctx, session.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err = s.VerifyAccessToken(ctx, refreshed.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(verified.Roles, []string{domain.RoleEditor}) ||
+		!reflect.DeepEqual(verified.Permissions, []string{domain.PermissionMoviesRead, domain.PermissionMoviesWrite}) {
+		t.Errorf("expected editor access, got roles: %v, permissions: %v", verified.Roles, verified.Permissions)
+	}
+}
+
+func TestTokenSessionDisabledUser(t *testing.T) {
+	ctx := context.Background()
+	s, userID := newTestTokenSessionService(t)
+
+	session, err := s.CreateSession(ctx, userID, domain.Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// токен проверяется без хранилища, отключение само по себе его не отзывает
+	_, err = s.Storage.(*inmemory.Storage).SetUserDisabled(ctx, userID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.VerifyAccessToken(ctx, session.AccessToken)
+	if err != nil {
+		t.Fatalf("expected token valid until revoked, got: %v", err)
+	}
+
+	// при отключении администратор отзывает сессии, и выданный токен больше не принимается
+	_, err = s.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.VerifyAccessToken(ctx, session.AccessToken)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidToken, err)
+	}
+
+	// сессия удалена, обновить токен тоже нельзя
+	_, err = s.Refresh(ctx, session.RefreshToken)
+	if err == nil {
+		t.Errorf("expected refresh of revoked session rejected")
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	ctx := context.Background()
+	s, userID := newTestTokenSessionService(t)
+
+	device := domain.Device{UserAgent: strings.Repeat("a", domain.MaxUserAgentLength+10), IP: "10.0.0.1"}
+	first, err := s.CreateSession(ctx, userID, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateSession(ctx, userID, domain.Device{UserAgent: "curl", IP: "10.0.0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := s.UserSessions(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || len(sessions[0].UserAgent) != domain.MaxUserAgentLength || sessions[1].IP != "10.0.0.2" {
+		t.Fatalf("expected both devices recorded, got: %+v", sessions)
+	}
+
+	// отозванная сессия перестает принимать access токен сразу, не дожидаясь его истечения
+	err = s.RevokeUserSession(ctx, userID, sessions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.VerifyAccessToken(ctx, first.AccessToken)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidToken, err)
+	}
+	_, err = s.VerifyAccessToken(ctx, second.AccessToken)
+	if err != nil {
+		t.Errorf("expected other session to stay valid, got: %v", err)
+	}
+
+	err = s.RevokeUserSession(ctx, userID+1, sessions[1].ID)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+
+	revoked, err := s.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 1 {
+		t.Errorf("expected one session revoked, got: %d", revoked)
+	}
+	_, err = s.VerifyAccessToken(ctx, second.AccessToken)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidToken, err)
+	}
+}
+
+func TestRevocationsExpire(t *testing.T) {
+	var r revocations
+	now := time.Now()
+	r.revoke(now, now.Add(time.Minute), 1, 2)
+
+	if !r.revoked(1, now) || !r.revoked(2, now) || r.revoked(3, now) {
+		t.Errorf("expected sessions 1 and 2 revoked, got: %v", r.sessions)
+	}
+
+	// после истечения выданных токенов запись больше не нужна и удаляется при следующем отзыве
+	later := now.Add(time.Minute)
+	if r.revoked(1, later) {
+		t.Errorf("expected revocation of session 1 expired")
+	}
+	r.revoke(later, later.Add(time.Minute), 3)
+	if len(r.sessions) != 1 {
+		t.Errorf("expected expired revocations pruned, got: %v", r.sessions)
+	}
+}
+
+func TestRefreshInvalid(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestTokenSessionService(t)
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "fail_malformed", token: "token", wantErr: domain.ErrInvalidToken},
+		{name: "fail_bad_key", token: "key.secret", wantErr: domain.ErrInvalidToken},
+		{name: "fail_unknown_session", token: uuid.NewString() + ".secret", wantErr: domain.ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.Refresh(ctx, tc.token)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	cookieMode := NewSessionService(&sessionsStorageStub{}, nil, time.Hour, time.Hour)
+	_, err := cookieMode.Refresh(ctx, uuid.NewString()+".secret")
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected refresh disabled in cookie mode, got: %v", err)
+	}
+}
+
+// flakyInvalidator отклоняет первые failures уведомлений и сообщает об успешном в invalidated.
+type flakyInvalidator struct {
+	mu          sync.Mutex
+	failures    int
+	attempts    int
+	invalidated chan uuid.UUID
+}
+
+func (i *flakyInvalidator) InvalidateSession(ctx context.Context, key uuid.UUID) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.attempts++
+	if i.attempts <= i.failures {
+		return errors.New("movies unavailable")
+	}
+	i.invalidated <- key
+
+	return nil
+}
+
+func TestDeleteSessionInvalidationRetry(t *testing.T) {
+	ctx := context.Background()
+	invalidator := &flakyInvalidator{failures: 2, invalidated: make(chan uuid.UUID, 1)}
+	storage := inmemory.NewStorage()
+	user, err := storage.Insert(ctx, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSessionService(storage, invalidator, time.Hour, 10*time.Minute)
+
+	session, err := s.CreateSession(ctx, user.ID, domain.Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.DeleteSession(ctx, session.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// временная недоступность другого сервиса не должна оставлять сессию в его кэше
+	select {
+	case key := <-invalidator.invalidated:
+		if key != session.Key {
+			t.Errorf("expected invalidated session: %v, got: %v", session.Key, key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected session invalidated after retries")
+	}
+	invalidator.mu.Lock()
+	defer invalidator.mu.Unlock()
+	if invalidator.attempts != 3 {
+		t.Errorf("expected invalidation attempts: %d, got: %d", 3, invalidator.attempts)
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"movies-auth/users/internal/metrics"
+	"movies-auth/users/internal/totp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultChallengeTTL - срок токена входа со вторым фактором, если LoginProtection.ChallengeTTL не задан
+	defaultChallengeTTL = 5 * time.Minute
+	recoveryCodesCount  = 10
+	// recoveryCodeSize - случайные байты кода восстановления, 16 символов base32
+	recoveryCodeSize = 10
+	qrCodeSize       = 256
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactorStatus возвращает состояние второго фактора пользователя.
+func (s *UsersService) TwoFactorStatus(ctx context.Context, userID int) (domain.TwoFactorStatus, error) {
+	user, err := s.Storage.GetUser(ctx, userID)
+	if err != nil {
+		return domain.TwoFactorStatus{}, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+
+	status := domain.TwoFactorStatus{
+		Enabled: !user.TOTPEnabledAt.IsZero(),
+		Pending: user.TOTPSecret != "" && user.TOTPEnabledAt.IsZero(),
+	}
+	if status.Enabled {
+		status.RecoveryCodesLeft, err = s.Storage.CountRecoveryCodes(ctx, userID)
+		if err != nil {
+			return domain.TwoFactorStatus{}, fmt.Errorf("failed to count recovery codes of user %d: %w", userID, err)
+		}
+	}
+
+	return status, nil
+}
+
+// BeginTwoFactor создает новый секрет второго фактора для пользователя, подтвердившего текущий пароль.
+// Второй фактор включится после ConfirmTwoFactor.
+func (s *UsersService) BeginTwoFactor(ctx context.Context, userID int, password string) (domain.TOTPEnrollment, error) {
+	user, err := s.reauthenticate(ctx, userID, password)
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	if !user.TOTPEnabledAt.IsZero() {
+		return domain.TOTPEnrollment{}, fmt.Errorf("two-factor of user %d already enabled: %w", userID, domain.ErrConflict)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	err = s.Storage.SetTOTPSecret(ctx, userID, secret)
+	if err != nil {
+		return domain.TOTPEnrollment{}, fmt.Errorf("failed to save totp secret of user %d: %w", userID, err)
+	}
+
+	return domain.TOTPEnrollment{Secret: secret, URI: totp.URI(s.Protection.TOTPIssuer, user.Login, secret)}, nil
+}
+
+// TwoFactorQRCode возвращает PNG с QR-кодом начатого подключения. После включения секрет больше не показывается.
+func (s *UsersService) TwoFactorQRCode(ctx context.Context, userID int) ([]byte, error) {
+	user, err := s.pendingTwoFactorUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return totp.QRCode(totp.URI(s.Protection.TOTPIssuer, user.Login, user.TOTPSecret), qrCodeSize)
+}
+
+// ConfirmTwoFactor включает второй фактор, если code подходит к новому секрету,
+// и возвращает коды восстановления. Коды показываются один раз, хранятся только их хэши.
+func (s *UsersService) ConfirmTwoFactor(ctx context.Context, userID int, code string) ([]string, error) {
+	user, err := s.pendingTwoFactorUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.checkTOTP(ctx, user, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("user %d: %w", userID, domain.ErrInvalidCode)
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	err = s.Storage.EnableTOTP(ctx, userID, time.Now().UTC(), hashes)
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditTwoFactorEnable, Target: domain.UserTarget(userID)}, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor of user %d: %w", userID, err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "two-factor enabled", slog.Int("user_id", userID))
+
+	return codes, nil
+}
+
+// DisableTwoFactor выключает второй фактор пользователя, подтвердившего это текущим паролем
+// и кодом или кодом восстановления.
+func (s *UsersService) DisableTwoFactor(ctx context.Context, userID int, password string, code string) error {
+	user, err := s.reauthenticate(ctx, userID, password)
+	if err != nil {
+		recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditTwoFactorDisable, Target: domain.UserTarget(userID)}, err)
+		return err
+	}
+	if user.TOTPEnabledAt.IsZero() {
+		return fmt.Errorf("two-factor of user %d not enabled: %w", userID, domain.ErrNotFound)
+	}
+
+	err = s.verifySecondFactor(ctx, user, code, time.Now())
+	if err != nil {
+		recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditTwoFactorDisable, Target: domain.UserTarget(userID)}, err)
+		return err
+	}
+
+	return s.ResetTwoFactor(ctx, userID)
+}
+
+// ResetTwoFactor выключает второй фактор без кода, например администратором после потери устройства.
+func (s *UsersService) ResetTwoFactor(ctx context.Context, userID int) error {
+	err := s.Storage.DisableTOTP(ctx, userID)
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditTwoFactorDisable, Target: domain.UserTarget(userID)}, err)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor of user %d: %w", userID, err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "two-factor disabled", slog.Int("user_id", userID))
+
+	return nil
+}
+
+// CompleteLogin завершает вход со вторым фактором: обменивает токен из *domain.ChallengeError
+// и код из приложения или код восстановления на пользователя, которому можно открыть сессию.
+func (s *UsersService) CompleteLogin(ctx context.Context, challenge string, code string) (domain.User, error) {
+	user, err := s.completeLogin(ctx, challenge, code)
+	recordAudit(ctx, s.Audit, userAuditEvent(domain.AuditLoginSecondFactor, user.Login, user), err)
+	if err != nil {
+		result := metrics.ResultFailure
+		if errors.Is(err, domain.ErrTooManyRequests) || errors.Is(err, domain.ErrAccountLocked) {
+			result = metrics.ResultThrottled
+		}
+		metrics.AuthAttempts.With("login_2fa", result).Inc()
+		return domain.User{}, err
+	}
+	metrics.AuthAttempts.With("login_2fa", metrics.ResultSuccess).Inc()
+
+	return user, nil
+}
+
+func (s *UsersService) completeLogin(ctx context.Context, challenge string, code string) (domain.User, error) {
+	accountToken, err := s.findAccountToken(ctx, domain.AccountTokenLoginChallenge, challenge)
+	if err != nil {
+		return domain.User{}, err
+	}
+	now := time.Now()
+	if !accountToken.UsedAt.IsZero() || !accountToken.ExpiresAt.After(now) {
+		return domain.User{}, fmt.Errorf("login challenge used or expired: %w", domain.ErrInvalidToken)
+	}
+
+	user, err := s.Storage.GetUser(ctx, accountToken.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.User{}, fmt.Errorf("user %d deleted: %w", accountToken.UserID, domain.ErrInvalidToken)
+		}
+		return domain.User{}, fmt.Errorf("failed to get user %d: %w", accountToken.UserID, err)
+	}
+	if !user.DisabledAt.IsZero() {
+		return domain.User{}, fmt.Errorf("user %d: %w", user.ID, domain.ErrUserDisabled)
+	}
+	err = checkLocked(user, now)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if user.TOTPEnabledAt.IsZero() {
+		return domain.User{}, fmt.Errorf("two-factor of user %d disabled: %w", user.ID, domain.ErrInvalidToken)
+	}
+
+	err = s.verifySecondFactor(ctx, user, code, now)
+	if err != nil {
+		return domain.User{}, err
+	}
+	s.resetSecondFactorFailures(ctx, user)
+
+	err = s.spendAccountToken(ctx, accountToken, now)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+// loginChallenge выдает токен, который вместе с кодом второго фактора обменивается на сессию.
+func (s *UsersService) loginChallenge(ctx context.Context, user domain.User) error {
+	ttl := s.Protection.ChallengeTTL
+	if ttl <= 0 {
+		ttl = defaultChallengeTTL
+	}
+
+	token, accountToken, err := s.issueAccountToken(ctx, user, domain.AccountTokenLoginChallenge, ttl)
+	if err != nil {
+		return fmt.Errorf("failed to issue login challenge: %w", err)
+	}
+
+	return &domain.ChallengeError{Token: token, ExpiresAt: accountToken.ExpiresAt}
+}
+
+// verifySecondFactor проверяет код из приложения или код восстановления.
+// Неверный код считается неудачной попыткой входа и ведет к блокировке так же, как неверный пароль.
+func (s *UsersService) verifySecondFactor(ctx context.Context, user domain.User, code string, now time.Time) error {
+	if s.Protection.Limiter != nil {
+		err := s.Protection.Limiter.Allow(ctx, "2fa:"+strconv.Itoa(user.ID))
+		if err != nil {
+			return err
+		}
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		ok, err := s.checkTOTP(ctx, user, code, now)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	} else {
+		err := s.Storage.UseRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(code)), now.UTC())
+		if err == nil {
+			logging.FromContext(ctx).WarnContext(ctx, "recovery code used", slog.Int("user_id", user.ID))
+			return nil
+		}
+		if !errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("failed to use recovery code: %w", err)
+		}
+	}
+
+	s.registerLoginFailure(ctx, user, now)
+
+	return fmt.Errorf("user %d: %w", user.ID, domain.ErrInvalidCode)
+}
+
+// resetSecondFactorFailures сбрасывает неудачные попытки и лимит попыток второго фактора
+// после верного кода, как authenticate после верного пароля.
+func (s *UsersService) resetSecondFactorFailures(ctx context.Context, user domain.User) {
+	if user.FailedLogins > 0 || !user.LockedUntil.IsZero() {
+		s.resetLoginFailures(ctx, user)
+	}
+	if s.Protection.Limiter != nil {
+		err := s.Protection.Limiter.Reset(ctx, "2fa:"+strconv.Itoa(user.ID))
+		if err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to reset two-factor rate limit", slog.Any("error", err))
+		}
+	}
+}
+
+// reauthenticate проверяет текущий пароль пользователя с открытой сессией перед изменением второго фактора,
+// чтобы его нельзя было подключить или выключить с одной только украденной сессией.
+// Неверный пароль учитывается так же, как при входе.
+func (s *UsersService) reauthenticate(ctx context.Context, userID int, password string) (domain.User, error) {
+	user, err := s.Storage.GetUser(ctx, userID)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+
+	return s.authenticate(ctx, user.Login, password)
+}
+
+// checkTOTP проверяет код из приложения. Принятый код запоминается и повторно не принимается.
+func (s *UsersService) checkTOTP(ctx context.Context, user domain.User, code string, now time.Time) (bool, error) {
+	counter, ok := totp.Validate(user.TOTPSecret, strings.TrimSpace(code), now)
+	if !ok {
+		return false, nil
+	}
+
+	err := s.Storage.UseTOTPCounter(ctx, user.ID, counter)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to save totp counter: %w", err)
+	}
+
+	return true, nil
+}
+
+func (s *UsersService) pendingTwoFactorUser(ctx context.Context, userID int) (domain.User, error) {
+	user, err := s.Storage.GetUser(ctx, userID)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if !user.TOTPEnabledAt.IsZero() {
+		return domain.User{}, fmt.Errorf("two-factor of user %d already enabled: %w", userID, domain.ErrConflict)
+	}
+	if user.TOTPSecret == "" {
+		return domain.User{}, fmt.Errorf("two-factor enrollment of user %d not started: %w", userID, domain.ErrNotFound)
+	}
+
+	return user, nil
+}
+
+// newRecoveryCode возвращает код вида xxxx-xxxx-xxxx-xxxx.
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeSize)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// normalizeRecoveryCode убирает разделители и регистр, которые пользователь мог ввести по-разному.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/passwords"
+	"movies-auth/users/internal/ratelimit"
+	"movies-auth/users/internal/storage/inmemory"
+	"movies-auth/users/internal/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loginChallenge входит по паролю и возвращает токен второго шага.
+func loginChallenge(t *testing.T, s *UsersService) string {
+	t.Helper()
+
+	_, err := s.Login(context.Background(), domain.User{Login: "user1", Password: "password1"})
+	var challenge *domain.ChallengeError
+	if !errors.As(err, &challenge) {
+		t.Fatalf("expected challenge, got: %v", err)
+	}
+	if !errors.Is(err, domain.ErrSecondFactorRequired) || challenge.Token == "" {
+		t.Fatalf("unexpected challenge: %+v", challenge)
+	}
+
+	return challenge.Token
+}
+
+func mustCode(t *testing.T, secret string, counter int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code
+}
+
+func TestUsersServiceTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUsersService(passwords.Policy{}, LoginProtection{MaxFailures: 3, LockoutDuration: time.Minute})
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.BeginTwoFactor(ctx, user.ID, "wrong-password")
+	if !errors.Is(err, domain.ErrInvalidPassword) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidPassword, err)
+	}
+	enrollment, err := s.BeginTwoFactor(ctx, user.ID, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := totp.Counter(time.Now())
+
+	_, err = s.ConfirmTwoFactor(ctx, user.ID, "000000")
+	if !errors.Is(err, domain.ErrInvalidCode) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidCode, err)
+	}
+	confirmCode := mustCode(t, enrollment.Secret, counter)
+	recoveryCodes, err := s.ConfirmTwoFactor(ctx, user.ID, confirmCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recoveryCodes) != recoveryCodesCount {
+		t.Fatalf("expected %d recovery codes, got: %v", recoveryCodesCount, recoveryCodes)
+	}
+	_, err = s.BeginTwoFactor(ctx, user.ID, "password1")
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrConflict, err)
+	}
+
+	challenge := loginChallenge(t, s)
+	_, err = s.CompleteLogin(ctx, challenge, confirmCode)
+	if !errors.Is(err, domain.ErrInvalidCode) {
+		t.Errorf("expected replayed code rejected, got: %v", err)
+	}
+	_, err = s.CompleteLogin(ctx, "unknown", mustCode(t, enrollment.Secret, counter+1))
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidToken, err)
+	}
+	logged, err := s.CompleteLogin(ctx, challenge, mustCode(t, enrollment.Secret, counter+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged.ID != user.ID {
+		t.Errorf("expected user %d, got: %d", user.ID, logged.ID)
+	}
+	_, err = s.CompleteLogin(ctx, challenge, recoveryCodes[0])
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("expected used challenge rejected, got: %v", err)
+	}
+
+	// код восстановления принимается в любом регистре, но только один раз
+	_, err = s.CompleteLogin(ctx, loginChallenge(t, s), " "+strings.ToUpper(recoveryCodes[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CompleteLogin(ctx, loginChallenge(t, s), recoveryCodes[0])
+	if !errors.Is(err, domain.ErrInvalidCode) {
+		t.Errorf("expected used recovery code rejected, got: %v", err)
+	}
+	status, err := s.TwoFactorStatus(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.RecoveryCodesLeft != recoveryCodesCount-1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	// выключить второй фактор одной сессией нельзя, нужен текущий пароль
+	err = s.DisableTwoFactor(ctx, user.ID, "wrong-password", recoveryCodes[1])
+	if !errors.Is(err, domain.ErrInvalidPassword) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidPassword, err)
+	}
+	err = s.DisableTwoFactor(ctx, user.ID, "password1", recoveryCodes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Errorf("expected login without second factor, got: %v", err)
+	}
+}
+
+func TestUsersServiceTwoFactorLockout(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUsersService(passwords.Policy{}, LoginProtection{MaxFailures: 2, LockoutDuration: time.Minute})
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := s.BeginTwoFactor(ctx, user.ID, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := totp.Counter(time.Now())
+	_, err = s.ConfirmTwoFactor(ctx, user.ID, mustCode(t, enrollment.Secret, counter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// неверные коды второго фактора блокируют вход так же, как неверные пароли
+	challenge := loginChallenge(t, s)
+	for i := 0; i < 2; i++ {
+		_, err = s.CompleteLogin(ctx, challenge, "wrong-code")
+		if !errors.Is(err, domain.ErrInvalidCode) {
+			t.Fatalf("expected error: %v, got: %v", domain.ErrInvalidCode, err)
+		}
+	}
+	_, err = s.CompleteLogin(ctx, challenge, mustCode(t, enrollment.Secret, counter+1))
+	if !errors.Is(err, domain.ErrAccountLocked) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrAccountLocked, err)
+	}
+}
+
+func TestUsersServiceTwoFactorResetFailures(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemory.NewStorage()
+	protection := LoginProtection{Limiter: ratelimit.NewLimiter(storage, 2, time.Minute), MaxFailures: 2, LockoutDuration: time.Minute}
+	s := NewUsersService(storage, passwords.NewManager(passwords.NewBcryptHasher(4)), passwords.Policy{}, protection, AccountVerification{})
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := s.BeginTwoFactor(ctx, user.ID, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := totp.Counter(time.Now())
+	recoveryCodes, err := s.ConfirmTwoFactor(ctx, user.ID, mustCode(t, enrollment.Secret, counter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// верный код сбрасывает неудачные попытки и лимит, иначе вторая неверная попытка заблокировала бы вход
+	for i, code := range []string{mustCode(t, enrollment.Secret, counter+1), recoveryCodes[0]} {
+		challenge := loginChallenge(t, s)
+		_, err = s.CompleteLogin(ctx, challenge, "wrong-code")
+		if !errors.Is(err, domain.ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected error: %v, got: %v", i+1, domain.ErrInvalidCode, err)
+		}
+		_, err = s.CompleteLogin(ctx, challenge, code)
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+
+	logged, err := storage.GetUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged.FailedLogins != 0 || !logged.LockedUntil.IsZero() {
+		t.Errorf("expected failures reset, got: %+v", logged)
+	}
+}
+
+func TestUsersServiceTwoFactorChangePassword(t *testing.T) {
+	ctx := context.Background()
+	s, storage := newTestUsersService(passwords.Policy{}, LoginProtection{MaxFailures: 5, LockoutDuration: time.Minute})
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := s.BeginTwoFactor(ctx, user.ID, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := totp.Counter(time.Now())
+	_, err = s.ConfirmTwoFactor(ctx, user.ID, mustCode(t, enrollment.Secret, counter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{
+			name:    "fail_code_missing",
+			wantErr: domain.ErrSecondFactorRequired,
+		},
+		{
+			name:    "fail_code_invalid",
+			code:    "000000",
+			wantErr: domain.ErrInvalidCode,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before, err := storage.GetUser(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.ChangePassword(ctx, "user1", "password1", "password2", tc.code)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			// одного текущего пароля недостаточно, чтобы сменить пароль
+			after, err := storage.GetUser(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Password != before.Password {
+				t.Errorf("expected password hash unchanged, got: %s", after.Password)
+			}
+		})
+	}
+
+	changed, err := s.ChangePassword(ctx, "user1", "password1", "password2", mustCode(t, enrollment.Secret, counter+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.ID != user.ID {
+		t.Errorf("expected user %d, got: %d", user.ID, changed.ID)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password2"})
+	if !errors.Is(err, domain.ErrSecondFactorRequired) {
+		t.Errorf("expected login challenge with new password, got: %v", err)
+	}
+}
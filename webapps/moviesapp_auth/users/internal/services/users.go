@@ -4,26 +4,121 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/logging"
+	"movies-auth/users/internal/metrics"
+	"movies-auth/users/internal/passwords"
+	"strings"
+	"sync"
+	"time"
 )
 
 type UsersStorage interface {
 	GetUserByID(ctx context.Context, login string) (domain.User, error)
 	Insert(ctx context.Context, user domain.User) (domain.User, error)
 	IsUserExist(ctx context.Context, login string) (bool, error)
+	UpdatePassword(ctx context.Context, id int, hash string, algorithm string) error
+	ChangePassword(ctx context.Context, id int, hash string, algorithm string, expires time.Time) error
+	GetPasswordHistory(ctx context.Context, id int, limit int) ([]domain.PasswordHistoryEntry, error)
+	ListUsers(ctx context.Context, params domain.UsersListParams) ([]domain.User, error)
+	RegisterLoginFailure(ctx context.Context, id int, maxFailures int, lockedUntil time.Time) (domain.User, error)
+	ResetLoginFailures(ctx context.Context, id int) error
+	InsertLockoutEvent(ctx context.Context, event domain.LockoutEvent) error
+	SetUserRoles(ctx context.Context, userID int, roles []string) error
+	GetUserAccess(ctx context.Context, userID int) (domain.Access, error)
+	GetUser(ctx context.Context, id int) (domain.User, error)
+	UpdateUser(ctx context.Context, user domain.User) (domain.User, error)
+	SetUserDisabled(ctx context.Context, id int, disabledAt time.Time) (domain.User, error)
+	DeleteUser(ctx context.Context, id int, deletedAt time.Time) error
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, enabledAt time.Time, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseTOTPCounter(ctx context.Context, userID int, counter int64) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string, usedAt time.Time) error
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+	InsertAccountToken(ctx context.Context, token domain.AccountToken) (domain.AccountToken, error)
+	GetAccountToken(ctx context.Context, purpose string, tokenHash string) (domain.AccountToken, error)
+	UseAccountToken(ctx context.Context, id int, usedAt time.Time) error
+	SetEmailVerified(ctx context.Context, userID int, email string, verifiedAt time.Time) error
+}
+
+const (
+	DefaultUsersPageSize = 20
+	MaxUsersPageSize     = 100
+)
+
+type PasswordHasher interface {
+	Hash(password string) (string, string, error)
+	Verify(algorithm, hash, password string) (bool, error)
+	MaxLength() int
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+// LoginProtection - защита от перебора паролей. Limiter ограничивает число попыток входа по логину,
+// после MaxFailures неудачных попыток подряд учетная запись блокируется на LockoutDuration.
+// Нулевые значения отключают соответствующую защиту. Неверные коды второго фактора считаются
+// неудачными попытками. TOTPIssuer - имя сервиса в приложении-аутентификаторе, ChallengeTTL -
+// сколько действует токен входа со вторым фактором.
+type LoginProtection struct {
+	Limiter         RateLimiter
+	MaxFailures     int
+	LockoutDuration time.Duration
+	TOTPIssuer      string
+	ChallengeTTL    time.Duration
 }
 
 type UsersService struct {
-	Storage UsersStorage
+	Storage      UsersStorage
+	Passwords    PasswordHasher
+	Policy       passwords.Policy
+	Protection   LoginProtection
+	Verification AccountVerification
+	// Audit - журнал аудита, nil отключает запись событий
+	Audit Auditor
+
+	dummyOnce sync.Once
+	dummyHash string
+	dummyAlgo string
 }
 
-func NewUsersService(storage UsersStorage) *UsersService {
+func NewUsersService(storage UsersStorage, passwords PasswordHasher, policy passwords.Policy, protection LoginProtection,
+	verification AccountVerification) *UsersService {
 	return &UsersService{
-		Storage: storage,
+		Storage:      storage,
+		Passwords:    passwords,
+		Policy:       policy,
+		Protection:   protection,
+		Verification: verification,
 	}
 }
 
 func (s *UsersService) Create(ctx context.Context, user domain.User) (domain.User, error) {
+	createdUser, err := s.create(ctx, user)
+	recordAudit(ctx, s.Audit, userAuditEvent(domain.AuditRegister, user.Login, createdUser), err)
+
+	return createdUser, err
+}
+
+func (s *UsersService) create(ctx context.Context, user domain.User) (domain.User, error) {
+	err := validateNotificationChannel(user)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	err = s.validatePassword(user.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if s.Verification.RequireVerified && user.Email == "" {
+		return domain.User{}, fmt.Errorf("email required for verification: %w", domain.ErrInvalidParams)
+	}
+
 	isExist, err := s.Storage.IsUserExist(ctx, user.Login)
 	if err != nil && !errors.Is(err, domain.ErrNotFound) {
 		return domain.User{}, fmt.Errorf("failed to get user from storage: %w", err)
@@ -32,23 +127,543 @@ func (s *UsersService) Create(ctx context.Context, user domain.User) (domain.Use
 		return domain.User{}, fmt.Errorf("user create error: %w", domain.ErrConflict)
 	}
 
+	hash, algorithm, err := s.Passwords.Hash(user.Password)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.Password = hash
+	user.PasswordAlgo = algorithm
+	user.PasswordExpires = s.Policy.Expires(time.Now().UTC())
+
 	createdUser, err := s.Storage.Insert(ctx, user)
 	if err != nil {
 		return domain.User{}, err
 	}
 
+	if createdUser.Email != "" && s.Verification.Mailer != nil {
+		// письмо можно запросить повторно, поэтому ошибка отправки не отменяет регистрацию
+		err = s.sendEmailVerification(ctx, createdUser)
+		if err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "failed to send email verification",
+				slog.Int("user_id", createdUser.ID), slog.Any("error", err))
+		}
+	}
+
 	return createdUser, nil
 }
 
 func (s *UsersService) Login(ctx context.Context, user domain.User) (domain.User, error) {
-	existingUser, err := s.Storage.GetUserByID(ctx, user.Login)
+	loggedUser, err := s.login(ctx, user)
+	recordAudit(ctx, s.Audit, userAuditEvent(domain.AuditLogin, user.Login, loggedUser), err)
+	if err != nil {
+		result := metrics.ResultFailure
+		switch {
+		case errors.Is(err, domain.ErrPasswordExpired):
+			result = metrics.ResultExpired
+		case errors.Is(err, domain.ErrTooManyRequests), errors.Is(err, domain.ErrAccountLocked):
+			result = metrics.ResultThrottled
+		case errors.Is(err, domain.ErrSecondFactorRequired):
+			result = metrics.ResultChallenge
+		}
+		metrics.AuthAttempts.With("login", result).Inc()
+		return domain.User{}, err
+	}
+	metrics.AuthAttempts.With("login", metrics.ResultSuccess).Inc()
+
+	return loggedUser, nil
+}
+
+// login возвращает domain.ErrPasswordExpired для верного, но истекшего пароля:
+// такой пароль нужно сменить через ChangePassword, прежде чем получить сессию.
+// Если вход требует подтвержденного email, для неподтвержденного возвращает domain.ErrEmailNotVerified.
+// Со включенным вторым фактором возвращает *domain.ChallengeError, вход завершает CompleteLogin.
+func (s *UsersService) login(ctx context.Context, user domain.User) (domain.User, error) {
+	existingUser, err := s.authenticate(ctx, user.Login, user.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	err = s.checkEmailVerified(existingUser)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if !existingUser.PasswordExpires.IsZero() && time.Now().After(existingUser.PasswordExpires) {
+		return domain.User{}, fmt.Errorf("user %d: %w", existingUser.ID, domain.ErrPasswordExpired)
+	}
+
+	if !existingUser.TOTPEnabledAt.IsZero() {
+		return domain.User{}, s.loginChallenge(ctx, existingUser)
+	}
+
+	return existingUser, nil
+}
+
+// ChangePassword меняет пароль пользователя, подтвердившего текущий пароль. Истекший текущий пароль
+// допускается, новый пароль должен соответствовать политике и не совпадать с последними из истории.
+// Со включенным вторым фактором пароль меняется только вместе с кодом из приложения или кодом
+// восстановления code, без кода возвращается domain.ErrSecondFactorRequired.
+func (s *UsersService) ChangePassword(ctx context.Context, login string, password string, newPassword string, code string) (domain.User, error) {
+	user, err := s.changePassword(ctx, login, password, newPassword, code)
+	recordAudit(ctx, s.Audit, userAuditEvent(domain.AuditPasswordChange, login, user), err)
+
+	return user, err
+}
+
+func (s *UsersService) changePassword(ctx context.Context, login string, password string, newPassword string, code string) (domain.User, error) {
+	existingUser, err := s.authenticate(ctx, login, password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	err = s.checkEmailVerified(existingUser)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	err = s.checkNewPassword(ctx, existingUser, newPassword)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	// текущего пароля недостаточно: второй фактор проверяется до того, как пароль будет сохранен
+	if !existingUser.TOTPEnabledAt.IsZero() {
+		if strings.TrimSpace(code) == "" {
+			return domain.User{}, fmt.Errorf("user %d: %w", existingUser.ID, domain.ErrSecondFactorRequired)
+		}
+		err = s.verifySecondFactor(ctx, existingUser, code, time.Now())
+		if err != nil {
+			return domain.User{}, err
+		}
+	}
+
+	err = s.storePassword(ctx, existingUser, newPassword)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return s.Storage.GetUserByID(ctx, login)
+}
+
+// checkNewPassword проверяет, что новый пароль соответствует политике и не совпадает с последними из истории.
+func (s *UsersService) checkNewPassword(ctx context.Context, user domain.User, newPassword string) error {
+	err := s.validatePassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	historySize := s.Policy.HistorySize
+	if historySize < 1 {
+		// новый пароль в любом случае должен отличаться от текущего
+		historySize = 1
+	}
+	history, err := s.Storage.GetPasswordHistory(ctx, user.ID, historySize)
+	if err != nil {
+		return fmt.Errorf("failed to get password history: %w", err)
+	}
+	if len(history) == 0 {
+		history = append(history, domain.PasswordHistoryEntry{Hash: user.Password, Algorithm: user.PasswordAlgo})
+	}
+	for _, entry := range history {
+		_, err := s.Passwords.Verify(entry.Algorithm, entry.Hash, newPassword)
+		if err == nil {
+			return fmt.Errorf("user %d: %w", user.ID, domain.ErrPasswordReused)
+		}
+		if !errors.Is(err, passwords.ErrMismatch) {
+			return fmt.Errorf("failed to verify password history: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// verifyDummyPassword проверяет пароль неизвестного логина по заранее вычисленному хэшу,
+// чтобы по времени ответа нельзя было узнать, существует ли логин.
+func (s *UsersService) verifyDummyPassword(password string) {
+	s.dummyOnce.Do(func() {
+		hash, algorithm, err := s.Passwords.Hash("dummy-password")
+		if err == nil {
+			s.dummyHash, s.dummyAlgo = hash, algorithm
+		}
+	})
+	if s.dummyHash == "" {
+		return
+	}
+
+	// результат не важен, нужно только время проверки
+	_, _ = s.Passwords.Verify(s.dummyAlgo, s.dummyHash, password)
+}
+
+func (s *UsersService) storePassword(ctx context.Context, user domain.User, newPassword string) error {
+	hash, algorithm, err := s.Passwords.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	expires := s.Policy.Expires(time.Now().UTC())
+	err = s.Storage.ChangePassword(ctx, user.ID, hash, algorithm, expires)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	return nil
+}
+
+// authenticate проверяет пароль пользователя и при необходимости обновляет его хэш.
+// Ограничение попыток и блокировка возвращают *domain.RetryError.
+func (s *UsersService) authenticate(ctx context.Context, login string, password string) (domain.User, error) {
+	if s.Protection.Limiter != nil {
+		err := s.Protection.Limiter.Allow(ctx, "login:"+login)
+		if err != nil {
+			return domain.User{}, err
+		}
+	}
+
+	existingUser, err := s.Storage.GetUserByID(ctx, login)
 	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			s.verifyDummyPassword(password)
+		}
 		return domain.User{}, fmt.Errorf("failed to get user from storage: %w", err)
 	}
 
-	if existingUser.Password != user.Password {
-		return domain.User{}, fmt.Errorf("password incorrect: %w", err)
+	now := time.Now()
+	err = checkLocked(existingUser, now)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	needsRehash, err := s.Passwords.Verify(existingUser.PasswordAlgo, existingUser.Password, password)
+	if err != nil {
+		if errors.Is(err, passwords.ErrMismatch) {
+			s.registerLoginFailure(ctx, existingUser, now)
+			return domain.User{}, fmt.Errorf("password incorrect: %w", domain.ErrInvalidPassword)
+		}
+
+		return domain.User{}, fmt.Errorf("failed to verify password: %w", err)
+	}
+
+	// об отключении сообщаем только знающему пароль, чтобы не раскрывать состояние учетной записи
+	if !existingUser.DisabledAt.IsZero() {
+		return domain.User{}, fmt.Errorf("user %d: %w", existingUser.ID, domain.ErrUserDisabled)
+	}
+
+	if existingUser.FailedLogins > 0 || !existingUser.LockedUntil.IsZero() {
+		s.resetLoginFailures(ctx, existingUser)
+	}
+	if s.Protection.Limiter != nil {
+		// успешный вход не расходует лимит попыток по логину
+		err = s.Protection.Limiter.Reset(ctx, "login:"+login)
+		if err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to reset login rate limit", slog.Any("error", err))
+		}
+	}
+
+	if needsRehash {
+		// ошибка обновления хэша не должна мешать входу, попробуем снова при следующем входе
+		err = s.rehashPassword(ctx, &existingUser, password)
+		if err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "password rehash failed", slog.Int("user_id", existingUser.ID), slog.Any("error", err))
+		}
 	}
 
 	return existingUser, nil
 }
+
+// List возвращает страницу пользователей, начиная с позиции cursor.
+// Пустой cursor означает первую страницу.
+func (s *UsersService) List(ctx context.Context, params domain.UsersListParams, cursor string) (domain.UsersPage, error) {
+	switch params.SortBy {
+	case "":
+		params.SortBy = domain.UsersSortByID
+	case domain.UsersSortByID, domain.UsersSortByLogin, domain.UsersSortByPasswordExpires:
+	default:
+		return domain.UsersPage{}, fmt.Errorf("unknown sort field %q: %w", params.SortBy, domain.ErrInvalidParams)
+	}
+
+	switch {
+	case params.Limit == 0:
+		params.Limit = DefaultUsersPageSize
+	case params.Limit < 0 || params.Limit > MaxUsersPageSize:
+		return domain.UsersPage{}, fmt.Errorf("limit must be between 1 and %d: %w", MaxUsersPageSize, domain.ErrInvalidParams)
+	}
+
+	if cursor != "" {
+		after, err := decodeUsersCursor(params, cursor)
+		if err != nil {
+			return domain.UsersPage{}, err
+		}
+		params.After = after
+	}
+
+	// запрашиваем на одного пользователя больше, чтобы понять, есть ли следующая страница
+	pageSize := params.Limit
+	params.Limit++
+	users, err := s.Storage.ListUsers(ctx, params)
+	if err != nil {
+		return domain.UsersPage{}, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	page := domain.UsersPage{
+		Users: users,
+	}
+	if len(users) > pageSize {
+		page.Users = users[:pageSize]
+		page.NextCursor, err = encodeUsersCursor(params, page.Users[pageSize-1])
+		if err != nil {
+			return domain.UsersPage{}, err
+		}
+	}
+
+	return page, nil
+}
+
+// SetRoles заменяет роли пользователя. Новые права применяются к сессиям при следующей проверке,
+// а в режиме токенов - при обновлении access токена.
+func (s *UsersService) SetRoles(ctx context.Context, login string, roles []string) error {
+	if len(roles) == 0 {
+		return fmt.Errorf("at least one role is required: %w", domain.ErrInvalidParams)
+	}
+
+	user, err := s.Storage.GetUserByID(ctx, login)
+	if err != nil {
+		return fmt.Errorf("failed to get user from storage: %w", err)
+	}
+
+	err = s.Storage.SetUserRoles(ctx, user.ID, roles)
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditRolesChange, Target: domain.UserTarget(user.ID)}, err)
+	if err != nil {
+		return fmt.Errorf("failed to set roles of user %d: %w", user.ID, err)
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "user roles changed",
+		slog.Int("user_id", user.ID), slog.Any("roles", roles))
+
+	return nil
+}
+
+// Get возвращает пользователя вместе с ролями.
+func (s *UsersService) Get(ctx context.Context, id int) (domain.User, error) {
+	user, err := s.Storage.GetUser(ctx, id)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to get user %d: %w", id, err)
+	}
+
+	return s.withRoles(ctx, user)
+}
+
+// Update меняет контактные данные и роли пользователя.
+func (s *UsersService) Update(ctx context.Context, id int, update domain.UserUpdate) (domain.User, error) {
+	user, err := s.update(ctx, id, update)
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditUserUpdate, Target: domain.UserTarget(id)}, err)
+
+	return user, err
+}
+
+func (s *UsersService) update(ctx context.Context, id int, update domain.UserUpdate) (domain.User, error) {
+	if update.Roles != nil && len(update.Roles) == 0 {
+		return domain.User{}, fmt.Errorf("at least one role is required: %w", domain.ErrInvalidParams)
+	}
+
+	user, err := s.Storage.GetUser(ctx, id)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to get user %d: %w", id, err)
+	}
+
+	if update.Email != nil {
+		user.Email = *update.Email
+	}
+	if update.NotificationChannel != nil {
+		user.NotificationChannel = *update.NotificationChannel
+	}
+	err = validateNotificationChannel(user)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user, err = s.Storage.UpdateUser(ctx, user)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to update user %d: %w", id, err)
+	}
+
+	if update.Roles != nil {
+		err = s.Storage.SetUserRoles(ctx, id, update.Roles)
+		recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditRolesChange, Target: domain.UserTarget(id)}, err)
+		if err != nil {
+			return domain.User{}, fmt.Errorf("failed to set roles of user %d: %w", id, err)
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "user roles changed", slog.Int("user_id", id), slog.Any("roles", update.Roles))
+	}
+
+	return s.withRoles(ctx, user)
+}
+
+// SetDisabled отключает или включает пользователя. Отключенный пользователь не может войти,
+// а его сессии перестают проходить проверку.
+func (s *UsersService) SetDisabled(ctx context.Context, id int, disabled bool) (domain.User, error) {
+	var disabledAt time.Time
+	if disabled {
+		disabledAt = time.Now().UTC()
+	}
+
+	user, err := s.Storage.SetUserDisabled(ctx, id, disabledAt)
+	action := domain.AuditUserEnable
+	if disabled {
+		action = domain.AuditUserDisable
+	}
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: action, Target: domain.UserTarget(id)}, err)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to update user %d: %w", id, err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "user status changed", slog.Int("user_id", id), slog.Bool("disabled", disabled))
+
+	return s.withRoles(ctx, user)
+}
+
+// Delete мягко удаляет пользователя: запись остается в хранилище, но больше не находится.
+func (s *UsersService) Delete(ctx context.Context, id int) error {
+	err := s.Storage.DeleteUser(ctx, id, time.Now().UTC())
+	recordAudit(ctx, s.Audit, domain.AuditEvent{Action: domain.AuditUserDelete, Target: domain.UserTarget(id)}, err)
+	if err != nil {
+		return fmt.Errorf("failed to delete user %d: %w", id, err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "user deleted", slog.Int("user_id", id))
+
+	return nil
+}
+
+func (s *UsersService) withRoles(ctx context.Context, user domain.User) (domain.User, error) {
+	access, err := s.Storage.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("failed to get roles of user %d: %w", user.ID, err)
+	}
+	user.Roles = access.Roles
+
+	return user, nil
+}
+
+func (s *UsersService) rehashPassword(ctx context.Context, user *domain.User, password string) error {
+	hash, algorithm, err := s.Passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	err = s.Storage.UpdatePassword(ctx, user.ID, hash, algorithm)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hash
+	user.PasswordAlgo = algorithm
+
+	return nil
+}
+
+// registerLoginFailure учитывает неудачную попытку и блокирует пользователя после MaxFailures попыток подряд.
+// Ошибки хранилища только логируются, чтобы не раскрывать их вместо ошибки неверного пароля.
+func (s *UsersService) registerLoginFailure(ctx context.Context, user domain.User, now time.Time) {
+	if s.Protection.MaxFailures <= 0 {
+		return
+	}
+
+	logger := logging.FromContext(ctx).With(slog.Int("user_id", user.ID))
+	updated, err := s.Storage.RegisterLoginFailure(ctx, user.ID, s.Protection.MaxFailures, now.Add(s.Protection.LockoutDuration))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to register login failure", slog.Any("error", err))
+		return
+	}
+	if !updated.LockedUntil.After(now) {
+		return
+	}
+
+	logger.WarnContext(ctx, "account locked", slog.Time("locked_until", updated.LockedUntil))
+	metrics.AccountLockouts.Inc()
+	s.addLockoutEvent(ctx, logger, domain.LockoutEvent{
+		UserID: user.ID,
+		Event:  domain.LockoutEventLocked,
+		Reason: fmt.Sprintf("%d failed login attempts", s.Protection.MaxFailures),
+	})
+}
+
+// resetLoginFailures сбрасывает неудачные попытки после успешного входа, в том числе истекшую блокировку.
+func (s *UsersService) resetLoginFailures(ctx context.Context, user domain.User) {
+	logger := logging.FromContext(ctx).With(slog.Int("user_id", user.ID))
+	err := s.Storage.ResetLoginFailures(ctx, user.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to reset login failures", slog.Any("error", err))
+		return
+	}
+	if user.LockedUntil.IsZero() {
+		return
+	}
+
+	logger.InfoContext(ctx, "account unlocked")
+	s.addLockoutEvent(ctx, logger, domain.LockoutEvent{
+		UserID: user.ID,
+		Event:  domain.LockoutEventUnlocked,
+		Reason: "lockout expired",
+	})
+}
+
+func (s *UsersService) addLockoutEvent(ctx context.Context, logger *slog.Logger, event domain.LockoutEvent) {
+	err := s.Storage.InsertLockoutEvent(ctx, event)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to save lockout event", slog.String("event", event.Event), slog.Any("error", err))
+	}
+}
+
+// userAuditEvent - событие пользователя с логином login. Пользователь user известен только после успешной проверки.
+func userAuditEvent(action string, login string, user domain.User) domain.AuditEvent {
+	event := domain.AuditEvent{Actor: login, Action: action}
+	if user.ID != 0 {
+		event.ActorID = user.ID
+		event.Target = domain.UserTarget(user.ID)
+	}
+
+	return event
+}
+
+func checkLocked(user domain.User, now time.Time) error {
+	if user.LockedUntil.After(now) {
+		return &domain.RetryError{
+			Err:        fmt.Errorf("user %d: %w", user.ID, domain.ErrAccountLocked),
+			RetryAfter: user.LockedUntil.Sub(now),
+		}
+	}
+
+	return nil
+}
+
+func validateNotificationChannel(user domain.User) error {
+	switch user.NotificationChannel {
+	case "", domain.NotificationChannelWebhook, domain.NotificationChannelLogFile:
+		return nil
+	case domain.NotificationChannelEmail:
+		if user.Email == "" {
+			return fmt.Errorf("email required for email notifications: %w", domain.ErrInvalidParams)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown notification channel %q: %w", user.NotificationChannel, domain.ErrInvalidParams)
+	}
+}
+
+func (s *UsersService) validatePassword(password string) error {
+	// ограничение алгоритма хэширования проверяется до политики, иначе Hash вернет внутреннюю ошибку
+	maxLength := s.Passwords.MaxLength()
+	if maxLength > 0 && len(password) > maxLength {
+		return domain.NewValidationError(domain.FieldError{
+			Field: "password", Code: "too_long", Message: fmt.Sprintf("must be at most %d bytes", maxLength),
+		})
+	}
+
+	err := s.Policy.Validate(password)
+	if err != nil {
+		return &domain.ValidationError{
+			Err:    domain.ErrWeakPassword,
+			Fields: []domain.FieldError{{Field: "password", Code: "policy", Message: err.Error()}},
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/passwords"
+	"movies-auth/users/internal/ratelimit"
+	"movies-auth/users/internal/storage/inmemory"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestUsersService(policy passwords.Policy, protection LoginProtection) (*UsersService, *inmemory.Storage) {
+	storage := inmemory.NewStorage()
+	manager := passwords.NewManager(passwords.NewBcryptHasher(4))
+
+	return NewUsersService(storage, manager, policy, protection, AccountVerification{}), storage
+}
+
+func TestUsersServiceChangePassword(t *testing.T) {
+	policy := passwords.Policy{MinLength: 8, RequireDigit: true, MaxAge: time.Hour, HistorySize: 2}
+	ctx := context.Background()
+
+	testCases := []struct {
+		name        string
+		password    string
+		newPassword string
+		wantErr     error
+	}{
+		{
+			name:        "fail_invalid_password",
+			password:    "wrong-password1",
+			newPassword: "password3",
+			wantErr:     domain.ErrInvalidPassword,
+		},
+		{
+			name:        "fail_weak_password",
+			password:    "password2",
+			newPassword: "short",
+			wantErr:     domain.ErrWeakPassword,
+		},
+		{
+			name:        "fail_current_password",
+			password:    "password2",
+			newPassword: "password2",
+			wantErr:     domain.ErrPasswordReused,
+		},
+		{
+			name:        "fail_password_in_history",
+			password:    "password2",
+			newPassword: "password1",
+			wantErr:     domain.ErrPasswordReused,
+		},
+		{
+			name:        "success_changed",
+			password:    "password2",
+			newPassword: "password3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, storage := newTestUsersService(policy, LoginProtection{})
+			_, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.ChangePassword(ctx, "user1", "password1", "password2", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = storage.UpdateNotificationSent(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := s.ChangePassword(ctx, "user1", tc.password, tc.newPassword, "")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			if user.NotificationSent {
+				t.Errorf("expected notification flag reset")
+			}
+			if user.PasswordExpires.Before(time.Now().Add(policy.MaxAge - time.Minute)) {
+				t.Errorf("expected password expiry reset, got: %v", user.PasswordExpires)
+			}
+			_, err = s.Login(ctx, domain.User{Login: "user1", Password: tc.newPassword})
+			if err != nil {
+				t.Errorf("expected login with new password, got: %v", err)
+			}
+		})
+	}
+}
+
+// countingHasher считает проверки паролей.
+type countingHasher struct {
+	PasswordHasher
+	verified int
+}
+
+func (h *countingHasher) Verify(algorithm, hash, password string) (bool, error) {
+	h.verified++
+	return h.PasswordHasher.Verify(algorithm, hash, password)
+}
+
+func TestUsersServiceCreateLongPassword(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUsersService(passwords.Policy{MinLength: 8}, LoginProtection{})
+
+	// bcrypt не принимает пароли длиннее 72 байт, это ошибка запроса, а не внутренняя
+	_, err := s.Create(ctx, domain.User{Login: "user1", Password: strings.Repeat("п", 40)})
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, domain.ErrInvalidParams) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrInvalidParams, err)
+	}
+	if len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "password" || validationErr.Fields[0].Code != "too_long" {
+		t.Errorf("expected password too_long field error, got: %+v", validationErr.Fields)
+	}
+
+	_, err = s.Create(ctx, domain.User{Login: "user1", Password: strings.Repeat("p", passwords.BcryptMaxLength)})
+	if err != nil {
+		t.Errorf("expected password of %d bytes accepted, got: %v", passwords.BcryptMaxLength, err)
+	}
+}
+
+func TestUsersServiceLoginUnknownUser(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUsersService(passwords.Policy{}, LoginProtection{})
+	hasher := &countingHasher{PasswordHasher: s.Passwords}
+	s.Passwords = hasher
+
+	// пароль проверяется и для неизвестного логина, чтобы время ответа не выдавало существующие логины
+	_, err := s.Login(ctx, domain.User{Login: "unknown", Password: "password1"})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+	if hasher.verified != 1 {
+		t.Errorf("expected password verifications: %d, got: %d", 1, hasher.verified)
+	}
+}
+
+func TestUsersServiceLoginExpiredPassword(t *testing.T) {
+	ctx := context.Background()
+	s, storage := newTestUsersService(passwords.Policy{MinLength: 8}, LoginProtection{})
+
+	hash, algorithm, err := s.Passwords.Hash("password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.Insert(ctx, domain.User{Login: "user1", Password: hash, PasswordAlgo: algorithm, PasswordExpires: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if !errors.Is(err, domain.ErrPasswordExpired) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrPasswordExpired, err)
+	}
+
+	_, err = s.ChangePassword(ctx, "user1", "password1", "password2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password2"})
+	if err != nil {
+		t.Errorf("expected login after password change, got: %v", err)
+	}
+}
+
+func TestUsersServiceLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	s, storage := newTestUsersService(passwords.Policy{}, LoginProtection{MaxFailures: 3, LockoutDuration: time.Hour})
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = s.Login(ctx, domain.User{Login: "user1", Password: "wrong"})
+		if !errors.Is(err, domain.ErrInvalidPassword) {
+			t.Fatalf("attempt %d: expected error: %v, got: %v", i+1, domain.ErrInvalidPassword, err)
+		}
+	}
+
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	var retryErr *domain.RetryError
+	if !errors.As(err, &retryErr) || !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("expected locked account, got: %v", err)
+	}
+	if retryErr.RetryAfter <= 59*time.Minute || retryErr.RetryAfter > time.Hour {
+		t.Errorf("expected retry after lockout duration, got: %v", retryErr.RetryAfter)
+	}
+
+	// истекшая блокировка снимается при успешном входе
+	_, err = storage.RegisterLoginFailure(ctx, user.ID, 1, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatalf("expected login after lockout expired, got: %v", err)
+	}
+
+	events, err := storage.GetLockoutEvents(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Event != domain.LockoutEventLocked || events[1].Event != domain.LockoutEventUnlocked {
+		t.Errorf("expected locked and unlocked events, got: %+v", events)
+	}
+
+	loggedUser, err := storage.GetUserByID(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedUser.FailedLogins != 0 || !loggedUser.LockedUntil.IsZero() {
+		t.Errorf("expected failures reset, got: %+v", loggedUser)
+	}
+}
+
+func TestUsersServiceLoginRateLimit(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemory.NewStorage()
+	protection := LoginProtection{Limiter: ratelimit.NewLimiter(storage, 2, time.Minute)}
+	s := NewUsersService(storage, passwords.NewManager(passwords.NewBcryptHasher(4)), passwords.Policy{}, protection, AccountVerification{})
+
+	_, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// успешные входы не расходуют лимит
+	for i := 0; i < 3; i++ {
+		_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = s.Login(ctx, domain.User{Login: "user1", Password: "wrong-password"})
+		if !errors.Is(err, domain.ErrInvalidPassword) {
+			t.Fatalf("expected error: %v, got: %v", domain.ErrInvalidPassword, err)
+		}
+	}
+
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	var retryErr *domain.RetryError
+	if !errors.As(err, &retryErr) || !errors.Is(err, domain.ErrTooManyRequests) {
+		t.Fatalf("expected rate limit error, got: %v", err)
+	}
+	if retryErr.RetryAfter <= 0 || retryErr.RetryAfter > time.Minute {
+		t.Errorf("expected retry after window reset, got: %v", retryErr.RetryAfter)
+	}
+
+	// лимит считается по логину
+	_, err = s.Login(ctx, domain.User{Login: "user2", Password: "password1"})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+}
+
+func TestUsersServiceSetRoles(t *testing.T) {
+	ctx := context.Background()
+	s, storage := newTestUsersService(passwords.Policy{}, LoginProtection{})
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		login   string
+		roles   []string
+		wantErr error
+	}{
+		{name: "fail_no_roles", login: "user1", wantErr: domain.ErrInvalidParams},
+		{name: "fail_unknown_user", login: "user2", roles: []string{domain.RoleEditor}, wantErr: domain.ErrNotFound},
+		{name: "fail_unknown_role", login: "user1", roles: []string{"owner"}, wantErr: domain.ErrNotFound},
+		{name: "success", login: "user1", roles: []string{domain.RoleEditor}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.SetRoles(ctx, tc.login, tc.roles)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	access, err := storage.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !access.HasPermission(domain.PermissionMoviesWrite) || access.HasPermission(domain.PermissionUsersRead) {
+		t.Errorf("expected editor access, got: %+v", access)
+	}
+}
+
+func TestUsersServiceDisabled(t *testing.T) {
+	ctx := context.Background()
+	s, storage := newTestUsersService(passwords.Policy{}, LoginProtection{})
+	sessions := NewSessionService(storage, nil, time.Hour, time.Hour)
+
+	user, err := s.Create(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := sessions.CreateSession(ctx, user.ID, domain.Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.SetDisabled(ctx, user.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "wrong"})
+	if !errors.Is(err, domain.ErrInvalidPassword) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidPassword, err)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if !errors.Is(err, domain.ErrUserDisabled) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrUserDisabled, err)
+	}
+	_, err = sessions.ValidateSession(ctx, session.Key)
+	if !errors.Is(err, domain.ErrUserDisabled) {
+		t.Errorf("expected session of disabled user rejected, got: %v", err)
+	}
+
+	_, err = s.SetDisabled(ctx, user.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Login(ctx, domain.User{Login: "user1", Password: "password1"})
+	if err != nil {
+		t.Errorf("expected enabled user to log in, got: %v", err)
+	}
+
+	err = s.Delete(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Get(ctx, user.ID)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected deleted user not found, got: %v", err)
+	}
+}
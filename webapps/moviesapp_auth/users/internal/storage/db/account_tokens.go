@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"movies-auth/users/internal/domain"
+	"time"
+)
+
+const accountTokenColumns = `id, user_id, purpose, token_hash, email, expires_at, used_at, created_at`
+
+func accountTokenFields(token *domain.AccountToken) []any {
+	return []any{&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.Email, &token.ExpiresAt,
+		nullTime{&token.UsedAt}, &token.CreatedAt}
+}
+
+// InsertAccountToken сохраняет токен. Прежние неиспользованные токены пользователя
+// с тем же назначением перестают действовать.
+func (s *DbStorage) InsertAccountToken(ctx context.Context, token domain.AccountToken) (domain.AccountToken, error) {
+	query := `WITH old AS (
+			UPDATE account_tokens SET used_at = now() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+		)
+		INSERT INTO account_tokens (user_id, purpose, token_hash, email, expires_at) VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + accountTokenColumns
+
+	var inserted domain.AccountToken
+	err := s.db.QueryRowContext(ctx, query, token.UserID, token.Purpose, token.TokenHash, token.Email, token.ExpiresAt).
+		Scan(accountTokenFields(&inserted)...)
+	if err != nil {
+		return domain.AccountToken{}, mapError(err)
+	}
+
+	return inserted, nil
+}
+
+func (s *DbStorage) GetAccountToken(ctx context.Context, purpose string, tokenHash string) (domain.AccountToken, error) {
+	query := `SELECT ` + accountTokenColumns + ` FROM account_tokens WHERE purpose = $1 AND token_hash = $2`
+
+	var token domain.AccountToken
+	err := s.db.QueryRowContext(ctx, query, purpose, tokenHash).Scan(accountTokenFields(&token)...)
+	if err != nil {
+		return domain.AccountToken{}, mapError(err)
+	}
+
+	return token, nil
+}
+
+// UseAccountToken отмечает токен использованным. Использованный или истекший к usedAt токен
+// не находится, поэтому из двух одновременных попыток успешна только одна.
+func (s *DbStorage) UseAccountToken(ctx context.Context, id int, usedAt time.Time) error {
+	query := `UPDATE account_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL AND expires_at > $2`
+
+	res, err := s.db.ExecContext(ctx, query, id, usedAt)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// SetEmailVerified подтверждает email пользователя, если адрес пользователя не изменился.
+func (s *DbStorage) SetEmailVerified(ctx context.Context, userID int, email string, verifiedAt time.Time) error {
+	query := `UPDATE users SET email_verified_at = $3 WHERE id = $1 AND email = $2 AND deleted_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, userID, email, verifiedAt)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
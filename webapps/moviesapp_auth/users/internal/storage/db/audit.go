@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"movies-auth/users/internal/domain"
+	"strings"
+)
+
+func (s *DbStorage) InsertAuditEvent(ctx context.Context, event domain.AuditEvent) (domain.AuditEvent, error) {
+	query := `INSERT INTO audit_events
+			(actor_id, actor, action, target, ip, user_agent, request_id, outcome, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`
+
+	err := s.db.QueryRowContext(ctx, query, event.ActorID, event.Actor, event.Action, event.Target, event.IP,
+		event.UserAgent, event.RequestID, event.Outcome, event.Reason, event.CreatedAt).Scan(&event.ID)
+	if err != nil {
+		return domain.AuditEvent{}, mapError(err)
+	}
+
+	return event, nil
+}
+
+// ListAuditEvents возвращает до filter.Limit записей журнала от старых к новым.
+func (s *DbStorage) ListAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if filter.ActorID != 0 {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.AfterID != 0 {
+		addCondition("id > $%d", filter.AfterID)
+	}
+
+	query := `SELECT id, actor_id, actor, action, target, ip, user_agent, request_id, outcome, reason, created_at
+		FROM audit_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]domain.AuditEvent, 0, filter.Limit)
+	for rows.Next() {
+		var e domain.AuditEvent
+		err := rows.Scan(&e.ID, &e.ActorID, &e.Actor, &e.Action, &e.Target, &e.IP, &e.UserAgent, &e.RequestID,
+			&e.Outcome, &e.Reason, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,13 @@
+package db
+
+import (
+	"movies-auth/users/internal/storage/storagetest"
+	"movies-auth/users/internal/tests/pgtest"
+	"testing"
+)
+
+func TestStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		return NewDbStorage(pgtest.NewDB(t))
+	})
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"movies-auth/users/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// mapError приводит ошибки postgres к ошибкам domain, чтобы все хранилища вели себя одинаково.
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return fmt.Errorf("%s: %w", pgErr.ConstraintName, domain.ErrConflict)
+		case pgForeignKeyViolation:
+			return fmt.Errorf("%s: %w", pgErr.ConstraintName, domain.ErrNotFound)
+		}
+	}
+
+	return err
+}
+
+func requireAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"movies-auth/users/internal/domain"
+	"time"
+)
+
+// RegisterLoginFailure учитывает неудачную попытку входа. Попытка, на которой число неудач
+// достигает maxFailures, блокирует пользователя до lockedUntil и сбрасывает счетчик.
+func (s *DbStorage) RegisterLoginFailure(ctx context.Context, id int, maxFailures int, lockedUntil time.Time) (domain.User, error) {
+	query := `UPDATE users SET
+			failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+			locked_until = CASE WHEN failed_logins + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	var user domain.User
+	err := s.db.QueryRowContext(ctx, query, id, maxFailures, lockedUntil).Scan(userFields(&user)...)
+	if err != nil {
+		return domain.User{}, mapError(err)
+	}
+
+	return user, nil
+}
+
+// ResetLoginFailures сбрасывает счетчик неудачных попыток и снимает блокировку.
+func (s *DbStorage) ResetLoginFailures(ctx context.Context, id int) error {
+	query := `UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1`
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+func (s *DbStorage) InsertLockoutEvent(ctx context.Context, event domain.LockoutEvent) error {
+	query := `INSERT INTO lockout_events (user_id, event, reason) VALUES ($1, $2, $3)`
+	_, err := s.db.ExecContext(ctx, query, event.UserID, event.Event, event.Reason)
+
+	return mapError(err)
+}
+
+// GetLockoutEvents возвращает события блокировки пользователя от старых к новым.
+func (s *DbStorage) GetLockoutEvents(ctx context.Context, userID int) ([]domain.LockoutEvent, error) {
+	query := `SELECT id, user_id, event, reason, created_at FROM lockout_events WHERE user_id = $1 ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.LockoutEvent
+	for rows.Next() {
+		var event domain.LockoutEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Event, &event.Reason, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *DbStorage) IncrementRateLimit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	query := `INSERT INTO rate_limits (key, count, reset_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limits.reset_at <= $3 THEN 1 ELSE rate_limits.count + 1 END,
+			reset_at = CASE WHEN rate_limits.reset_at <= $3 THEN EXCLUDED.reset_at ELSE rate_limits.reset_at END
+		RETURNING count, reset_at`
+
+	var count int
+	var resetAt time.Time
+	err := s.db.QueryRowContext(ctx, query, key, now.Add(window), now).Scan(&count, &resetAt)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return count, resetAt, nil
+}
+
+func (s *DbStorage) DeleteRateLimit(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE key = $1`, key)
+
+	return err
+}
+
+func (s *DbStorage) DeleteExpiredRateLimits(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE reset_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"movies-auth/users/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EnqueueNotification ставит задание в очередь. Возвращает false, если задание
+// с тем же ключом идемпотентности уже есть.
+func (s *DbStorage) EnqueueNotification(ctx context.Context, job domain.NotificationJob) (bool, error) {
+	query := `INSERT INTO notification_jobs (user_id, kind, idempotency_key, status, next_attempt_at)
+		VALUES ($1, $2, $3, 'pending', $4)
+		ON CONFLICT (idempotency_key) DO NOTHING`
+
+	res, err := s.db.ExecContext(ctx, query, job.UserID, job.Kind, job.IdempotencyKey, job.NextAttemptAt)
+	if err != nil {
+		return false, mapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// ClaimNotifications захватывает до limit готовых заданий на время lease: ожидающие,
+// у которых наступило время попытки, и захваченные ранее, но не завершенные до истечения lease.
+// SKIP LOCKED не дает нескольким репликам захватить одно задание: строки, которые
+// сейчас захватывает другая транзакция, пропускаются, а не перечитываются после ее завершения.
+// Каждый захват выдает новый токен, поэтому прежний владелец задания с истекшим lease
+// больше не может его завершить.
+func (s *DbStorage) ClaimNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.NotificationJob, error) {
+	query := `WITH claimed AS (
+			UPDATE notification_jobs SET status = 'processing', attempts = attempts + 1, locked_until = $2, claim_token = $4
+			WHERE id IN (
+				SELECT id FROM notification_jobs
+				WHERE (status = 'pending' AND next_attempt_at <= $1) OR (status = 'processing' AND locked_until <= $1)
+				ORDER BY next_attempt_at, id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, user_id, kind, idempotency_key, status, attempts, next_attempt_at, locked_until, claim_token, last_error, created_at
+		)
+		SELECT c.id, c.user_id, c.kind, c.idempotency_key, c.status, c.attempts, c.next_attempt_at, c.locked_until, c.claim_token,
+			c.last_error, c.created_at,
+			u.id, u.login, u.password, u.password_algo, u.password_expires, u.notification_sent, u.email, u.notification_channel
+		FROM claimed c JOIN users u ON u.id = c.user_id
+		ORDER BY c.next_attempt_at, c.id`
+
+	rows, err := s.db.QueryContext(ctx, query, now, now.Add(lease), limit, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]domain.NotificationJob, 0, limit)
+	for rows.Next() {
+		var job domain.NotificationJob
+		var lockedUntil sql.NullTime
+		fields := []any{&job.ID, &job.UserID, &job.Kind, &job.IdempotencyKey, &job.Status, &job.Attempts, &job.NextAttemptAt,
+			&lockedUntil, &job.ClaimToken, &job.LastError, &job.CreatedAt}
+		err := rows.Scan(append(fields, userFields(&job.User)...)...)
+		if err != nil {
+			return nil, err
+		}
+		job.LockedUntil = lockedUntil.Time
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// CountReadyNotifications возвращает число ожидающих заданий, у которых наступило время попытки.
+func (s *DbStorage) CountReadyNotifications(ctx context.Context, now time.Time) (int, error) {
+	query := `SELECT count(*) FROM notification_jobs WHERE status = 'pending' AND next_attempt_at <= $1`
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, now).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CompleteNotification завершает задание, захваченное с токеном claimToken, и отмечает уведомление
+// пользователя отправленным. Если задание уже захвачено заново, возвращает domain.ErrLeaseLost.
+func (s *DbStorage) CompleteNotification(ctx context.Context, id int, claimToken uuid.UUID) error {
+	query := `WITH job AS (
+			UPDATE notification_jobs SET status = 'done', locked_until = NULL, claim_token = NULL
+			WHERE id = $1 AND claim_token = $2 AND status = 'processing'
+			RETURNING user_id
+		)
+		UPDATE users SET notification_sent = true WHERE id IN (SELECT user_id FROM job)`
+
+	res, err := s.db.ExecContext(ctx, query, id, claimToken)
+	if err != nil {
+		return err
+	}
+
+	return requireClaim(res)
+}
+
+// RetryNotification возвращает задание в очередь с попыткой не раньше nextAttemptAt.
+func (s *DbStorage) RetryNotification(ctx context.Context, id int, claimToken uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	query := `UPDATE notification_jobs SET status = 'pending', next_attempt_at = $3, last_error = $4, locked_until = NULL, claim_token = NULL
+		WHERE id = $1 AND claim_token = $2 AND status = 'processing'`
+
+	res, err := s.db.ExecContext(ctx, query, id, claimToken, nextAttemptAt, lastError)
+	if err != nil {
+		return err
+	}
+
+	return requireClaim(res)
+}
+
+// DeadLetterNotification переводит задание в dead, больше оно не захватывается.
+func (s *DbStorage) DeadLetterNotification(ctx context.Context, id int, claimToken uuid.UUID, lastError string) error {
+	query := `UPDATE notification_jobs SET status = 'dead', last_error = $3, locked_until = NULL, claim_token = NULL
+		WHERE id = $1 AND claim_token = $2 AND status = 'processing'`
+
+	res, err := s.db.ExecContext(ctx, query, id, claimToken, lastError)
+	if err != nil {
+		return err
+	}
+
+	return requireClaim(res)
+}
+
+// requireClaim возвращает domain.ErrLeaseLost, если задание не изменено: его захватил другой обработчик
+// или его уже нет.
+func requireClaim(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrLeaseLost
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"movies-auth/users/internal/domain"
+	"strings"
+)
+
+// SetUserRoles заменяет роли пользователя. Для неизвестной роли или пользователя возвращает domain.ErrNotFound.
+func (s *DbStorage) SetUserRoles(ctx context.Context, userID int, roles []string) error {
+	// роли передаются строкой через запятую, названия ролей запятых не содержат
+	query := `WITH d AS (
+			DELETE FROM user_roles WHERE user_id = $1 AND role <> ALL (string_to_array($2, ','))
+		)
+		INSERT INTO user_roles (user_id, role) SELECT $1, unnest(string_to_array($2, ','))
+		ON CONFLICT DO NOTHING`
+
+	_, err := s.db.ExecContext(ctx, query, userID, strings.Join(roles, ","))
+
+	return mapError(err)
+}
+
+// GetUserAccess возвращает роли пользователя и права, которые они дают.
+// Для неизвестного или удаленного пользователя возвращает domain.ErrNotFound.
+func (s *DbStorage) GetUserAccess(ctx context.Context, userID int) (domain.Access, error) {
+	query := `SELECT
+			u.disabled_at IS NOT NULL,
+			COALESCE(string_agg(DISTINCT ur.role, ',' ORDER BY ur.role), ''),
+			COALESCE(string_agg(DISTINCT rp.permission, ',' ORDER BY rp.permission), '')
+		FROM users u
+		LEFT JOIN user_roles ur ON ur.user_id = u.id
+		LEFT JOIN role_permissions rp ON rp.role = ur.role
+		WHERE u.id = $1 AND u.deleted_at IS NULL
+		GROUP BY u.id`
+
+	var access domain.Access
+	var roles, permissions string
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&access.Disabled, &roles, &permissions)
+	if err != nil {
+		return domain.Access{}, mapError(err)
+	}
+	access.Roles = splitList(roles)
+	access.Permissions = splitList(permissions)
+
+	return access, nil
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+
+	return strings.Split(s, ",")
+}
@@ -1,45 +1,150 @@
 package db
 
 import (
+	"context"
 	"movies-auth/users/internal/domain"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-func (s *DbStorage) InsertSession(session domain.Session) (domain.Session, error) {
-	query := `INSERT INTO sessions (key, userid, startedat) VALUES ($1, $2, $3) RETURNING id, key, userid, startedat`
+const sessionColumns = `id, key, userid, startedat, expiresat, lastseenat, refresh_token_hash, user_agent, ip`
+
+// sessionFields возвращает поля сессии в порядке sessionColumns.
+func sessionFields(session *domain.Session) []any {
+	return []any{&session.ID, &session.Key, &session.UserID, &session.StartedAt, &session.ExpiresAt, &session.LastSeenAt,
+		&session.RefreshTokenHash, &session.UserAgent, &session.IP}
+}
+
+func (s *DbStorage) InsertSession(ctx context.Context, session domain.Session) (domain.Session, error) {
+	query := `INSERT INTO sessions (key, userid, startedat, expiresat, lastseenat, refresh_token_hash, user_agent, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + sessionColumns
 
 	var newSession domain.Session
 	err := s.db.
-		QueryRow(query, session.Key, session.UserID, session.StartedAt).
-		Scan(&newSession.ID, &newSession.Key, &newSession.UserID, &newSession.StartedAt)
+		QueryRowContext(ctx, query, session.Key, session.UserID, session.StartedAt, session.ExpiresAt, session.LastSeenAt, session.RefreshTokenHash,
+			session.UserAgent, session.IP).
+		Scan(sessionFields(&newSession)...)
 	if err != nil {
-		return domain.Session{}, err
+		return domain.Session{}, mapError(err)
 	}
 
 	return newSession, nil
 }
 
-func (s *DbStorage) GetSessionByKey(key uuid.UUID) (domain.Session, error) {
-	query := `SELECT id, key, userid, startedat FROM sessions WHERE key = $1`
+func (s *DbStorage) GetSessionByKey(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE key = $1`
 
 	var newSession domain.Session
-	err := s.db.QueryRow(query, key).
-		Scan(&newSession.ID, &newSession.Key, &newSession.UserID, &newSession.StartedAt)
+	err := s.db.QueryRowContext(ctx, query, key).Scan(sessionFields(&newSession)...)
 	if err != nil {
-		return domain.Session{}, err
+		return domain.Session{}, mapError(err)
 	}
 
 	return newSession, nil
 }
 
-func (s *DbStorage) DeleteSessionByKey(key uuid.UUID) error {
+func (s *DbStorage) UpdateSessionLastSeen(ctx context.Context, key uuid.UUID, lastSeenAt time.Time) error {
+	query := `UPDATE sessions SET lastseenat = $1 WHERE key = $2`
+
+	res, err := s.db.ExecContext(ctx, query, lastSeenAt, key)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// RotateRefreshToken заменяет хэш refresh токена, только если текущий хэш равен oldHash,
+// иначе возвращает domain.ErrNotFound.
+func (s *DbStorage) RotateRefreshToken(ctx context.Context, key uuid.UUID, oldHash string, newHash string, lastSeenAt time.Time) error {
+	query := `UPDATE sessions SET refresh_token_hash = $3, lastseenat = $4 WHERE key = $1 AND refresh_token_hash = $2`
+
+	res, err := s.db.ExecContext(ctx, query, key, oldHash, newHash, lastSeenAt)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// GetUserSessions возвращает сессии пользователя от старых к новым.
+func (s *DbStorage) GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE userid = $1 ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.Session, 0)
+	for rows.Next() {
+		var session domain.Session
+		err := rows.Scan(sessionFields(&session)...)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// DeleteUserSession удаляет сессию id, только если она принадлежит пользователю userID, и возвращает ее ключ.
+func (s *DbStorage) DeleteUserSession(ctx context.Context, userID int, id int) (uuid.UUID, error) {
+	query := `DELETE FROM sessions WHERE id = $1 AND userid = $2 RETURNING key`
+
+	var key uuid.UUID
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(&key)
+	if err != nil {
+		return uuid.UUID{}, mapError(err)
+	}
+
+	return key, nil
+}
+
+// DeleteUserSessions удаляет все сессии пользователя и возвращает удаленные сессии.
+func (s *DbStorage) DeleteUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	query := `DELETE FROM sessions WHERE userid = $1 RETURNING ` + sessionColumns
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.Session, 0)
+	for rows.Next() {
+		var session domain.Session
+		if err := rows.Scan(sessionFields(&session)...); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (s *DbStorage) DeleteSessionByKey(ctx context.Context, key uuid.UUID) error {
 	query := `DELETE FROM sessions WHERE key = $1`
 
-	_, err := s.db.Exec(query, key)
+	_, err := s.db.ExecContext(ctx, query, key)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (s *DbStorage) DeleteExpiredSessions(ctx context.Context, now time.Time, idleTimeout time.Duration) (int64, error) {
+	query := `DELETE FROM sessions WHERE expiresat <= $1 OR lastseenat <= $2`
+
+	res, err := s.db.ExecContext(ctx, query, now, now.Add(-idleTimeout))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"movies-auth/users/internal/logging"
+	"movies-auth/users/internal/metrics"
+	"time"
+)
+
+// tracedDB пишет в лог запроса каждый SQL-запрос с его длительностью
+// и учитывает ее в метриках.
+type tracedDB struct {
+	db *sql.DB
+}
+
+func (t tracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := t.db.ExecContext(ctx, query, args...)
+	t.trace(ctx, "exec", query, start, err)
+
+	return res, err
+}
+
+func (t tracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	t.trace(ctx, "query", query, start, err)
+
+	return rows, err
+}
+
+func (t tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := t.db.QueryRowContext(ctx, query, args...)
+	t.trace(ctx, "query_row", query, start, row.Err())
+
+	return row
+}
+
+func (t tracedDB) trace(ctx context.Context, operation string, query string, start time.Time, err error) {
+	duration := time.Since(start)
+
+	status := "ok"
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		status = "error"
+	}
+	metrics.DBQueryDuration.With(operation, status).Observe(duration.Seconds())
+
+	logger := logging.FromContext(ctx)
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("query", query),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "db query", attrs...)
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// SetTOTPSecret начинает подключение второго фактора с секретом secret.
+// Пользователь с уже включенным вторым фактором не находится.
+func (s *DbStorage) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	query := `UPDATE users SET totp_secret = $2, totp_last_counter = 0
+		WHERE id = $1 AND totp_enabled_at IS NULL AND deleted_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// EnableTOTP включает второй фактор с секретом, сохраненным SetTOTPSecret,
+// и заменяет коды восстановления пользователя на codeHashes.
+func (s *DbStorage) EnableTOTP(ctx context.Context, userID int, enabledAt time.Time, codeHashes []string) error {
+	query := `WITH u AS (
+			UPDATE users SET totp_enabled_at = $2
+			WHERE id = $1 AND totp_secret <> '' AND totp_enabled_at IS NULL AND deleted_at IS NULL
+			RETURNING id
+		), d AS (
+			DELETE FROM recovery_codes WHERE user_id IN (SELECT id FROM u)
+		)
+		INSERT INTO recovery_codes (user_id, code_hash) SELECT u.id, unnest(string_to_array($3, ',')) FROM u`
+
+	res, err := s.db.ExecContext(ctx, query, userID, enabledAt, strings.Join(codeHashes, ","))
+	if err != nil {
+		return mapError(err)
+	}
+
+	return requireAffected(res)
+}
+
+// DisableTOTP выключает второй фактор и удаляет коды восстановления.
+func (s *DbStorage) DisableTOTP(ctx context.Context, userID int) error {
+	query := `WITH u AS (
+			UPDATE users SET totp_secret = '', totp_enabled_at = NULL, totp_last_counter = 0
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING id
+		), d AS (
+			DELETE FROM recovery_codes WHERE user_id IN (SELECT id FROM u)
+		)
+		SELECT id FROM u`
+
+	var id int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&id)
+
+	return mapError(err)
+}
+
+// UseTOTPCounter запоминает шаг принятого кода. Шаг не новее последнего принятого не находится,
+// поэтому один код нельзя использовать дважды.
+func (s *DbStorage) UseTOTPCounter(ctx context.Context, userID int, counter int64) error {
+	query := `UPDATE users SET totp_last_counter = $2 WHERE id = $1 AND totp_last_counter < $2`
+
+	res, err := s.db.ExecContext(ctx, query, userID, counter)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// UseRecoveryCode отмечает код восстановления использованным, использованный код не находится.
+func (s *DbStorage) UseRecoveryCode(ctx context.Context, userID int, codeHash string, usedAt time.Time) error {
+	query := `UPDATE recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, userID, codeHash, usedAt)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// CountRecoveryCodes возвращает число неиспользованных кодов восстановления.
+func (s *DbStorage) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	query := `SELECT count(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+
+	return count, err
+}
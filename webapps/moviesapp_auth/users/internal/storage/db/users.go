@@ -3,43 +3,140 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"movies-auth/users/internal/domain"
+	"strings"
+	"time"
 )
 
 type DbStorage struct {
-	db *sql.DB
+	db tracedDB
 }
 
 func NewDbStorage(dbCon *sql.DB) *DbStorage {
 	return &DbStorage{
-		db: dbCon,
+		db: tracedDB{db: dbCon},
 	}
 }
 
+const userColumns = `id, login, password, password_algo, password_expires, notification_sent, email, notification_channel,
+	failed_logins, locked_until, disabled_at, deleted_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter`
+
+// userFields возвращает поля пользователя в порядке userColumns.
+func userFields(user *domain.User) []any {
+	return []any{&user.ID, &user.Login, &user.Password, &user.PasswordAlgo, &user.PasswordExpires, &user.NotificationSent,
+		&user.Email, &user.NotificationChannel, &user.FailedLogins, nullTime{&user.LockedUntil},
+		nullTime{&user.DisabledAt}, nullTime{&user.DeletedAt}, nullTime{&user.EmailVerifiedAt},
+		&user.TOTPSecret, nullTime{&user.TOTPEnabledAt}, &user.TOTPLastCounter}
+}
+
+// nullTime сканирует NULL как нулевое время.
+type nullTime struct {
+	t *time.Time
+}
+
+func (n nullTime) Scan(src any) error {
+	var nt sql.NullTime
+	err := nt.Scan(src)
+	if err != nil {
+		return err
+	}
+	*n.t = nt.Time
+
+	return nil
+}
+
 func (s *DbStorage) Insert(ctx context.Context, user domain.User) (domain.User, error) {
-	query := `INSERT INTO users (login, password) VALUES ($1, $2) RETURNING id, login, password`
+	// пароль нового пользователя сразу попадает в историю паролей, а сам он получает роль по умолчанию
+	query := `WITH u AS (
+			INSERT INTO users (login, password, password_algo, password_expires, email, notification_channel)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING ` + userColumns + `
+		), h AS (
+			INSERT INTO password_history (user_id, password, password_algo) SELECT id, password, password_algo FROM u
+		), r AS (
+			INSERT INTO user_roles (user_id, role) SELECT id, $7 FROM u
+		)
+		SELECT ` + userColumns + ` FROM u`
 
 	var newUser domain.User
-	err := s.db.QueryRowContext(ctx, query, user.Login, user.Password).Scan(&newUser.ID, &newUser.Login, &newUser.Password)
+	err := s.db.QueryRowContext(ctx, query, user.Login, user.Password, user.PasswordAlgo, user.PasswordExpires, user.Email, user.NotificationChannel,
+		domain.DefaultRole).
+		Scan(userFields(&newUser)...)
 	if err != nil {
-		return domain.User{}, err
+		return domain.User{}, mapError(err)
 	}
 
 	return newUser, nil
 }
 
 func (s *DbStorage) GetUserByID(ctx context.Context, login string) (domain.User, error) {
-	query := `SELECT id, login, password, notification_sent FROM users WHERE login = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE login = $1 AND deleted_at IS NULL`
 
 	var newUser domain.User
-	err := s.db.QueryRowContext(ctx, query, login).Scan(&newUser.ID, &newUser.Login, &newUser.Password, &newUser.NotificationSent)
+	err := s.db.QueryRowContext(ctx, query, login).Scan(userFields(&newUser)...)
 	if err != nil {
-		return domain.User{}, err
+		return domain.User{}, mapError(err)
 	}
 
 	return newUser, nil
 }
 
+// GetUser возвращает пользователя по id, удаленные пользователи не находятся.
+func (s *DbStorage) GetUser(ctx context.Context, id int) (domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+
+	var user domain.User
+	err := s.db.QueryRowContext(ctx, query, id).Scan(userFields(&user)...)
+	if err != nil {
+		return domain.User{}, mapError(err)
+	}
+
+	return user, nil
+}
+
+// UpdateUser сохраняет email и канал уведомлений пользователя. Новый email нужно подтвердить заново.
+func (s *DbStorage) UpdateUser(ctx context.Context, user domain.User) (domain.User, error) {
+	query := `UPDATE users SET email = $2, notification_channel = $3,
+			email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING ` + userColumns
+
+	var updated domain.User
+	err := s.db.QueryRowContext(ctx, query, user.ID, user.Email, user.NotificationChannel).Scan(userFields(&updated)...)
+	if err != nil {
+		return domain.User{}, mapError(err)
+	}
+
+	return updated, nil
+}
+
+// SetUserDisabled отключает пользователя с момента disabledAt, нулевой disabledAt включает его обратно.
+func (s *DbStorage) SetUserDisabled(ctx context.Context, id int, disabledAt time.Time) (domain.User, error) {
+	query := `UPDATE users SET disabled_at = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING ` + userColumns
+
+	var user domain.User
+	err := s.db.QueryRowContext(ctx, query, id, sql.NullTime{Time: disabledAt, Valid: !disabledAt.IsZero()}).
+		Scan(userFields(&user)...)
+	if err != nil {
+		return domain.User{}, mapError(err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser помечает пользователя удаленным. Повторное удаление возвращает domain.ErrNotFound.
+func (s *DbStorage) DeleteUser(ctx context.Context, id int, deletedAt time.Time) error {
+	query := `UPDATE users SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, id, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
 func (s *DbStorage) IsUserExist(ctx context.Context, login string) (bool, error) {
 	query := `SELECT id FROM users WHERE login = $1`
 	var userID int
@@ -55,18 +152,71 @@ func (s *DbStorage) IsUserExist(ctx context.Context, login string) (bool, error)
 	return true, nil
 }
 
+func (s *DbStorage) UpdatePassword(ctx context.Context, id int, hash string, algorithm string) error {
+	query := `UPDATE users SET password = $1, password_algo = $2 WHERE id = $3`
+	res, err := s.db.ExecContext(ctx, query, hash, algorithm, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// ChangePassword устанавливает новый пароль со сроком действия expires, сбрасывает отметку
+// об уведомлении и добавляет пароль в историю.
+func (s *DbStorage) ChangePassword(ctx context.Context, id int, hash string, algorithm string, expires time.Time) error {
+	query := `WITH u AS (
+			UPDATE users SET password = $2, password_algo = $3,
+				password_expires = $4, notification_sent = false
+			WHERE id = $1
+			RETURNING id, password, password_algo
+		)
+		INSERT INTO password_history (user_id, password, password_algo) SELECT id, password, password_algo FROM u`
+
+	res, err := s.db.ExecContext(ctx, query, id, hash, algorithm, expires)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// GetPasswordHistory возвращает до limit последних паролей пользователя, начиная с текущего.
+func (s *DbStorage) GetPasswordHistory(ctx context.Context, id int, limit int) ([]domain.PasswordHistoryEntry, error) {
+	query := `SELECT password, password_algo, created_at FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []domain.PasswordHistoryEntry
+	for rows.Next() {
+		var entry domain.PasswordHistoryEntry
+		if err := rows.Scan(&entry.Hash, &entry.Algorithm, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
 func (s *DbStorage) UpdateNotificationSent(ctx context.Context, id int) error {
 	query := `UPDATE users SET notification_sent = true WHERE id = $1`
-	_, err := s.db.ExecContext(ctx, query, id)
+	res, err := s.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return requireAffected(res)
 }
 
 func (s *DbStorage) GetUsersWithExpiredPassword(ctx context.Context) ([]domain.User, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT * FROM users WHERE current_timestamp > password_expires AND notification_sent = false")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users
+		WHERE current_timestamp > password_expires AND notification_sent = false
+			AND disabled_at IS NULL AND deleted_at IS NULL`)
 	if err != nil {
 		return nil, err
 	}
@@ -75,11 +225,87 @@ func (s *DbStorage) GetUsersWithExpiredPassword(ctx context.Context) ([]domain.U
 	var users []domain.User
 	for rows.Next() {
 		var user domain.User
-		if err := rows.Scan(&user.ID, &user.Login, &user.Password, &user.PasswordExpires, &user.NotificationSent); err != nil {
+		if err := rows.Scan(userFields(&user)...); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
+}
+
+var usersSortColumns = map[string]string{
+	domain.UsersSortByID:              "id",
+	domain.UsersSortByLogin:           "login",
+	domain.UsersSortByPasswordExpires: "password_expires",
+}
+
+func (s *DbStorage) ListUsers(ctx context.Context, params domain.UsersListParams) ([]domain.User, error) {
+	column, ok := usersSortColumns[params.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %q: %w", params.SortBy, domain.ErrInvalidParams)
+	}
+
+	direction, cmp := "ASC", ">"
+	if params.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	conditions := []string{"deleted_at IS NULL"}
+	var args []any
+	if params.LoginPrefix != "" {
+		args = append(args, escapeLike(params.LoginPrefix)+"%")
+		conditions = append(conditions, fmt.Sprintf(`login LIKE $%d ESCAPE '\'`, len(args)))
+	}
+	if params.After != nil {
+		if column == "id" {
+			args = append(args, params.After.ID)
+			conditions = append(conditions, fmt.Sprintf("id %s $%d", cmp, len(args)))
+		} else {
+			args = append(args, sortValue(params.SortBy, *params.After), params.After.ID)
+			conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, cmp, len(args)-1, len(args)))
+		}
+	}
+
+	query := `SELECT id, login, password_expires, notification_sent, email, notification_channel FROM users
+		WHERE ` + strings.Join(conditions, " AND ")
+	if column == "id" {
+		query += fmt.Sprintf(" ORDER BY id %s", direction)
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+	}
+	args = append(args, params.Limit)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0, params.Limit)
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Login, &user.PasswordExpires, &user.NotificationSent, &user.Email, &user.NotificationChannel); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func sortValue(sortBy string, user domain.User) any {
+	switch sortBy {
+	case domain.UsersSortByLogin:
+		return user.Login
+	case domain.UsersSortByPasswordExpires:
+		return user.PasswordExpires
+	default:
+		return user.ID
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
@@ -1,36 +1,941 @@
 package inmemory
 
-import "movies-auth/users/internal/domain"
+import (
+	"context"
+	"movies-auth/users/internal/domain"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-type UsersStorage struct {
-	users []domain.User
+	"github.com/google/uuid"
+)
+
+// Storage - хранилище пользователей и сессий в памяти процесса.
+// Возвращает те же ошибки domain, что и db.DbStorage.
+type Storage struct {
+	mu       sync.RWMutex
+	users    []domain.User
+	sessions map[uuid.UUID]domain.Session
+	jobs     []domain.NotificationJob
+	// passwordHistory - пароли пользователей по id, от старых к новым
+	passwordHistory map[int][]domain.PasswordHistoryEntry
+	lockoutEvents   []domain.LockoutEvent
+	auditEvents     []domain.AuditEvent
+	rateLimits      map[string]rateLimit
+	// rolePermissions - права ролей, userRoles - роли пользователей по id
+	rolePermissions map[string][]string
+	userRoles       map[int][]string
+	accountTokens   []domain.AccountToken
+	// recoveryCodes - коды восстановления второго фактора по id пользователя
+	recoveryCodes map[int][]recoveryCode
+	lastUserID    int
+	lastSessionID int
+}
+
+type recoveryCode struct {
+	hash   string
+	usedAt time.Time
+}
+
+type rateLimit struct {
+	count   int
+	resetAt time.Time
 }
 
-func NewUsersStorage() *UsersStorage {
-	return &UsersStorage{
-		users: make([]domain.User, 0),
+func NewStorage() *Storage {
+	return &Storage{
+		users:           make([]domain.User, 0),
+		sessions:        make(map[uuid.UUID]domain.Session),
+		passwordHistory: make(map[int][]domain.PasswordHistoryEntry),
+		rateLimits:      make(map[string]rateLimit),
+		rolePermissions: domain.DefaultRolePermissions,
+		userRoles:       make(map[int][]string),
+		recoveryCodes:   make(map[int][]recoveryCode),
 	}
 }
 
-func (s *UsersStorage) Insert(user domain.User) (domain.User, error) {
-	var lastID int
-	if len(s.users) > 0 {
-		lastID = s.users[:len(s.users)-1][0].ID
+func (s *Storage) Insert(ctx context.Context, user domain.User) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndexByLogin(user.Login) >= 0 {
+		return domain.User{}, domain.ErrConflict
 	}
 
-	user.ID = lastID + 1
+	s.lastUserID++
+	user.ID = s.lastUserID
+	user.NotificationSent = false
+	user.FailedLogins = 0
+	user.LockedUntil = time.Time{}
+	user.DisabledAt = time.Time{}
+	user.DeletedAt = time.Time{}
+	user.EmailVerifiedAt = time.Time{}
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = time.Time{}
+	user.TOTPLastCounter = 0
+	user.Roles = nil
 
 	s.users = append(s.users, user)
+	s.addPasswordHistory(user)
+	s.userRoles[user.ID] = []string{domain.DefaultRole}
 
 	return user, nil
 }
 
-func (s *UsersStorage) IsUserExist(login string) (bool, error) {
+func (s *Storage) GetUserByID(ctx context.Context, login string) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.userIndexByLogin(login)
+	if i < 0 || !s.users[i].DeletedAt.IsZero() {
+		return domain.User{}, domain.ErrNotFound
+	}
+
+	return s.users[i], nil
+}
+
+func (s *Storage) GetUser(ctx context.Context, id int) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.activeUserIndexByID(id)
+	if i < 0 {
+		return domain.User{}, domain.ErrNotFound
+	}
+
+	return s.users[i], nil
+}
+
+func (s *Storage) UpdateUser(ctx context.Context, user domain.User) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.activeUserIndexByID(user.ID)
+	if i < 0 {
+		return domain.User{}, domain.ErrNotFound
+	}
+	if s.users[i].Email != user.Email {
+		s.users[i].EmailVerifiedAt = time.Time{}
+	}
+	s.users[i].Email = user.Email
+	s.users[i].NotificationChannel = user.NotificationChannel
+
+	return s.users[i], nil
+}
+
+func (s *Storage) SetUserDisabled(ctx context.Context, id int, disabledAt time.Time) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.activeUserIndexByID(id)
+	if i < 0 {
+		return domain.User{}, domain.ErrNotFound
+	}
+	s.users[i].DisabledAt = disabledAt
+
+	return s.users[i], nil
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, id int, deletedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.activeUserIndexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.users[i].DeletedAt = deletedAt
+
+	return nil
+}
+
+func (s *Storage) InsertAccountToken(ctx context.Context, token domain.AccountToken) (domain.AccountToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndexByID(token.UserID) < 0 {
+		return domain.AccountToken{}, domain.ErrNotFound
+	}
+	now := time.Now().UTC()
+	for i, t := range s.accountTokens {
+		if t.TokenHash == token.TokenHash {
+			return domain.AccountToken{}, domain.ErrConflict
+		}
+		if t.UserID == token.UserID && t.Purpose == token.Purpose && t.UsedAt.IsZero() {
+			s.accountTokens[i].UsedAt = now
+		}
+	}
+
+	token.ID = len(s.accountTokens) + 1
+	token.UsedAt = time.Time{}
+	token.CreatedAt = now
+	s.accountTokens = append(s.accountTokens, token)
+
+	return token, nil
+}
+
+func (s *Storage) GetAccountToken(ctx context.Context, purpose string, tokenHash string) (domain.AccountToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, t := range s.accountTokens {
+		if t.Purpose == purpose && t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+
+	return domain.AccountToken{}, domain.ErrNotFound
+}
+
+func (s *Storage) UseAccountToken(ctx context.Context, id int, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, t := range s.accountTokens {
+		if t.ID == id && t.UsedAt.IsZero() && t.ExpiresAt.After(usedAt) {
+			s.accountTokens[i].UsedAt = usedAt
+			return nil
+		}
+	}
+
+	return domain.ErrNotFound
+}
+
+func (s *Storage) SetEmailVerified(ctx context.Context, userID int, email string, verifiedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.activeUserIndexByID(userID)
+	if i < 0 || s.users[i].Email != email {
+		return domain.ErrNotFound
+	}
+	s.users[i].EmailVerifiedAt = verifiedAt
+
+	return nil
+}
+
+func (s *Storage) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.activeUserIndexByID(userID)
+	if i < 0 || !s.users[i].TOTPEnabledAt.IsZero() {
+		return domain.ErrNotFound
+	}
+	s.users[i].TOTPSecret = secret
+	s.users[i].TOTPLastCounter = 0
+
+	return nil
+}
+
+func (s *Storage) EnableTOTP(ctx context.Context, userID int, enabledAt time.Time, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.activeUserIndexByID(userID)
+	if i < 0 || s.users[i].TOTPSecret == "" || !s.users[i].TOTPEnabledAt.IsZero() {
+		return domain.ErrNotFound
+	}
+	s.users[i].TOTPEnabledAt = enabledAt
+
+	codes := make([]recoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, recoveryCode{hash: hash})
+	}
+	s.recoveryCodes[userID] = codes
+
+	return nil
+}
+
+func (s *Storage) DisableTOTP(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.activeUserIndexByID(userID)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.users[i].TOTPSecret = ""
+	s.users[i].TOTPEnabledAt = time.Time{}
+	s.users[i].TOTPLastCounter = 0
+	delete(s.recoveryCodes, userID)
+
+	return nil
+}
+
+func (s *Storage) UseTOTPCounter(ctx context.Context, userID int, counter int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndexByID(userID)
+	if i < 0 || s.users[i].TOTPLastCounter >= counter {
+		return domain.ErrNotFound
+	}
+	s.users[i].TOTPLastCounter = counter
+
+	return nil
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int, codeHash string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, code := range s.recoveryCodes[userID] {
+		if code.hash == codeHash && code.usedAt.IsZero() {
+			s.recoveryCodes[userID][i].usedAt = usedAt
+			return nil
+		}
+	}
+
+	return domain.ErrNotFound
+}
+
+func (s *Storage) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int
+	for _, code := range s.recoveryCodes[userID] {
+		if code.usedAt.IsZero() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *Storage) IsUserExist(ctx context.Context, login string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.userIndexByLogin(login) < 0 {
+		return false, domain.ErrNotFound
+	}
+
+	return true, nil
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, id int, hash string, algorithm string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.users[i].Password = hash
+	s.users[i].PasswordAlgo = algorithm
+
+	return nil
+}
+
+func (s *Storage) ChangePassword(ctx context.Context, id int, hash string, algorithm string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.users[i].Password = hash
+	s.users[i].PasswordAlgo = algorithm
+	s.users[i].PasswordExpires = expires
+	s.users[i].NotificationSent = false
+	s.addPasswordHistory(s.users[i])
+
+	return nil
+}
+
+func (s *Storage) GetPasswordHistory(ctx context.Context, id int, limit int) ([]domain.PasswordHistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := s.passwordHistory[id]
+	var history []domain.PasswordHistoryEntry
+	for i := len(all) - 1; i >= 0 && len(history) < limit; i-- {
+		history = append(history, all[i])
+	}
+
+	return history, nil
+}
+
+func (s *Storage) addPasswordHistory(user domain.User) {
+	s.passwordHistory[user.ID] = append(s.passwordHistory[user.ID], domain.PasswordHistoryEntry{
+		Hash:      user.Password,
+		Algorithm: user.PasswordAlgo,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+func (s *Storage) RegisterLoginFailure(ctx context.Context, id int, maxFailures int, lockedUntil time.Time) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndexByID(id)
+	if i < 0 {
+		return domain.User{}, domain.ErrNotFound
+	}
+	s.users[i].FailedLogins++
+	if s.users[i].FailedLogins >= maxFailures {
+		s.users[i].FailedLogins = 0
+		s.users[i].LockedUntil = lockedUntil
+	}
+
+	return s.users[i], nil
+}
+
+func (s *Storage) ResetLoginFailures(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.users[i].FailedLogins = 0
+	s.users[i].LockedUntil = time.Time{}
+
+	return nil
+}
+
+func (s *Storage) InsertLockoutEvent(ctx context.Context, event domain.LockoutEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndexByID(event.UserID) < 0 {
+		return domain.ErrNotFound
+	}
+	event.ID = len(s.lockoutEvents) + 1
+	event.CreatedAt = time.Now().UTC()
+	s.lockoutEvents = append(s.lockoutEvents, event)
+
+	return nil
+}
+
+func (s *Storage) GetLockoutEvents(ctx context.Context, userID int) ([]domain.LockoutEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []domain.LockoutEvent
+	for _, event := range s.lockoutEvents {
+		if event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (s *Storage) InsertAuditEvent(ctx context.Context, event domain.AuditEvent) (domain.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.ID = int64(len(s.auditEvents) + 1)
+	s.auditEvents = append(s.auditEvents, event)
+
+	return event, nil
+}
+
+func (s *Storage) ListAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]domain.AuditEvent, 0, filter.Limit)
+	for _, event := range s.auditEvents {
+		if len(events) == filter.Limit {
+			break
+		}
+		if event.ID <= filter.AfterID ||
+			(!filter.From.IsZero() && event.CreatedAt.Before(filter.From)) ||
+			(!filter.To.IsZero() && !event.CreatedAt.Before(filter.To)) ||
+			(filter.ActorID != 0 && event.ActorID != filter.ActorID) ||
+			(filter.Actor != "" && event.Actor != filter.Actor) ||
+			(filter.Action != "" && event.Action != filter.Action) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) IncrementRateLimit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit, ok := s.rateLimits[key]
+	if !ok || !limit.resetAt.After(now) {
+		limit = rateLimit{resetAt: now.Add(window)}
+	}
+	limit.count++
+	s.rateLimits[key] = limit
+
+	return limit.count, limit.resetAt, nil
+}
+
+func (s *Storage) DeleteRateLimit(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.rateLimits, key)
+
+	return nil
+}
+
+func (s *Storage) DeleteExpiredRateLimits(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, limit := range s.rateLimits {
+		if !limit.resetAt.After(now) {
+			delete(s.rateLimits, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *Storage) SetUserRoles(ctx context.Context, userID int, roles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndexByID(userID) < 0 {
+		return domain.ErrNotFound
+	}
+	for _, role := range roles {
+		if _, ok := s.rolePermissions[role]; !ok {
+			return domain.ErrNotFound
+		}
+	}
+
+	s.userRoles[userID] = append([]string(nil), roles...)
+
+	return nil
+}
+
+func (s *Storage) GetUserAccess(ctx context.Context, userID int) (domain.Access, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.activeUserIndexByID(userID)
+	if i < 0 {
+		return domain.Access{}, domain.ErrNotFound
+	}
+
+	access := domain.Access{Roles: []string{}, Permissions: []string{}, Disabled: !s.users[i].DisabledAt.IsZero()}
+	roles := make(map[string]bool)
+	permissions := make(map[string]bool)
+	for _, role := range s.userRoles[userID] {
+		if roles[role] {
+			continue
+		}
+		roles[role] = true
+		access.Roles = append(access.Roles, role)
+
+		for _, permission := range s.rolePermissions[role] {
+			if !permissions[permission] {
+				permissions[permission] = true
+				access.Permissions = append(access.Permissions, permission)
+			}
+		}
+	}
+	sort.Strings(access.Roles)
+	sort.Strings(access.Permissions)
+
+	return access, nil
+}
+
+func (s *Storage) UpdateNotificationSent(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+	s.users[i].NotificationSent = true
+
+	return nil
+}
+
+func (s *Storage) GetUsersWithExpiredPassword(ctx context.Context) ([]domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var users []domain.User
+	for _, u := range s.users {
+		if now.After(u.PasswordExpires) && !u.NotificationSent && u.DisabledAt.IsZero() && u.DeletedAt.IsZero() {
+			users = append(users, u)
+		}
+	}
+
+	return users, nil
+}
+
+func (s *Storage) ListUsers(ctx context.Context, params domain.UsersListParams) ([]domain.User, error) {
+	switch params.SortBy {
+	case domain.UsersSortByID, domain.UsersSortByLogin, domain.UsersSortByPasswordExpires:
+	default:
+		return nil, domain.ErrInvalidParams
+	}
+
+	less := func(a, b domain.User) bool {
+		switch params.SortBy {
+		case domain.UsersSortByLogin:
+			if a.Login != b.Login {
+				return a.Login < b.Login
+			}
+		case domain.UsersSortByPasswordExpires:
+			if !a.PasswordExpires.Equal(b.PasswordExpires) {
+				return a.PasswordExpires.Before(b.PasswordExpires)
+			}
+		}
+
+		return a.ID < b.ID
+	}
+	if params.Desc {
+		asc := less
+		less = func(a, b domain.User) bool {
+			return asc(b, a)
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]domain.User, 0, len(s.users))
+	for _, u := range s.users {
+		if !u.DeletedAt.IsZero() || !strings.HasPrefix(u.Login, params.LoginPrefix) {
+			continue
+		}
+		if params.After != nil && !less(*params.After, u) {
+			continue
+		}
+		u.Password = ""
+		u.PasswordAlgo = ""
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return less(users[i], users[j])
+	})
+
+	if len(users) > params.Limit {
+		users = users[:params.Limit]
+	}
+
+	return users, nil
+}
+
+func (s *Storage) InsertSession(ctx context.Context, session domain.Session) (domain.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[session.Key]; ok {
+		return domain.Session{}, domain.ErrConflict
+	}
+	if s.userIndexByID(session.UserID) < 0 {
+		return domain.Session{}, domain.ErrNotFound
+	}
+
+	s.lastSessionID++
+	session.ID = s.lastSessionID
+	s.sessions[session.Key] = session
+
+	return session, nil
+}
+
+func (s *Storage) GetSessionByKey(ctx context.Context, key uuid.UUID) (domain.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[key]
+	if !ok {
+		return domain.Session{}, domain.ErrNotFound
+	}
+
+	return session, nil
+}
+
+func (s *Storage) UpdateSessionLastSeen(ctx context.Context, key uuid.UUID, lastSeenAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	session.LastSeenAt = lastSeenAt
+	s.sessions[key] = session
+
+	return nil
+}
+
+func (s *Storage) RotateRefreshToken(ctx context.Context, key uuid.UUID, oldHash string, newHash string, lastSeenAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok || session.RefreshTokenHash != oldHash {
+		return domain.ErrNotFound
+	}
+	session.RefreshTokenHash = newHash
+	session.LastSeenAt = lastSeenAt
+	s.sessions[key] = session
+
+	return nil
+}
+
+func (s *Storage) GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]domain.Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	return sessions, nil
+}
+
+func (s *Storage) DeleteUserSession(ctx context.Context, userID int, id int) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, session := range s.sessions {
+		if session.ID == id && session.UserID == userID {
+			delete(s.sessions, key)
+			return key, nil
+		}
+	}
+
+	return uuid.UUID{}, domain.ErrNotFound
+}
+
+func (s *Storage) DeleteUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]domain.Session, 0)
+	for key, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, key)
+			sessions = append(sessions, session)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (s *Storage) DeleteSessionByKey(ctx context.Context, key uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, key)
+
+	return nil
+}
+
+func (s *Storage) DeleteExpiredSessions(ctx context.Context, now time.Time, idleTimeout time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idleBefore := now.Add(-idleTimeout)
+	var deleted int64
+	for key, session := range s.sessions {
+		if !session.ExpiresAt.After(now) || !session.LastSeenAt.After(idleBefore) {
+			delete(s.sessions, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *Storage) EnqueueNotification(ctx context.Context, job domain.NotificationJob) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndexByID(job.UserID) < 0 {
+		return false, domain.ErrNotFound
+	}
+	for _, j := range s.jobs {
+		if j.IdempotencyKey == job.IdempotencyKey {
+			return false, nil
+		}
+	}
+
+	job.ID = len(s.jobs) + 1
+	job.Status = domain.NotificationJobPending
+	job.Attempts = 0
+	job.LockedUntil = time.Time{}
+	job.LastError = ""
+	job.CreatedAt = time.Now().UTC()
+	job.User = domain.User{}
+	s.jobs = append(s.jobs, job)
+
+	return true, nil
+}
+
+func (s *Storage) ClaimNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.NotificationJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ready []int
+	for i, j := range s.jobs {
+		pending := j.Status == domain.NotificationJobPending && !j.NextAttemptAt.After(now)
+		leaseExpired := j.Status == domain.NotificationJobProcessing && !j.LockedUntil.After(now)
+		if pending || leaseExpired {
+			ready = append(ready, i)
+		}
+	}
+	sort.SliceStable(ready, func(a, b int) bool {
+		return s.jobs[ready[a]].NextAttemptAt.Before(s.jobs[ready[b]].NextAttemptAt)
+	})
+	if len(ready) > limit {
+		ready = ready[:limit]
+	}
+
+	claimToken := uuid.New()
+	jobs := make([]domain.NotificationJob, 0, len(ready))
+	for _, i := range ready {
+		s.jobs[i].Status = domain.NotificationJobProcessing
+		s.jobs[i].Attempts++
+		s.jobs[i].LockedUntil = now.Add(lease)
+		s.jobs[i].ClaimToken = claimToken
+
+		job := s.jobs[i]
+		if u := s.userIndexByID(job.UserID); u >= 0 {
+			job.User = s.users[u]
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func (s *Storage) CountReadyNotifications(ctx context.Context, now time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, j := range s.jobs {
+		if j.Status == domain.NotificationJobPending && !j.NextAttemptAt.After(now) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *Storage) CompleteNotification(ctx context.Context, id int, claimToken uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.claimedJobIndex(id, claimToken)
+	if i < 0 {
+		return domain.ErrLeaseLost
+	}
+	s.jobs[i].Status = domain.NotificationJobDone
+	s.jobs[i].LockedUntil = time.Time{}
+	s.jobs[i].ClaimToken = uuid.UUID{}
+
+	u := s.userIndexByID(s.jobs[i].UserID)
+	if u < 0 {
+		return domain.ErrNotFound
+	}
+	s.users[u].NotificationSent = true
+
+	return nil
+}
+
+func (s *Storage) RetryNotification(ctx context.Context, id int, claimToken uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.claimedJobIndex(id, claimToken)
+	if i < 0 {
+		return domain.ErrLeaseLost
+	}
+	s.jobs[i].Status = domain.NotificationJobPending
+	s.jobs[i].NextAttemptAt = nextAttemptAt
+	s.jobs[i].LastError = lastError
+	s.jobs[i].LockedUntil = time.Time{}
+	s.jobs[i].ClaimToken = uuid.UUID{}
+
+	return nil
+}
+
+func (s *Storage) DeadLetterNotification(ctx context.Context, id int, claimToken uuid.UUID, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.claimedJobIndex(id, claimToken)
+	if i < 0 {
+		return domain.ErrLeaseLost
+	}
+	s.jobs[i].Status = domain.NotificationJobDead
+	s.jobs[i].LastError = lastError
+	s.jobs[i].LockedUntil = time.Time{}
+	s.jobs[i].ClaimToken = uuid.UUID{}
+
+	return nil
+}
+
+// claimedJobIndex возвращает индекс задания id, если оно все еще захвачено с токеном claimToken.
+func (s *Storage) claimedJobIndex(id int, claimToken uuid.UUID) int {
+	i := s.jobIndexByID(id)
+	if i < 0 || s.jobs[i].Status != domain.NotificationJobProcessing || s.jobs[i].ClaimToken != claimToken {
+		return -1
+	}
+
+	return i
+}
+
+func (s *Storage) jobIndexByID(id int) int {
+	// идентификаторы заданий совпадают с позицией в срезе, задания не удаляются
+	if id < 1 || id > len(s.jobs) {
+		return -1
+	}
+
+	return id - 1
+}
+
+func (s *Storage) userIndexByLogin(login string) int {
 	for i := range s.users {
 		if s.users[i].Login == login {
-			return true, domain.ErrNotFound
+			return i
+		}
+	}
+
+	return -1
+}
+
+// activeUserIndexByID не находит удаленных пользователей.
+func (s *Storage) activeUserIndexByID(id int) int {
+	i := s.userIndexByID(id)
+	if i < 0 || !s.users[i].DeletedAt.IsZero() {
+		return -1
+	}
+
+	return i
+}
+
+func (s *Storage) userIndexByID(id int) int {
+	for i := range s.users {
+		if s.users[i].ID == id {
+			return i
 		}
 	}
 
-	return false, nil
+	return -1
 }
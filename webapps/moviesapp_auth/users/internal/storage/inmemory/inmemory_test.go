@@ -0,0 +1,12 @@
+package inmemory
+
+import (
+	"movies-auth/users/internal/storage/storagetest"
+	"testing"
+)
+
+func TestStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		return NewStorage()
+	})
+}
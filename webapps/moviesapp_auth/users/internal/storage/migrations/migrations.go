@@ -0,0 +1,277 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID - ключ advisory lock, чтобы несколько реплик не применяли миграции одновременно.
+const lockID = 7242001
+
+var ErrInvalidMigration = errors.New("invalid migration")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load возвращает встроенные в бинарник миграции, упорядоченные по версии.
+// Файлы называются <version>_<name>.up.sql и <version>_<name>.down.sql.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionPart, migrationName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, name)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, name)
+		}
+
+		body, err := files.ReadFile(path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: version %d must have both up and down files", ErrInvalidMigration, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up применяет все еще не примененные миграции, каждую в отдельной транзакции.
+func Up(ctx context.Context, db *sql.DB) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	err = ensureVersionTable(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		ok, err := apply(ctx, db, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// Down откатывает steps последних примененных миграций.
+func Down(ctx context.Context, db *sql.DB, steps int) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	err = ensureVersionTable(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+		m := migrations[i]
+		ok, err := revert(ctx, db, m)
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			reverted++
+		}
+	}
+
+	return reverted, nil
+}
+
+// GetStatus возвращает все известные миграции с отметкой о применении.
+func GetStatus(ctx context.Context, db *sql.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ensureVersionTable(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		at, ok := appliedAt[m.Version]
+		statuses = append(statuses, Status{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+
+	return statuses, nil
+}
+
+func ensureVersionTable(ctx context.Context, db *sql.DB) error {
+	query := `CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+func apply(ctx context.Context, db *sql.DB, m Migration) (bool, error) {
+	return inLockedTx(ctx, db, func(tx *sql.Tx) (bool, error) {
+		isApplied, err := isApplied(ctx, tx, m.Version)
+		if err != nil || isApplied {
+			return false, err
+		}
+
+		_, err = tx.ExecContext(ctx, m.Up)
+		if err != nil {
+			return false, err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_version (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+func revert(ctx context.Context, db *sql.DB, m Migration) (bool, error) {
+	return inLockedTx(ctx, db, func(tx *sql.Tx) (bool, error) {
+		isApplied, err := isApplied(ctx, tx, m.Version)
+		if err != nil || !isApplied {
+			return false, err
+		}
+
+		_, err = tx.ExecContext(ctx, m.Down)
+		if err != nil {
+			return false, err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_version WHERE version = $1`, m.Version)
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+func isApplied(ctx context.Context, tx *sql.Tx, version int) (bool, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_version WHERE version = $1)`, version).Scan(&exists)
+	return exists, err
+}
+
+func inLockedTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) (bool, error)) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockID)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := fn(tx)
+	if err != nil {
+		return false, err
+	}
+
+	return ok, tx.Commit()
+}
@@ -0,0 +1,77 @@
+package migrations_test
+
+import (
+	"context"
+	"movies-auth/users/internal/storage/migrations"
+	"movies-auth/users/internal/tests/pgtest"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	ms, err := migrations.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range ms {
+		if i > 0 && ms[i-1].Version >= m.Version {
+			t.Errorf("expected migrations ordered by version, got: %d after %d", m.Version, ms[i-1].Version)
+		}
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	db := pgtest.NewEmptyDB(t)
+	ctx := context.Background()
+
+	ms, err := migrations.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrations.Up(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(ms) {
+		t.Errorf("expected applied migrations: %d, got: %d", len(ms), applied)
+	}
+
+	applied, err = migrations.Up(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 0 {
+		t.Errorf("expected second up to be a no-op, got: %d applied", applied)
+	}
+
+	statuses, err := migrations.GetStatus(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("expected migration %d to be applied", s.Version)
+		}
+	}
+
+	reverted, err := migrations.Down(ctx, db, len(ms))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted != len(ms) {
+		t.Errorf("expected reverted migrations: %d, got: %d", len(ms), reverted)
+	}
+
+	var tables int
+	err = db.QueryRow(`SELECT count(*) FROM information_schema.tables WHERE table_schema = 'public' AND table_name <> 'schema_version'`).Scan(&tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("expected no tables after down, got: %d", tables)
+	}
+}
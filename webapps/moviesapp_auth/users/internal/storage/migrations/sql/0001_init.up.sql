@@ -0,0 +1,23 @@
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    login TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    password_algo TEXT NOT NULL,
+    password_expires TIMESTAMPTZ NOT NULL DEFAULT now() + INTERVAL '90 days',
+    notification_sent BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX users_password_expires_idx ON users (password_expires) WHERE notification_sent = false;
+
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,
+    key UUID NOT NULL UNIQUE,
+    userid INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    startedat TIMESTAMPTZ NOT NULL,
+    expiresat TIMESTAMPTZ NOT NULL,
+    lastseenat TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_userid_idx ON sessions (userid);
+CREATE INDEX sessions_expiresat_idx ON sessions (expiresat);
+CREATE INDEX sessions_lastseenat_idx ON sessions (lastseenat);
@@ -0,0 +1 @@
+ALTER TABLE users ALTER COLUMN login TYPE TEXT COLLATE "default";
@@ -0,0 +1,2 @@
+-- сортировка и сравнение логинов по байтам, независимо от локали базы
+ALTER TABLE users ALTER COLUMN login TYPE TEXT COLLATE "C";
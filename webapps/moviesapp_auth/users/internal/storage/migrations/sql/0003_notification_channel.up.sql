@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN email TEXT NOT NULL DEFAULT '',
+    ADD COLUMN notification_channel TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+CREATE TABLE password_history (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password TEXT NOT NULL,
+    password_algo TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX password_history_user_id_idx ON password_history (user_id, id);
+
+-- текущие пароли становятся первой записью истории
+INSERT INTO password_history (user_id, password, password_algo)
+SELECT id, password, password_algo FROM users;
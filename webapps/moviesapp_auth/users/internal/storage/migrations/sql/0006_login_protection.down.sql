@@ -0,0 +1,5 @@
+DROP TABLE rate_limits;
+DROP TABLE lockout_events;
+ALTER TABLE users
+    DROP COLUMN locked_until,
+    DROP COLUMN failed_logins;
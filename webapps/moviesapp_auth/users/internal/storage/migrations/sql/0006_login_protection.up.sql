@@ -0,0 +1,22 @@
+ALTER TABLE users
+    ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN locked_until TIMESTAMPTZ;
+
+CREATE TABLE lockout_events (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX lockout_events_user_id_idx ON lockout_events (user_id, id);
+
+-- счетчики попыток быстро устаревают, их потеря при сбое не критична
+CREATE UNLOGGED TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    count INTEGER NOT NULL,
+    reset_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limits_reset_at_idx ON rate_limits (reset_at);
@@ -0,0 +1 @@
+ALTER TABLE sessions ADD COLUMN refresh_token_hash TEXT NOT NULL DEFAULT '';
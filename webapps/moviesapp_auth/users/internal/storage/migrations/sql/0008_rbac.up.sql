@@ -0,0 +1,30 @@
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role)
+);
+
+-- должно совпадать с domain.DefaultRolePermissions
+INSERT INTO roles (name) VALUES ('admin'), ('editor'), ('viewer');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'movies:read'),
+    ('admin', 'movies:write'),
+    ('admin', 'users:read'),
+    ('admin', 'users:write'),
+    ('editor', 'movies:read'),
+    ('editor', 'movies:write'),
+    ('viewer', 'movies:read');
+
+-- существующие пользователи получают роль по умолчанию
+INSERT INTO user_roles (user_id, role) SELECT id, 'viewer' FROM users;
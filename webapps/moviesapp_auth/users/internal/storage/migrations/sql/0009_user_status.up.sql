@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN disabled_at TIMESTAMPTZ,
+    ADD COLUMN deleted_at TIMESTAMPTZ;
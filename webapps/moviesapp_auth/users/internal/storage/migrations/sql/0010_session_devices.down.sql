@@ -0,0 +1,3 @@
+ALTER TABLE sessions
+    DROP COLUMN ip,
+    DROP COLUMN user_agent;
@@ -0,0 +1,5 @@
+DELETE FROM role_permissions WHERE permission = 'audit:read';
+
+DROP TABLE audit_events;
+
+DROP FUNCTION audit_events_append_only();
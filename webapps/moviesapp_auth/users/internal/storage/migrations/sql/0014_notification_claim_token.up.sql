@@ -0,0 +1,2 @@
+-- захват задания выдает новый токен, завершить задание может только его владелец
+ALTER TABLE notification_jobs ADD COLUMN claim_token UUID;
@@ -0,0 +1 @@
+ALTER TABLE users ALTER COLUMN password_expires SET DEFAULT now() + INTERVAL '90 days';
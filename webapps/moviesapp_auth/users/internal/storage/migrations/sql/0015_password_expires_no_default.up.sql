@@ -0,0 +1,2 @@
+-- срок действия пароля задает политика паролей сервиса
+ALTER TABLE users ALTER COLUMN password_expires DROP DEFAULT;
@@ -0,0 +1,1409 @@
+// Package storagetest содержит общий набор тестов контракта хранилища.
+// Каждая реализация хранилища запускает его в своих тестах, чтобы все они вели себя одинаково.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"movies-auth/users/internal/audit"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/passwords"
+	"movies-auth/users/internal/ratelimit"
+	"movies-auth/users/internal/services"
+	"movies-auth/users/internal/workers"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Storage interface {
+	services.UsersStorage
+	services.SessionsStorage
+	workers.PassCheckStore
+	workers.SessionsStore
+	workers.RateLimitsStore
+	ratelimit.Store
+	audit.Store
+	services.AuditStorage
+	UpdateNotificationSent(ctx context.Context, id int) error
+	GetLockoutEvents(ctx context.Context, userID int) ([]domain.LockoutEvent, error)
+}
+
+// Run запускает набор тестов контракта. newStorage должна возвращать новое пустое хранилище на каждый вызов.
+func Run(t *testing.T, newStorage func(t *testing.T) Storage) {
+	t.Run("users", func(t *testing.T) {
+		testUsers(t, newStorage)
+	})
+	t.Run("list_users", func(t *testing.T) {
+		testListUsers(t, newStorage)
+	})
+	t.Run("expired_passwords", func(t *testing.T) {
+		testExpiredPasswords(t, newStorage)
+	})
+	t.Run("notification_queue", func(t *testing.T) {
+		testNotificationQueue(t, newStorage)
+	})
+	t.Run("login_failures", func(t *testing.T) {
+		testLoginFailures(t, newStorage)
+	})
+	t.Run("rate_limits", func(t *testing.T) {
+		testRateLimits(t, newStorage)
+	})
+	t.Run("roles", func(t *testing.T) {
+		testRoles(t, newStorage)
+	})
+	t.Run("user_status", func(t *testing.T) {
+		testUserStatus(t, newStorage)
+	})
+	t.Run("account_tokens", func(t *testing.T) {
+		testAccountTokens(t, newStorage)
+	})
+	t.Run("two_factor", func(t *testing.T) {
+		testTwoFactor(t, newStorage)
+	})
+	t.Run("audit_events", func(t *testing.T) {
+		testAuditEvents(t, newStorage)
+	})
+	t.Run("sessions", func(t *testing.T) {
+		testSessions(t, newStorage)
+	})
+	t.Run("expired_sessions", func(t *testing.T) {
+		testExpiredSessions(t, newStorage)
+	})
+}
+
+func testUsers(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("insert", func(t *testing.T) {
+		s := newStorage(t)
+
+		first := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash1", PasswordAlgo: "bcrypt"})
+		second := mustInsertUser(t, s, domain.User{Login: "user2", Password: "hash2", PasswordAlgo: "argon2id"})
+
+		if first.ID == 0 || first.ID == second.ID {
+			t.Errorf("expected distinct non-zero ids, got: %d and %d", first.ID, second.ID)
+		}
+		if first.Login != "user1" || first.Password != "hash1" || first.PasswordAlgo != "bcrypt" {
+			t.Errorf("unexpected inserted user: %+v", first)
+		}
+		if first.NotificationSent {
+			t.Errorf("expected notification not sent for new user")
+		}
+	})
+
+	t.Run("insert_password_expires", func(t *testing.T) {
+		s := newStorage(t)
+		expires := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt", PasswordExpires: expires})
+		if !user.PasswordExpires.Equal(expires) {
+			t.Errorf("expected password expires: %v, got: %v", expires, user.PasswordExpires)
+		}
+	})
+
+	t.Run("insert_notification_preference", func(t *testing.T) {
+		s := newStorage(t)
+		inserted := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt",
+			Email: "user1@example.com", NotificationChannel: domain.NotificationChannelEmail})
+		if inserted.Email != "user1@example.com" || inserted.NotificationChannel != domain.NotificationChannelEmail {
+			t.Errorf("unexpected notification preference: %+v", inserted)
+		}
+
+		user, err := s.GetUserByID(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectSameUser(t, inserted, user)
+	})
+
+	t.Run("insert_conflict", func(t *testing.T) {
+		s := newStorage(t)
+		mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		_, err := s.Insert(ctx, domain.User{Login: "user1", Password: "other", PasswordAlgo: "bcrypt"})
+		expectError(t, err, domain.ErrConflict)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		s := newStorage(t)
+		inserted := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		user, err := s.GetUserByID(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectSameUser(t, inserted, user)
+
+		_, err = s.GetUserByID(ctx, "missing")
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("exist", func(t *testing.T) {
+		s := newStorage(t)
+		mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		exist, err := s.IsUserExist(ctx, "user1")
+		if err != nil || !exist {
+			t.Errorf("expected existing user, got: %t, %v", exist, err)
+		}
+
+		exist, err = s.IsUserExist(ctx, "missing")
+		expectError(t, err, domain.ErrNotFound)
+		if exist {
+			t.Errorf("expected missing user not to exist")
+		}
+	})
+
+	t.Run("change_password", func(t *testing.T) {
+		s := newStorage(t)
+		inserted := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash1", PasswordAlgo: "bcrypt", PasswordExpires: time.Now().Add(-time.Hour)})
+		err := s.UpdateNotificationSent(ctx, inserted.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expires := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+		err = s.ChangePassword(ctx, inserted.ID, "hash2", "argon2id", expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// бессрочный пароль хранится с датой в далеком будущем
+		err = s.ChangePassword(ctx, inserted.ID, "hash3", "argon2id", passwords.NeverExpires)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := s.GetUserByID(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Password != "hash3" || user.NotificationSent || !user.PasswordExpires.Equal(passwords.NeverExpires) {
+			t.Errorf("unexpected user after password change: %+v", user)
+		}
+
+		history, err := s.GetPasswordHistory(ctx, inserted.ID, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 2 || history[0].Hash != "hash3" || history[1].Hash != "hash2" || history[1].Algorithm != "argon2id" {
+			t.Errorf("expected latest passwords first, got: %+v", history)
+		}
+		history, err = s.GetPasswordHistory(ctx, inserted.ID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 3 || history[2].Hash != "hash1" {
+			t.Errorf("expected inserted password in history, got: %+v", history)
+		}
+
+		err = s.ChangePassword(ctx, inserted.ID+100, "hash", "bcrypt", expires)
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("update_password", func(t *testing.T) {
+		s := newStorage(t)
+		inserted := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		err := s.UpdatePassword(ctx, inserted.ID, "new_hash", "argon2id")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := s.GetUserByID(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Password != "new_hash" || user.PasswordAlgo != "argon2id" {
+			t.Errorf("expected updated password, got: %s %s", user.PasswordAlgo, user.Password)
+		}
+
+		err = s.UpdatePassword(ctx, inserted.ID+100, "new_hash", "argon2id")
+		expectError(t, err, domain.ErrNotFound)
+	})
+}
+
+func testListUsers(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	base := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	users := []domain.User{
+		{Login: "carol", PasswordExpires: base.Add(2 * time.Hour)},
+		{Login: "alice", PasswordExpires: base.Add(3 * time.Hour)},
+		{Login: "bob", PasswordExpires: base.Add(time.Hour)},
+		{Login: "al_x", PasswordExpires: base.Add(time.Hour)},
+		{Login: "alfred", PasswordExpires: base},
+	}
+	for i := range users {
+		users[i].Password = "hash"
+		users[i].PasswordAlgo = "bcrypt"
+		users[i] = mustInsertUser(t, s, users[i])
+	}
+
+	testCases := []struct {
+		name       string
+		params     domain.UsersListParams
+		wantLogins []string
+	}{
+		{
+			name:       "by_id",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByID, Limit: 10},
+			wantLogins: []string{"carol", "alice", "bob", "al_x", "alfred"},
+		},
+		{
+			name:       "by_login_desc",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByLogin, Desc: true, Limit: 10},
+			wantLogins: []string{"carol", "bob", "alice", "alfred", "al_x"},
+		},
+		{
+			name:       "by_password_expires_ties_by_id",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByPasswordExpires, Limit: 10},
+			wantLogins: []string{"alfred", "bob", "al_x", "carol", "alice"},
+		},
+		{
+			name:       "prefix",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByLogin, LoginPrefix: "al", Limit: 10},
+			wantLogins: []string{"al_x", "alfred", "alice"},
+		},
+		{
+			name:       "prefix_wildcards_literal",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByLogin, LoginPrefix: "al_", Limit: 10},
+			wantLogins: []string{"al_x"},
+		},
+		{
+			name:       "limit",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByLogin, Limit: 2},
+			wantLogins: []string{"al_x", "alfred"},
+		},
+		{
+			name:       "after_login",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByLogin, Limit: 2, After: &users[1]},
+			wantLogins: []string{"bob", "carol"},
+		},
+		{
+			name:       "after_password_expires_desc",
+			params:     domain.UsersListParams{SortBy: domain.UsersSortByPasswordExpires, Desc: true, Limit: 10, After: &users[3]},
+			wantLogins: []string{"bob", "alfred"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.ListUsers(ctx, tc.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logins := make([]string, 0, len(got))
+			for _, u := range got {
+				logins = append(logins, u.Login)
+				if u.Password != "" {
+					t.Errorf("expected no password in list, got: %s", u.Password)
+				}
+			}
+
+			if fmt.Sprint(logins) != fmt.Sprint(tc.wantLogins) {
+				t.Errorf("expected users: %v, got: %v", tc.wantLogins, logins)
+			}
+		})
+	}
+
+	t.Run("unknown_sort", func(t *testing.T) {
+		_, err := s.ListUsers(ctx, domain.UsersListParams{SortBy: "password", Limit: 10})
+		expectError(t, err, domain.ErrInvalidParams)
+	})
+}
+
+func testExpiredPasswords(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	expired := mustInsertUser(t, s, domain.User{Login: "expired", Password: "hash", PasswordAlgo: "bcrypt", PasswordExpires: time.Now().Add(-time.Hour),
+		Email: "expired@example.com", NotificationChannel: domain.NotificationChannelWebhook})
+	mustInsertUser(t, s, domain.User{Login: "active", Password: "hash", PasswordAlgo: "bcrypt", PasswordExpires: time.Now().Add(time.Hour)})
+
+	users, err := s.GetUsersWithExpiredPassword(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].ID != expired.ID {
+		t.Fatalf("expected only user %d, got: %+v", expired.ID, users)
+	}
+	expectSameUser(t, expired, users[0])
+
+	err = s.UpdateNotificationSent(ctx, expired.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err = s.GetUsersWithExpiredPassword(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 0 {
+		t.Errorf("expected no users after notification, got: %+v", users)
+	}
+
+	err = s.UpdateNotificationSent(ctx, expired.ID+100)
+	expectError(t, err, domain.ErrNotFound)
+}
+
+func testNotificationQueue(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	lease := time.Minute
+
+	newJob := func(userID int, key string, nextAttemptAt time.Time) domain.NotificationJob {
+		return domain.NotificationJob{UserID: userID, Kind: "password_expired", IdempotencyKey: key, NextAttemptAt: nextAttemptAt}
+	}
+
+	t.Run("enqueue_idempotent", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		created, err := s.EnqueueNotification(ctx, newJob(user.ID, "key1", now))
+		if err != nil || !created {
+			t.Fatalf("expected job created, got: %t, %v", created, err)
+		}
+		created, err = s.EnqueueNotification(ctx, newJob(user.ID, "key1", now))
+		if err != nil || created {
+			t.Fatalf("expected duplicate job ignored, got: %t, %v", created, err)
+		}
+
+		_, err = s.EnqueueNotification(ctx, newJob(user.ID+100, "key2", now))
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt", Email: "user1@example.com"})
+
+		for _, job := range []domain.NotificationJob{
+			newJob(user.ID, "ready2", now.Add(-time.Minute)),
+			newJob(user.ID, "ready1", now.Add(-time.Hour)),
+			newJob(user.ID, "later", now.Add(time.Hour)),
+		} {
+			if _, err := s.EnqueueNotification(ctx, job); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ready, err := s.CountReadyNotifications(ctx, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ready != 2 {
+			t.Errorf("expected ready jobs: 2, got: %d", ready)
+		}
+
+		jobs, err := s.ClaimNotifications(ctx, now, lease, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 2 || jobs[0].IdempotencyKey != "ready1" || jobs[1].IdempotencyKey != "ready2" {
+			t.Fatalf("expected ready jobs in order of next attempt, got: %+v", jobs)
+		}
+		for _, job := range jobs {
+			if job.Status != domain.NotificationJobProcessing || job.Attempts != 1 || !job.LockedUntil.Equal(now.Add(lease)) {
+				t.Errorf("unexpected claimed job: %+v", job)
+			}
+			if job.User.ID != user.ID || job.User.Email != "user1@example.com" {
+				t.Errorf("expected claimed job with user, got: %+v", job.User)
+			}
+		}
+
+		jobs, err = s.ClaimNotifications(ctx, now, lease, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 0 {
+			t.Errorf("expected claimed jobs to be locked, got: %+v", jobs)
+		}
+		ready, err = s.CountReadyNotifications(ctx, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ready != 0 {
+			t.Errorf("expected ready jobs: 0, got: %d", ready)
+		}
+
+		jobs, err = s.ClaimNotifications(ctx, now.Add(lease), lease, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 1 || jobs[0].IdempotencyKey != "ready1" || jobs[0].Attempts != 2 {
+			t.Errorf("expected job reclaimed after lease, got: %+v", jobs)
+		}
+	})
+
+	t.Run("retry_and_dead_letter", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		if _, err := s.EnqueueNotification(ctx, newJob(user.ID, "key1", now)); err != nil {
+			t.Fatal(err)
+		}
+
+		jobs, err := s.ClaimNotifications(ctx, now, lease, 10)
+		if err != nil || len(jobs) != 1 {
+			t.Fatalf("expected one claimed job, got: %+v, %v", jobs, err)
+		}
+		id := jobs[0].ID
+
+		err = s.RetryNotification(ctx, id, jobs[0].ClaimToken, now.Add(time.Hour), "smtp down")
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs, err = s.ClaimNotifications(ctx, now.Add(time.Minute), lease, 10)
+		if err != nil || len(jobs) != 0 {
+			t.Fatalf("expected retried job to wait, got: %+v, %v", jobs, err)
+		}
+		jobs, err = s.ClaimNotifications(ctx, now.Add(time.Hour), lease, 10)
+		if err != nil || len(jobs) != 1 {
+			t.Fatalf("expected retried job claimed, got: %+v, %v", jobs, err)
+		}
+		if jobs[0].Attempts != 2 || jobs[0].LastError != "smtp down" {
+			t.Errorf("unexpected retried job: %+v", jobs[0])
+		}
+
+		claimToken := jobs[0].ClaimToken
+		err = s.DeadLetterNotification(ctx, id, claimToken, "still down")
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs, err = s.ClaimNotifications(ctx, now.Add(24*time.Hour), lease, 10)
+		if err != nil || len(jobs) != 0 {
+			t.Errorf("expected dead job not to be claimed, got: %+v, %v", jobs, err)
+		}
+
+		expectError(t, s.RetryNotification(ctx, id+100, claimToken, now, ""), domain.ErrLeaseLost)
+		expectError(t, s.DeadLetterNotification(ctx, id+100, claimToken, ""), domain.ErrLeaseLost)
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt", PasswordExpires: now.Add(-time.Hour)})
+		if _, err := s.EnqueueNotification(ctx, newJob(user.ID, "key1", now)); err != nil {
+			t.Fatal(err)
+		}
+
+		jobs, err := s.ClaimNotifications(ctx, now, lease, 10)
+		if err != nil || len(jobs) != 1 {
+			t.Fatalf("expected one claimed job, got: %+v, %v", jobs, err)
+		}
+
+		claimToken := jobs[0].ClaimToken
+		err = s.CompleteNotification(ctx, jobs[0].ID, claimToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users, err := s.GetUsersWithExpiredPassword(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 0 {
+			t.Errorf("expected user notified after completion, got: %+v", users)
+		}
+
+		jobs, err = s.ClaimNotifications(ctx, now.Add(24*time.Hour), lease, 10)
+		if err != nil || len(jobs) != 0 {
+			t.Errorf("expected completed job not to be claimed, got: %+v, %v", jobs, err)
+		}
+
+		expectError(t, s.CompleteNotification(ctx, 100, claimToken), domain.ErrLeaseLost)
+	})
+
+	t.Run("lease_lost", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt", PasswordExpires: now.Add(-time.Hour)})
+		if _, err := s.EnqueueNotification(ctx, newJob(user.ID, "key1", now)); err != nil {
+			t.Fatal(err)
+		}
+
+		stale, err := s.ClaimNotifications(ctx, now, lease, 10)
+		if err != nil || len(stale) != 1 {
+			t.Fatalf("expected one claimed job, got: %+v, %v", stale, err)
+		}
+		// lease истек, задание захватил другой обработчик
+		current, err := s.ClaimNotifications(ctx, now.Add(lease), lease, 10)
+		if err != nil || len(current) != 1 {
+			t.Fatalf("expected job reclaimed after lease, got: %+v, %v", current, err)
+		}
+		if current[0].ClaimToken == stale[0].ClaimToken {
+			t.Fatalf("expected new claim token, got: %s", current[0].ClaimToken)
+		}
+
+		id := stale[0].ID
+		expectError(t, s.CompleteNotification(ctx, id, stale[0].ClaimToken), domain.ErrLeaseLost)
+		expectError(t, s.RetryNotification(ctx, id, stale[0].ClaimToken, now, "timeout"), domain.ErrLeaseLost)
+		expectError(t, s.DeadLetterNotification(ctx, id, stale[0].ClaimToken, "timeout"), domain.ErrLeaseLost)
+
+		err = s.CompleteNotification(ctx, id, current[0].ClaimToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// завершенное задание нельзя вернуть в очередь даже с токеном последнего захвата
+		expectError(t, s.RetryNotification(ctx, id, current[0].ClaimToken, now, ""), domain.ErrLeaseLost)
+	})
+}
+
+func testSessions(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	newSession := func(userID int) domain.Session {
+		return domain.Session{
+			Key:        uuid.New(),
+			UserID:     userID,
+			StartedAt:  now,
+			ExpiresAt:  now.Add(time.Hour),
+			LastSeenAt: now,
+			UserAgent:  "Mozilla/5.0",
+			IP:         "10.0.0.1",
+		}
+	}
+
+	t.Run("insert_get", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		session := newSession(user.ID)
+		inserted, err := s.InsertSession(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted.ID == 0 {
+			t.Errorf("expected non-zero session id")
+		}
+		session.ID = inserted.ID
+		expectSameSession(t, session, inserted)
+
+		got, err := s.GetSessionByKey(ctx, session.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectSameSession(t, session, got)
+
+		_, err = s.GetSessionByKey(ctx, uuid.New())
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("user_sessions", func(t *testing.T) {
+		s := newStorage(t)
+		user1 := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		user2 := mustInsertUser(t, s, domain.User{Login: "user2", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		var inserted []domain.Session
+		for _, userID := range []int{user1.ID, user1.ID, user2.ID} {
+			session, err := s.InsertSession(ctx, newSession(userID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			inserted = append(inserted, session)
+		}
+
+		sessions, err := s.GetUserSessions(ctx, user1.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 2 || sessions[0].ID != inserted[0].ID || sessions[1].ID != inserted[1].ID {
+			t.Fatalf("expected two sessions of user1 by id, got: %+v", sessions)
+		}
+
+		// чужую сессию удалить нельзя
+		_, err = s.DeleteUserSession(ctx, user1.ID, inserted[2].ID)
+		expectError(t, err, domain.ErrNotFound)
+
+		key, err := s.DeleteUserSession(ctx, user1.ID, inserted[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != inserted[0].Key {
+			t.Errorf("expected deleted key: %s, got: %s", inserted[0].Key, key)
+		}
+
+		deleted, err := s.DeleteUserSessions(ctx, user1.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deleted) != 1 || deleted[0].ID != inserted[1].ID || deleted[0].Key != inserted[1].Key {
+			t.Errorf("expected remaining user1 session deleted, got: %+v", deleted)
+		}
+
+		_, err = s.GetSessionByKey(ctx, inserted[2].Key)
+		if err != nil {
+			t.Errorf("expected user2 session kept, got: %v", err)
+		}
+	})
+
+	t.Run("rotate_refresh_token", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		session := newSession(user.ID)
+		session.RefreshTokenHash = "hash1"
+		_, err := s.InsertSession(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lastSeen := now.Add(time.Minute)
+		err = s.RotateRefreshToken(ctx, session.Key, "hash1", "hash2", lastSeen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetSessionByKey(ctx, session.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.RefreshTokenHash != "hash2" || !got.LastSeenAt.Equal(lastSeen) {
+			t.Errorf("expected rotated token, got: %+v", got)
+		}
+
+		// замененный хэш больше не подходит
+		err = s.RotateRefreshToken(ctx, session.Key, "hash1", "hash3", lastSeen)
+		expectError(t, err, domain.ErrNotFound)
+		err = s.RotateRefreshToken(ctx, uuid.New(), "hash2", "hash3", lastSeen)
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("insert_notification_preference", func(t *testing.T) {
+		s := newStorage(t)
+		inserted := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt",
+			Email: "user1@example.com", NotificationChannel: domain.NotificationChannelEmail})
+		if inserted.Email != "user1@example.com" || inserted.NotificationChannel != domain.NotificationChannelEmail {
+			t.Errorf("unexpected notification preference: %+v", inserted)
+		}
+
+		user, err := s.GetUserByID(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectSameUser(t, inserted, user)
+	})
+
+	t.Run("insert_conflict", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		session := newSession(user.ID)
+		_, err := s.InsertSession(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.InsertSession(ctx, session)
+		expectError(t, err, domain.ErrConflict)
+	})
+
+	t.Run("insert_unknown_user", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.InsertSession(ctx, newSession(100))
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("update_last_seen", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		session := newSession(user.ID)
+		_, err := s.InsertSession(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lastSeen := now.Add(time.Minute)
+		err = s.UpdateSessionLastSeen(ctx, session.Key, lastSeen)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetSessionByKey(ctx, session.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.LastSeenAt.Equal(lastSeen) {
+			t.Errorf("expected last seen: %v, got: %v", lastSeen, got.LastSeenAt)
+		}
+
+		err = s.UpdateSessionLastSeen(ctx, uuid.New(), lastSeen)
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		session := newSession(user.ID)
+		_, err := s.InsertSession(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.DeleteSessionByKey(ctx, session.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.GetSessionByKey(ctx, session.Key)
+		expectError(t, err, domain.ErrNotFound)
+
+		err = s.DeleteSessionByKey(ctx, session.Key)
+		if err != nil {
+			t.Errorf("expected deleting missing session to succeed, got: %v", err)
+		}
+	})
+}
+
+func testExpiredSessions(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	idleTimeout := 10 * time.Minute
+	sessions := map[string]domain.Session{
+		"active":  {Key: uuid.New(), UserID: user.ID, StartedAt: now, ExpiresAt: now.Add(time.Hour), LastSeenAt: now},
+		"expired": {Key: uuid.New(), UserID: user.ID, StartedAt: now, ExpiresAt: now.Add(-time.Second), LastSeenAt: now},
+		"idle":    {Key: uuid.New(), UserID: user.ID, StartedAt: now, ExpiresAt: now.Add(time.Hour), LastSeenAt: now.Add(-idleTimeout - time.Second)},
+	}
+	for _, session := range sessions {
+		_, err := s.InsertSession(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := s.DeleteExpiredSessions(ctx, now, idleTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected deleted sessions: 2, got: %d", deleted)
+	}
+
+	_, err = s.GetSessionByKey(ctx, sessions["active"].Key)
+	if err != nil {
+		t.Errorf("expected active session to be kept, got: %v", err)
+	}
+	for _, name := range []string{"expired", "idle"} {
+		_, err = s.GetSessionByKey(ctx, sessions[name].Key)
+		expectError(t, err, domain.ErrNotFound)
+	}
+}
+
+func mustInsertUser(t *testing.T, s Storage, user domain.User) domain.User {
+	t.Helper()
+
+	inserted, err := s.Insert(context.Background(), user)
+	if err != nil {
+		t.Fatalf("insert user %s: %s", user.Login, err)
+	}
+
+	return inserted
+}
+
+func expectError(t *testing.T, err error, want error) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Errorf("expected error: %v, got: %v", want, err)
+	}
+}
+
+func expectSameUser(t *testing.T, want, got domain.User) {
+	t.Helper()
+
+	if got.ID != want.ID || got.Login != want.Login || got.Password != want.Password || got.PasswordAlgo != want.PasswordAlgo ||
+		!got.PasswordExpires.Equal(want.PasswordExpires) || got.NotificationSent != want.NotificationSent ||
+		got.Email != want.Email || got.NotificationChannel != want.NotificationChannel {
+		t.Errorf("expected user: %+v, got: %+v", want, got)
+	}
+}
+
+func expectSameSession(t *testing.T, want, got domain.Session) {
+	t.Helper()
+
+	if got.ID != want.ID || got.Key != want.Key || got.UserID != want.UserID || !got.StartedAt.Equal(want.StartedAt) ||
+		!got.ExpiresAt.Equal(want.ExpiresAt) || !got.LastSeenAt.Equal(want.LastSeenAt) || got.UserAgent != want.UserAgent || got.IP != want.IP {
+		t.Errorf("expected session: %+v, got: %+v", want, got)
+	}
+}
+
+func testLoginFailures(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("lock_and_reset", func(t *testing.T) {
+		s := newStorage(t)
+		inserted := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		lockedUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+
+		user, err := s.RegisterLoginFailure(ctx, inserted.ID, 2, lockedUntil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.FailedLogins != 1 || !user.LockedUntil.IsZero() {
+			t.Errorf("expected one failure without lock, got: %+v", user)
+		}
+
+		user, err = s.RegisterLoginFailure(ctx, inserted.ID, 2, lockedUntil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.FailedLogins != 0 || !user.LockedUntil.Equal(lockedUntil) {
+			t.Errorf("expected user locked until %v with failures reset, got: %+v", lockedUntil, user)
+		}
+
+		stored, err := s.GetUserByID(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stored.LockedUntil.Equal(lockedUntil) {
+			t.Errorf("expected stored lock until %v, got: %v", lockedUntil, stored.LockedUntil)
+		}
+
+		err = s.ResetLoginFailures(ctx, inserted.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err = s.GetUserByID(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.FailedLogins != 0 || !stored.LockedUntil.IsZero() {
+			t.Errorf("expected lock removed, got: %+v", stored)
+		}
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.RegisterLoginFailure(ctx, 100, 2, time.Now())
+		expectError(t, err, domain.ErrNotFound)
+		err = s.ResetLoginFailures(ctx, 100)
+		expectError(t, err, domain.ErrNotFound)
+		err = s.InsertLockoutEvent(ctx, domain.LockoutEvent{UserID: 100, Event: domain.LockoutEventLocked, Reason: "test"})
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("lockout_events", func(t *testing.T) {
+		s := newStorage(t)
+		first := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		second := mustInsertUser(t, s, domain.User{Login: "user2", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		for _, event := range []domain.LockoutEvent{
+			{UserID: first.ID, Event: domain.LockoutEventLocked, Reason: "failed logins"},
+			{UserID: second.ID, Event: domain.LockoutEventLocked, Reason: "failed logins"},
+			{UserID: first.ID, Event: domain.LockoutEventUnlocked, Reason: "lockout expired"},
+		} {
+			err := s.InsertLockoutEvent(ctx, event)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		events, err := s.GetLockoutEvents(ctx, first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].Event != domain.LockoutEventLocked || events[1].Event != domain.LockoutEventUnlocked {
+			t.Fatalf("expected user events in order, got: %+v", events)
+		}
+		if events[1].Reason != "lockout expired" || events[1].CreatedAt.IsZero() {
+			t.Errorf("unexpected event: %+v", events[1])
+		}
+	})
+}
+
+func testRateLimits(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	for i := 1; i <= 3; i++ {
+		count, resetAt, err := s.IncrementRateLimit(ctx, "key1", time.Minute, now.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != i || !resetAt.Equal(now.Add(time.Minute+time.Second)) {
+			t.Errorf("attempt %d: got count %d reset at %v", i, count, resetAt)
+		}
+	}
+
+	count, _, err := s.IncrementRateLimit(ctx, "key2", time.Minute, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected keys counted separately, got: %d", count)
+	}
+
+	// окно истекло, счет начинается заново
+	later := now.Add(2 * time.Minute)
+	count, resetAt, err := s.IncrementRateLimit(ctx, "key1", time.Minute, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || !resetAt.Equal(later.Add(time.Minute)) {
+		t.Errorf("expected new window, got count %d reset at %v", count, resetAt)
+	}
+
+	deleted, err := s.DeleteExpiredRateLimits(ctx, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected only expired key2 deleted, got: %d", deleted)
+	}
+
+	err = s.DeleteRateLimit(ctx, "key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, _, err = s.IncrementRateLimit(ctx, "key1", time.Minute, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected reset counter, got: %d", count)
+	}
+	err = s.DeleteRateLimit(ctx, "unknown")
+	if err != nil {
+		t.Errorf("expected deleting unknown key to succeed, got: %v", err)
+	}
+}
+
+func testRoles(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("default_role", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		access, err := s.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := domain.Access{Roles: []string{domain.RoleViewer}, Permissions: []string{domain.PermissionMoviesRead}}
+		if !reflect.DeepEqual(access, want) {
+			t.Errorf("expected access: %+v, got: %+v", want, access)
+		}
+	})
+
+	t.Run("set_roles", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		err := s.SetUserRoles(ctx, user.ID, []string{domain.RoleEditor, domain.RoleAdmin})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		access, err := s.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := domain.Access{
+			Roles: []string{domain.RoleAdmin, domain.RoleEditor},
+			Permissions: []string{domain.PermissionAuditRead, domain.PermissionMoviesRead, domain.PermissionMoviesWrite,
+				domain.PermissionUsersRead, domain.PermissionUsersWrite},
+		}
+		if !reflect.DeepEqual(access, want) {
+			t.Errorf("expected access: %+v, got: %+v", want, access)
+		}
+
+		// роли заменяются целиком
+		err = s.SetUserRoles(ctx, user.ID, []string{domain.RoleViewer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		access, err = s.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(access.Roles, []string{domain.RoleViewer}) {
+			t.Errorf("expected roles replaced, got: %v", access.Roles)
+		}
+	})
+
+	t.Run("unknown_role", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		err := s.SetUserRoles(ctx, user.ID, []string{"owner"})
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("unknown_user", func(t *testing.T) {
+		s := newStorage(t)
+
+		err := s.SetUserRoles(ctx, 1, []string{domain.RoleViewer})
+		expectError(t, err, domain.ErrNotFound)
+	})
+}
+
+func testUserStatus(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("update", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		user.Email = "user1@example.com"
+		user.NotificationChannel = domain.NotificationChannelEmail
+		updated, err := s.UpdateUser(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectSameUser(t, user, updated)
+
+		got, err := s.GetUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectSameUser(t, user, got)
+
+		_, err = s.UpdateUser(ctx, domain.User{ID: user.ID + 1})
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("disable_enable", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		disabledAt := time.Now().UTC().Truncate(time.Microsecond)
+
+		disabled, err := s.SetUserDisabled(ctx, user.ID, disabledAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !disabled.DisabledAt.Equal(disabledAt) {
+			t.Errorf("expected disabled at %v, got: %v", disabledAt, disabled.DisabledAt)
+		}
+
+		access, err := s.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !access.Disabled {
+			t.Errorf("expected access of disabled user to be disabled")
+		}
+
+		enabled, err := s.SetUserDisabled(ctx, user.ID, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !enabled.DisabledAt.IsZero() {
+			t.Errorf("expected user enabled, got disabled at: %v", enabled.DisabledAt)
+		}
+	})
+
+	t.Run("soft_delete", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		mustInsertUser(t, s, domain.User{Login: "user2", Password: "hash", PasswordAlgo: "bcrypt"})
+
+		err := s.DeleteUser(ctx, user.ID, time.Now().UTC())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.DeleteUser(ctx, user.ID, time.Now().UTC())
+		expectError(t, err, domain.ErrNotFound)
+
+		_, err = s.GetUser(ctx, user.ID)
+		expectError(t, err, domain.ErrNotFound)
+		_, err = s.GetUserByID(ctx, "user1")
+		expectError(t, err, domain.ErrNotFound)
+		_, err = s.GetUserAccess(ctx, user.ID)
+		expectError(t, err, domain.ErrNotFound)
+		_, err = s.SetUserDisabled(ctx, user.ID, time.Now())
+		expectError(t, err, domain.ErrNotFound)
+
+		// логин удаленного пользователя остается занятым
+		_, err = s.Insert(ctx, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		expectError(t, err, domain.ErrConflict)
+
+		users, err := s.ListUsers(ctx, domain.UsersListParams{SortBy: domain.UsersSortByID, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 1 || users[0].Login != "user2" {
+			t.Errorf("expected deleted user excluded from list, got: %+v", users)
+		}
+	})
+}
+
+func testAccountTokens(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("use_once", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		now := time.Now().UTC()
+
+		token, err := s.InsertAccountToken(ctx, domain.AccountToken{UserID: user.ID, Purpose: domain.AccountTokenResetPassword,
+			TokenHash: "hash1", Email: "user1@example.com", ExpiresAt: now.Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetAccountToken(ctx, domain.AccountTokenResetPassword, "hash1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != token.ID || got.UserID != user.ID || got.Email != "user1@example.com" || !got.UsedAt.IsZero() {
+			t.Errorf("expected token: %+v, got: %+v", token, got)
+		}
+		_, err = s.GetAccountToken(ctx, domain.AccountTokenVerifyEmail, "hash1")
+		expectError(t, err, domain.ErrNotFound)
+
+		err = s.UseAccountToken(ctx, token.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.UseAccountToken(ctx, token.ID, now)
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("expired_and_replaced", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		now := time.Now().UTC()
+
+		expired, err := s.InsertAccountToken(ctx, domain.AccountToken{UserID: user.ID, Purpose: domain.AccountTokenVerifyEmail,
+			TokenHash: "hash1", ExpiresAt: now.Add(-time.Second)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.UseAccountToken(ctx, expired.ID, now)
+		expectError(t, err, domain.ErrNotFound)
+
+		old, err := s.InsertAccountToken(ctx, domain.AccountToken{UserID: user.ID, Purpose: domain.AccountTokenVerifyEmail,
+			TokenHash: "hash2", ExpiresAt: now.Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.InsertAccountToken(ctx, domain.AccountToken{UserID: user.ID, Purpose: domain.AccountTokenVerifyEmail,
+			TokenHash: "hash3", ExpiresAt: now.Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.UseAccountToken(ctx, old.ID, now)
+		expectError(t, err, domain.ErrNotFound)
+
+		_, err = s.InsertAccountToken(ctx, domain.AccountToken{UserID: user.ID + 1, Purpose: domain.AccountTokenVerifyEmail,
+			TokenHash: "hash4", ExpiresAt: now.Add(time.Hour)})
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("email_verified", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt", Email: "user1@example.com"})
+		if !user.EmailVerifiedAt.IsZero() {
+			t.Errorf("expected new user not verified, got: %v", user.EmailVerifiedAt)
+		}
+
+		err := s.SetEmailVerified(ctx, user.ID, "other@example.com", time.Now().UTC())
+		expectError(t, err, domain.ErrNotFound)
+
+		verifiedAt := time.Now().UTC().Truncate(time.Microsecond)
+		err = s.SetEmailVerified(ctx, user.ID, "user1@example.com", verifiedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.EmailVerifiedAt.Equal(verifiedAt) {
+			t.Errorf("expected email verified at %v, got: %v", verifiedAt, got.EmailVerifiedAt)
+		}
+
+		// новый адрес нужно подтвердить заново
+		got.Email = "user1@example.org"
+		updated, err := s.UpdateUser(ctx, got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !updated.EmailVerifiedAt.IsZero() {
+			t.Errorf("expected changed email not verified, got: %v", updated.EmailVerifiedAt)
+		}
+	})
+}
+
+func testTwoFactor(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("enable_disable", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		err := s.EnableTOTP(ctx, user.ID, now, []string{"code1"})
+		expectError(t, err, domain.ErrNotFound)
+
+		err = s.SetTOTPSecret(ctx, user.ID, "SECRET")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.EnableTOTP(ctx, user.ID, now, []string{"code1", "code2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.TOTPSecret != "SECRET" || !got.TOTPEnabledAt.Equal(now) {
+			t.Errorf("expected enabled totp, got secret: %q, enabled at: %v", got.TOTPSecret, got.TOTPEnabledAt)
+		}
+
+		// секрет включенного второго фактора не перезаписывается
+		err = s.SetTOTPSecret(ctx, user.ID, "OTHER")
+		expectError(t, err, domain.ErrNotFound)
+
+		err = s.DisableTOTP(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = s.GetUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.TOTPSecret != "" || !got.TOTPEnabledAt.IsZero() {
+			t.Errorf("expected disabled totp, got secret: %q, enabled at: %v", got.TOTPSecret, got.TOTPEnabledAt)
+		}
+		count, err := s.CountRecoveryCodes(ctx, user.ID)
+		if err != nil || count != 0 {
+			t.Errorf("expected recovery codes deleted, got: %d, %v", count, err)
+		}
+
+		err = s.DisableTOTP(ctx, user.ID+1)
+		expectError(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("use_codes_once", func(t *testing.T) {
+		s := newStorage(t)
+		user := mustInsertUser(t, s, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt"})
+		err := s.SetTOTPSecret(ctx, user.ID, "SECRET")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.EnableTOTP(ctx, user.ID, time.Now().UTC(), []string{"code1", "code2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.UseTOTPCounter(ctx, user.ID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.UseTOTPCounter(ctx, user.ID, 10)
+		expectError(t, err, domain.ErrNotFound)
+		err = s.UseTOTPCounter(ctx, user.ID, 9)
+		expectError(t, err, domain.ErrNotFound)
+
+		err = s.UseRecoveryCode(ctx, user.ID, "code1", time.Now().UTC())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.UseRecoveryCode(ctx, user.ID, "code1", time.Now().UTC())
+		expectError(t, err, domain.ErrNotFound)
+		err = s.UseRecoveryCode(ctx, user.ID+1, "code2", time.Now().UTC())
+		expectError(t, err, domain.ErrNotFound)
+
+		count, err := s.CountRecoveryCodes(ctx, user.ID)
+		if err != nil || count != 1 {
+			t.Errorf("expected 1 recovery code left, got: %d, %v", count, err)
+		}
+	})
+}
+
+func testAuditEvents(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	start := time.Now().UTC().Truncate(time.Microsecond)
+
+	events := []domain.AuditEvent{
+		{ActorID: 1, Actor: "user1", Action: domain.AuditLogin, Target: domain.UserTarget(1), IP: "10.0.0.1",
+			UserAgent: "curl", RequestID: "req1", Outcome: domain.AuditSuccess, CreatedAt: start},
+		{Actor: "user2", Action: domain.AuditLogin, IP: "10.0.0.2", Outcome: domain.AuditFailure, Reason: "invalid_password",
+			CreatedAt: start.Add(time.Minute)},
+		{ActorID: 1, Action: domain.AuditLogout, Outcome: domain.AuditSuccess, CreatedAt: start.Add(2 * time.Minute)},
+		{Actor: domain.AuditActorSystem, Action: domain.AuditPasswordNotification, Target: domain.UserTarget(2),
+			Outcome: domain.AuditSuccess, CreatedAt: start.Add(3 * time.Minute)},
+	}
+	for i, event := range events {
+		inserted, err := s.InsertAuditEvent(ctx, event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted.ID == 0 || (i > 0 && inserted.ID <= events[i-1].ID) {
+			t.Fatalf("expected increasing id, got: %d", inserted.ID)
+		}
+		events[i] = inserted
+	}
+
+	got, err := s.ListAuditEvents(ctx, domain.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("expected %d events, got: %d", len(events), len(got))
+	}
+	for i := range got {
+		if !got[i].CreatedAt.Equal(events[i].CreatedAt) {
+			t.Errorf("expected created at %v, got: %v", events[i].CreatedAt, got[i].CreatedAt)
+		}
+		got[i].CreatedAt = events[i].CreatedAt
+		if got[i] != events[i] {
+			t.Errorf("expected event: %+v, got: %+v", events[i], got[i])
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		filter  domain.AuditFilter
+		wantIDs []int64
+	}{
+		{
+			name:    "actor_id",
+			filter:  domain.AuditFilter{ActorID: 1, Limit: 10},
+			wantIDs: []int64{events[0].ID, events[2].ID},
+		},
+		{
+			name:    "actor_and_action",
+			filter:  domain.AuditFilter{Actor: "user2", Action: domain.AuditLogin, Limit: 10},
+			wantIDs: []int64{events[1].ID},
+		},
+		{
+			name:    "time_range",
+			filter:  domain.AuditFilter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute), Limit: 10},
+			wantIDs: []int64{events[1].ID, events[2].ID},
+		},
+		{
+			name:    "after_id_and_limit",
+			filter:  domain.AuditFilter{AfterID: events[0].ID, Limit: 2},
+			wantIDs: []int64{events[1].ID, events[2].ID},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.ListAuditEvents(ctx, tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int64, 0, len(got))
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.wantIDs) {
+				t.Errorf("expected ids: %v, got: %v", tc.wantIDs, ids)
+			}
+		})
+	}
+}
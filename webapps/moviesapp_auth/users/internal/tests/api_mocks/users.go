@@ -3,15 +3,18 @@
 //
 // Generated by this command:
 //
-//	mockgen -source users.go -destination ../../tests/api_mocks/users.go package apimocks
+//	mockgen -source users.go -destination ../../tests/api_mocks/users.go
 //
+
 // Package mock_handlers is a generated GoMock package.
 package mock_handlers
 
 import (
 	context "context"
-	reflect "reflect"
 	domain "movies-auth/users/internal/domain"
+	tokens "movies-auth/users/internal/tokens"
+	reflect "reflect"
+	time "time"
 
 	uuid "github.com/google/uuid"
 	gomock "go.uber.org/mock/gomock"
@@ -40,6 +43,66 @@ func (m *MockUsersService) EXPECT() *MockUsersServiceMockRecorder {
 	return m.recorder
 }
 
+// BeginTwoFactor mocks base method.
+func (m *MockUsersService) BeginTwoFactor(ctx context.Context, userID int, password string) (domain.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginTwoFactor", ctx, userID, password)
+	ret0, _ := ret[0].(domain.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginTwoFactor indicates an expected call of BeginTwoFactor.
+func (mr *MockUsersServiceMockRecorder) BeginTwoFactor(ctx, userID, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTwoFactor", reflect.TypeOf((*MockUsersService)(nil).BeginTwoFactor), ctx, userID, password)
+}
+
+// ChangePassword mocks base method.
+func (m *MockUsersService) ChangePassword(ctx context.Context, login, password, newPassword, code string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, login, password, newPassword, code)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockUsersServiceMockRecorder) ChangePassword(ctx, login, password, newPassword, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockUsersService)(nil).ChangePassword), ctx, login, password, newPassword, code)
+}
+
+// CompleteLogin mocks base method.
+func (m *MockUsersService) CompleteLogin(ctx context.Context, challenge, code string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteLogin", ctx, challenge, code)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteLogin indicates an expected call of CompleteLogin.
+func (mr *MockUsersServiceMockRecorder) CompleteLogin(ctx, challenge, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteLogin", reflect.TypeOf((*MockUsersService)(nil).CompleteLogin), ctx, challenge, code)
+}
+
+// ConfirmTwoFactor mocks base method.
+func (m *MockUsersService) ConfirmTwoFactor(ctx context.Context, userID int, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTwoFactor", ctx, userID, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTwoFactor indicates an expected call of ConfirmTwoFactor.
+func (mr *MockUsersServiceMockRecorder) ConfirmTwoFactor(ctx, userID, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTwoFactor", reflect.TypeOf((*MockUsersService)(nil).ConfirmTwoFactor), ctx, userID, code)
+}
+
 // Create mocks base method.
 func (m *MockUsersService) Create(ctx context.Context, user domain.User) (domain.User, error) {
 	m.ctrl.T.Helper()
@@ -55,6 +118,64 @@ func (mr *MockUsersServiceMockRecorder) Create(ctx, user any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUsersService)(nil).Create), ctx, user)
 }
 
+// Delete mocks base method.
+func (m *MockUsersService) Delete(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUsersServiceMockRecorder) Delete(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUsersService)(nil).Delete), ctx, id)
+}
+
+// DisableTwoFactor mocks base method.
+func (m *MockUsersService) DisableTwoFactor(ctx context.Context, userID int, password, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTwoFactor", ctx, userID, password, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTwoFactor indicates an expected call of DisableTwoFactor.
+func (mr *MockUsersServiceMockRecorder) DisableTwoFactor(ctx, userID, password, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTwoFactor", reflect.TypeOf((*MockUsersService)(nil).DisableTwoFactor), ctx, userID, password, code)
+}
+
+// Get mocks base method.
+func (m *MockUsersService) Get(ctx context.Context, id int) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockUsersServiceMockRecorder) Get(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUsersService)(nil).Get), ctx, id)
+}
+
+// List mocks base method.
+func (m *MockUsersService) List(ctx context.Context, params domain.UsersListParams, cursor string) (domain.UsersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, params, cursor)
+	ret0, _ := ret[0].(domain.UsersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockUsersServiceMockRecorder) List(ctx, params, cursor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockUsersService)(nil).List), ctx, params, cursor)
+}
+
 // Login mocks base method.
 func (m *MockUsersService) Login(ctx context.Context, user domain.User) (domain.User, error) {
 	m.ctrl.T.Helper()
@@ -70,6 +191,151 @@ func (mr *MockUsersServiceMockRecorder) Login(ctx, user any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUsersService)(nil).Login), ctx, user)
 }
 
+// RequestEmailVerification mocks base method.
+func (m *MockUsersService) RequestEmailVerification(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestEmailVerification", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestEmailVerification indicates an expected call of RequestEmailVerification.
+func (mr *MockUsersServiceMockRecorder) RequestEmailVerification(ctx, login any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestEmailVerification", reflect.TypeOf((*MockUsersService)(nil).RequestEmailVerification), ctx, login)
+}
+
+// RequestPasswordReset mocks base method.
+func (m *MockUsersService) RequestPasswordReset(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPasswordReset", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPasswordReset indicates an expected call of RequestPasswordReset.
+func (mr *MockUsersServiceMockRecorder) RequestPasswordReset(ctx, login any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPasswordReset", reflect.TypeOf((*MockUsersService)(nil).RequestPasswordReset), ctx, login)
+}
+
+// RequiresEmailVerification mocks base method.
+func (m *MockUsersService) RequiresEmailVerification(user domain.User) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequiresEmailVerification", user)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// RequiresEmailVerification indicates an expected call of RequiresEmailVerification.
+func (mr *MockUsersServiceMockRecorder) RequiresEmailVerification(user any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequiresEmailVerification", reflect.TypeOf((*MockUsersService)(nil).RequiresEmailVerification), user)
+}
+
+// ResetPassword mocks base method.
+func (m *MockUsersService) ResetPassword(ctx context.Context, token, newPassword string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, token, newPassword)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockUsersServiceMockRecorder) ResetPassword(ctx, token, newPassword any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockUsersService)(nil).ResetPassword), ctx, token, newPassword)
+}
+
+// ResetTwoFactor mocks base method.
+func (m *MockUsersService) ResetTwoFactor(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetTwoFactor", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetTwoFactor indicates an expected call of ResetTwoFactor.
+func (mr *MockUsersServiceMockRecorder) ResetTwoFactor(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetTwoFactor", reflect.TypeOf((*MockUsersService)(nil).ResetTwoFactor), ctx, userID)
+}
+
+// SetDisabled mocks base method.
+func (m *MockUsersService) SetDisabled(ctx context.Context, id int, disabled bool) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDisabled", ctx, id, disabled)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetDisabled indicates an expected call of SetDisabled.
+func (mr *MockUsersServiceMockRecorder) SetDisabled(ctx, id, disabled any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDisabled", reflect.TypeOf((*MockUsersService)(nil).SetDisabled), ctx, id, disabled)
+}
+
+// TwoFactorQRCode mocks base method.
+func (m *MockUsersService) TwoFactorQRCode(ctx context.Context, userID int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TwoFactorQRCode", ctx, userID)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TwoFactorQRCode indicates an expected call of TwoFactorQRCode.
+func (mr *MockUsersServiceMockRecorder) TwoFactorQRCode(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TwoFactorQRCode", reflect.TypeOf((*MockUsersService)(nil).TwoFactorQRCode), ctx, userID)
+}
+
+// TwoFactorStatus mocks base method.
+func (m *MockUsersService) TwoFactorStatus(ctx context.Context, userID int) (domain.TwoFactorStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TwoFactorStatus", ctx, userID)
+	ret0, _ := ret[0].(domain.TwoFactorStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TwoFactorStatus indicates an expected call of TwoFactorStatus.
+func (mr *MockUsersServiceMockRecorder) TwoFactorStatus(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TwoFactorStatus", reflect.TypeOf((*MockUsersService)(nil).TwoFactorStatus), ctx, userID)
+}
+
+// Update mocks base method.
+func (m *MockUsersService) Update(ctx context.Context, id int, update domain.UserUpdate) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, id, update)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockUsersServiceMockRecorder) Update(ctx, id, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUsersService)(nil).Update), ctx, id, update)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockUsersService) VerifyEmail(ctx context.Context, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockUsersServiceMockRecorder) VerifyEmail(ctx, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockUsersService)(nil).VerifyEmail), ctx, token)
+}
+
 // MockSessionService is a mock of SessionService interface.
 type MockSessionService struct {
 	ctrl     *gomock.Controller
@@ -93,46 +359,133 @@ func (m *MockSessionService) EXPECT() *MockSessionServiceMockRecorder {
 	return m.recorder
 }
 
+// CookieExpires mocks base method.
+func (m *MockSessionService) CookieExpires(session domain.Session) time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CookieExpires", session)
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// CookieExpires indicates an expected call of CookieExpires.
+func (mr *MockSessionServiceMockRecorder) CookieExpires(session any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CookieExpires", reflect.TypeOf((*MockSessionService)(nil).CookieExpires), session)
+}
+
 // CreateSession mocks base method.
-func (m *MockSessionService) CreateSession(userId int) (domain.Session, error) {
+func (m *MockSessionService) CreateSession(ctx context.Context, userId int, device domain.Device) (domain.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateSession", userId)
+	ret := m.ctrl.Call(m, "CreateSession", ctx, userId, device)
 	ret0, _ := ret[0].(domain.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateSession indicates an expected call of CreateSession.
-func (mr *MockSessionServiceMockRecorder) CreateSession(userId any) *gomock.Call {
+func (mr *MockSessionServiceMockRecorder) CreateSession(ctx, userId, device any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSessionService)(nil).CreateSession), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSessionService)(nil).CreateSession), ctx, userId, device)
 }
 
 // DeleteSession mocks base method.
-func (m *MockSessionService) DeleteSession(key uuid.UUID) error {
+func (m *MockSessionService) DeleteSession(ctx context.Context, key uuid.UUID) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteSession", key)
+	ret := m.ctrl.Call(m, "DeleteSession", ctx, key)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteSession indicates an expected call of DeleteSession.
-func (mr *MockSessionServiceMockRecorder) DeleteSession(key any) *gomock.Call {
+func (mr *MockSessionServiceMockRecorder) DeleteSession(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockSessionService)(nil).DeleteSession), ctx, key)
+}
+
+// JWKS mocks base method.
+func (m *MockSessionService) JWKS() tokens.JWKS {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(tokens.JWKS)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockSessionServiceMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockSessionService)(nil).JWKS))
+}
+
+// Refresh mocks base method.
+func (m *MockSessionService) Refresh(ctx context.Context, refreshToken string) (domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx, refreshToken)
+	ret0, _ := ret[0].(domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockSessionServiceMockRecorder) Refresh(ctx, refreshToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockSessionService)(nil).Refresh), ctx, refreshToken)
+}
+
+// RevokeUserSession mocks base method.
+func (m *MockSessionService) RevokeUserSession(ctx context.Context, userID, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserSession", ctx, userID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserSession indicates an expected call of RevokeUserSession.
+func (mr *MockSessionServiceMockRecorder) RevokeUserSession(ctx, userID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserSession", reflect.TypeOf((*MockSessionService)(nil).RevokeUserSession), ctx, userID, id)
+}
+
+// RevokeUserSessions mocks base method.
+func (m *MockSessionService) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserSessions", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeUserSessions indicates an expected call of RevokeUserSessions.
+func (mr *MockSessionServiceMockRecorder) RevokeUserSessions(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserSessions", reflect.TypeOf((*MockSessionService)(nil).RevokeUserSessions), ctx, userID)
+}
+
+// UserSessions mocks base method.
+func (m *MockSessionService) UserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserSessions", ctx, userID)
+	ret0, _ := ret[0].([]domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserSessions indicates an expected call of UserSessions.
+func (mr *MockSessionServiceMockRecorder) UserSessions(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockSessionService)(nil).DeleteSession), key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserSessions", reflect.TypeOf((*MockSessionService)(nil).UserSessions), ctx, userID)
 }
 
 // ValidateSession mocks base method.
-func (m *MockSessionService) ValidateSession(key uuid.UUID) (domain.Session, error) {
+func (m *MockSessionService) ValidateSession(ctx context.Context, key uuid.UUID) (domain.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ValidateSession", key)
+	ret := m.ctrl.Call(m, "ValidateSession", ctx, key)
 	ret0, _ := ret[0].(domain.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ValidateSession indicates an expected call of ValidateSession.
-func (mr *MockSessionServiceMockRecorder) ValidateSession(key any) *gomock.Call {
+func (mr *MockSessionServiceMockRecorder) ValidateSession(ctx, key any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateSession", reflect.TypeOf((*MockSessionService)(nil).ValidateSession), key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateSession", reflect.TypeOf((*MockSessionService)(nil).ValidateSession), ctx, key)
 }
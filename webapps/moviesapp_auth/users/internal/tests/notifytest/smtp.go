@@ -0,0 +1,152 @@
+// Package notifytest содержит локальные заглушки каналов уведомлений для тестов.
+package notifytest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Mail - письмо, принятое SMTPServer.
+type Mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// SMTPServer - минимальный SMTP-сервер без аутентификации и TLS, принимающий все письма.
+type SMTPServer struct {
+	Host string
+	Port int
+
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []Mail
+	received chan Mail
+}
+
+// NewSMTPServer запускает сервер на свободном локальном порту и останавливает его в конце теста.
+func NewSMTPServer(t *testing.T) *SMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &SMTPServer{
+		Host:     host,
+		Port:     portNum,
+		listener: listener,
+		received: make(chan Mail, 100),
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	return s
+}
+
+// Received возвращает канал, в который попадает каждое принятое письмо.
+func (s *SMTPServer) Received() <-chan Mail {
+	return s.received
+}
+
+func (s *SMTPServer) Mails() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Mail(nil), s.mails...)
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP notifytest")
+
+	var mail Mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			mail = Mail{From: trimAddress(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			mail.To = append(mail.To, trimAddress(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			mail.Data = data
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			s.received <- mail
+			reply("250 OK")
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func trimAddress(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "<>")
+}
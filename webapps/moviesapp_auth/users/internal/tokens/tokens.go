@@ -0,0 +1,233 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minHS256SecretSize - минимальная длина секрета HS256, равная длине подписи.
+const minHS256SecretSize = sha256.Size
+
+var ErrInvalidToken = errors.New("invalid token")
+var ErrTokenExpired = errors.New("token expired")
+var ErrInvalidKey = errors.New("invalid key")
+
+var encoding = base64.RawURLEncoding
+
+// Key - ключ подписи токенов. Ключи HS256 симметричные и не публикуются в JWKS.
+type Key struct {
+	ID        string
+	Algorithm string
+	secret    []byte
+	private   ed25519.PrivateKey
+	public    ed25519.PublicKey
+}
+
+func NewHS256Key(id string, secret []byte) (Key, error) {
+	if len(secret) < minHS256SecretSize {
+		return Key{}, fmt.Errorf("key %q: secret must be at least %d bytes: %w", id, minHS256SecretSize, ErrInvalidKey)
+	}
+
+	return Key{ID: id, Algorithm: AlgHS256, secret: secret}, nil
+}
+
+// NewEdDSAKey создает ключ Ed25519 из seed длиной ed25519.SeedSize.
+func NewEdDSAKey(id string, seed []byte) (Key, error) {
+	if len(seed) != ed25519.SeedSize {
+		return Key{}, fmt.Errorf("key %q: seed must be %d bytes: %w", id, ed25519.SeedSize, ErrInvalidKey)
+	}
+
+	private := ed25519.NewKeyFromSeed(seed)
+
+	return Key{ID: id, Algorithm: AlgEdDSA, private: private, public: private.Public().(ed25519.PublicKey)}, nil
+}
+
+func (k Key) sign(data []byte) []byte {
+	if k.Algorithm == AlgEdDSA {
+		return ed25519.Sign(k.private, data)
+	}
+
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+func (k Key) verify(data []byte, signature []byte) bool {
+	if k.Algorithm == AlgEdDSA {
+		return ed25519.Verify(k.public, data, signature)
+	}
+
+	return hmac.Equal(k.sign(data), signature)
+}
+
+// Claims - содержимое access токена. SessionID - id сессии, к которой выпущен токен. Это не ключ сессии:
+// содержимое токена может прочитать любой, а ключ дает доступ к сессии.
+type Claims struct {
+	Issuer      string   `json:"iss,omitempty"`
+	Subject     string   `json:"sub"`
+	SessionID   string   `json:"sid"`
+	IssuedAt    int64    `json:"iat"`
+	ExpiresAt   int64    `json:"exp"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// Manager подписывает токены активным ключом и проверяет их любым из известных ключей,
+// поэтому при ротации старый ключ остается в списке, пока не истекут подписанные им токены.
+type Manager struct {
+	issuer  string
+	signing Key
+	keys    map[string]Key
+}
+
+func NewManager(issuer string, signingKeyID string, keys ...Key) (*Manager, error) {
+	m := &Manager{
+		issuer: issuer,
+		keys:   make(map[string]Key, len(keys)),
+	}
+	for _, key := range keys {
+		if _, ok := m.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key %q: %w", key.ID, ErrInvalidKey)
+		}
+		m.keys[key.ID] = key
+	}
+
+	signing, ok := m.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found: %w", signingKeyID, ErrInvalidKey)
+	}
+	m.signing = signing
+
+	return m, nil
+}
+
+// Sign подписывает claims активным ключом, пустой Issuer заменяется издателем менеджера.
+func (m *Manager) Sign(claims Claims) (string, error) {
+	if claims.Issuer == "" {
+		claims.Issuer = m.issuer
+	}
+
+	headerBytes, err := json.Marshal(header{Algorithm: m.signing.Algorithm, Type: "JWT", KeyID: m.signing.ID})
+	if err != nil {
+		return "", err
+	}
+	claimsBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := encoding.EncodeToString(headerBytes) + "." + encoding.EncodeToString(claimsBytes)
+	signature := m.signing.sign([]byte(signed))
+
+	return signed + "." + encoding.EncodeToString(signature), nil
+}
+
+// Verify проверяет подпись, издателя и срок действия токена на момент now.
+func (m *Manager) Verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("malformed token: %w", ErrInvalidToken)
+	}
+
+	var h header
+	err := decodePart(parts[0], &h)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	key, ok := m.keys[h.KeyID]
+	// алгоритм задается ключом, а не заголовком токена
+	if !ok || h.Algorithm != key.Algorithm {
+		return Claims{}, fmt.Errorf("unknown key %q: %w", h.KeyID, ErrInvalidToken)
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return Claims{}, fmt.Errorf("bad signature: %w", ErrInvalidToken)
+	}
+
+	var claims Claims
+	err = decodePart(parts[1], &claims)
+	if err != nil {
+		return Claims{}, err
+	}
+	if m.issuer != "" && claims.Issuer != m.issuer {
+		return Claims{}, fmt.Errorf("unexpected issuer %q: %w", claims.Issuer, ErrInvalidToken)
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrTokenExpired
+	}
+
+	return claims, nil
+}
+
+func decodePart(part string, dst any) error {
+	data, err := encoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("malformed token: %w", ErrInvalidToken)
+	}
+
+	err = json.Unmarshal(data, dst)
+	if err != nil {
+		return fmt.Errorf("malformed token: %w", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// JWK - открытый ключ Ed25519 в формате RFC 8037.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые ключи EdDSA, которыми другие сервисы могут проверять токены.
+func (m *Manager) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(m.keys))}
+	for _, key := range m.keys {
+		if key.Algorithm != AlgEdDSA {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         encoding.EncodeToString(key.public),
+			KeyID:     key.ID,
+			Algorithm: AlgEdDSA,
+			Use:       "sig",
+		})
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID
+	})
+
+	return jwks
+}
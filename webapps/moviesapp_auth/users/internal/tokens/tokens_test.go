@@ -0,0 +1,192 @@
+package tokens
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeys(t *testing.T) (Key, Key) {
+	t.Helper()
+
+	hs, err := NewHS256Key("hs-1", bytes.Repeat([]byte("s"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := NewEdDSAKey("ed-1", bytes.Repeat([]byte("e"), ed25519.SeedSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hs, ed
+}
+
+func TestSignVerify(t *testing.T) {
+	hs, ed := newTestKeys(t)
+	now := time.Now()
+	claims := Claims{Subject: "1", SessionID: "3", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(),
+		Roles: []string{"editor"}, Permissions: []string{"movies:read", "movies:write"}}
+
+	testCases := []struct {
+		name string
+		key  Key
+	}{
+		{
+			name: "success_hs256",
+			key:  hs,
+		},
+		{
+			name: "success_eddsa",
+			key:  ed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewManager("users", tc.key.ID, hs, ed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := m.Sign(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verified, err := m.Verify(token, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantClaims := claims
+			wantClaims.Issuer = "users"
+			if !reflect.DeepEqual(verified, wantClaims) {
+				t.Errorf("expected claims: %+v, got: %+v", wantClaims, verified)
+			}
+
+			_, err = m.Verify(token, now.Add(time.Minute))
+			if !errors.Is(err, ErrTokenExpired) {
+				t.Errorf("expected error: %v, got: %v", ErrTokenExpired, err)
+			}
+		})
+	}
+}
+
+func TestVerifyRotation(t *testing.T) {
+	hs, ed := newTestKeys(t)
+	now := time.Now()
+	claims := Claims{Subject: "1", ExpiresAt: now.Add(time.Minute).Unix()}
+
+	old, err := NewManager("users", hs.ID, hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := old.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// новый ключ подписывает, старый еще принимается
+	rotated, err := NewManager("users", ed.ID, hs, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rotated.Verify(token, now)
+	if err != nil {
+		t.Errorf("expected token signed with retired key to verify, got: %v", err)
+	}
+
+	// старый ключ удален
+	retired, err := NewManager("users", ed.ID, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = retired.Verify(token, now)
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", ErrInvalidToken, err)
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	hs, ed := newTestKeys(t)
+	now := time.Now()
+	m, err := NewManager("users", ed.ID, hs, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := m.Sign(Claims{Subject: "1", ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	// заголовок с ключом Ed25519, но алгоритмом HS256
+	confused := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT","kid":"ed-1"}`))
+	otherIssuer, err := NewManager("other", ed.ID, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := otherIssuer.Sign(Claims{Subject: "1", ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{
+			name:  "fail_malformed",
+			token: "token",
+		},
+		{
+			name:  "fail_bad_signature",
+			token: parts[0] + "." + parts[1] + "." + parts[2][:len(parts[2])-2] + "AA",
+		},
+		{
+			name:  "fail_tampered_claims",
+			token: parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"2","exp":9999999999}`)) + "." + parts[2],
+		},
+		{
+			name:  "fail_algorithm_confusion",
+			token: confused + "." + parts[1] + "." + parts[2],
+		},
+		{
+			name:  "fail_other_issuer",
+			token: foreign,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := m.Verify(tc.token, now)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("expected error: %v, got: %v", ErrInvalidToken, err)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	hs, ed := newTestKeys(t)
+	m, err := NewManager("users", hs.ID, hs, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := m.JWKS()
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("expected only EdDSA key published, got: %+v", jwks.Keys)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwks.Keys[0].KeyID != "ed-1" || !bytes.Equal(x, ed.public) {
+		t.Errorf("expected key: %s, got: %+v", "ed-1", jwks.Keys[0])
+	}
+}
@@ -0,0 +1,98 @@
+package totp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret - секрет "12345678901234567890" из тестовых векторов RFC 6238 в base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// последние 6 цифр 8-значных кодов SHA1 из приложения B RFC 6238
+	testCases := []struct {
+		name     string
+		unix     int64
+		wantCode string
+	}{
+		{
+			name:     "success_59",
+			unix:     59,
+			wantCode: "287082",
+		},
+		{
+			name:     "success_1111111109",
+			unix:     1111111109,
+			wantCode: "081804",
+		},
+		{
+			name:     "success_1234567890",
+			unix:     1234567890,
+			wantCode: "005924",
+		},
+		{
+			name:     "success_2000000000",
+			unix:     2000000000,
+			wantCode: "279037",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := Code(rfcSecret, Counter(time.Unix(tc.unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tc.wantCode {
+				t.Errorf("expected code: %s, got: %s", tc.wantCode, code)
+			}
+		})
+	}
+
+	_, err := Code("not base32!", 1)
+	if !errors.Is(err, ErrInvalidSecret) {
+		t.Errorf("expected error: %v, got: %v", ErrInvalidSecret, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := Code(secret, Counter(now.Add(-Period)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter, ok := Validate(secret, code, now)
+	if !ok || counter != Counter(now.Add(-Period)) {
+		t.Errorf("expected previous step code accepted, got: %d, %v", counter, ok)
+	}
+	if _, ok := Validate(secret, code, now.Add(2*Period)); ok {
+		t.Errorf("expected code outside skew rejected")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Errorf("expected short code rejected")
+	}
+}
+
+func TestURIAndQRCode(t *testing.T) {
+	uri := URI("Movies", "user 1", rfcSecret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Movies:user%201?") || !strings.Contains(uri, "secret="+rfcSecret) ||
+		!strings.Contains(uri, "issuer=Movies") {
+		t.Errorf("expected otpauth uri with secret and issuer, got: %s", uri)
+	}
+
+	png, err := QRCode(uri, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("expected png image, got: %q", png[:min(len(png), 8)])
+	}
+}
@@ -4,114 +4,348 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/metrics"
+	"movies-auth/users/internal/notifications"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 var ErrStopped = errors.New("worker stopped")
-var notificationsCount int
-var mu sync.Mutex
+
+// defaultInterval - период воркеров, для которых в конфигурации не задан положительный период.
+const defaultInterval = time.Minute
+
+const (
+	notifyTimeout = 10 * time.Second
+	// claimLease - время, на которое задание закрепляется за обработчиком.
+	// Если обработчик упал, задание будет захвачено снова по истечении lease.
+	claimLease     = 2 * notifyTimeout
+	claimBatchSize = 100
+)
 
 type UsersStore interface {
-	UpdateNotificationSent(ctx context.Context, id int) error
 	GetUsersWithExpiredPassword(ctx context.Context) ([]domain.User, error)
 }
 
+// NotificationQueue - надежная очередь заданий на уведомления.
+// CompleteNotification должна отмечать уведомление пользователя отправленным.
+// Завершение, возврат в очередь и перевод в dead принимаются только с токеном последнего захвата,
+// иначе возвращается domain.ErrLeaseLost.
+type NotificationQueue interface {
+	EnqueueNotification(ctx context.Context, job domain.NotificationJob) (bool, error)
+	ClaimNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.NotificationJob, error)
+	CountReadyNotifications(ctx context.Context, now time.Time) (int, error)
+	CompleteNotification(ctx context.Context, id int, claimToken uuid.UUID) error
+	RetryNotification(ctx context.Context, id int, claimToken uuid.UUID, nextAttemptAt time.Time, lastError string) error
+	DeadLetterNotification(ctx context.Context, id int, claimToken uuid.UUID, lastError string) error
+}
+
+type PassCheckStore interface {
+	UsersStore
+	NotificationQueue
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n notifications.Notification) error
+}
+
+// Auditor записывает события журнала аудита.
+type Auditor interface {
+	Record(ctx context.Context, event domain.AuditEvent)
+}
+
+// RetryPolicy - число попыток доставки и экспоненциальная задержка между ними.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Minute,
+	MaxDelay:    time.Hour,
+}
+
+// withDefaults подставляет значения DefaultRetryPolicy вместо незаданных в конфигурации числа попыток
+// и максимальной задержки. Нулевая BaseDelay означает повтор на следующем проходе воркера.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+
+	return p
+}
+
+// Backoff возвращает задержку перед следующей попыткой после attempt неудачных.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	return delay
+}
+
+// RunStats - результат одного прохода воркера.
+type RunStats struct {
+	Enqueued     int
+	Claimed      int
+	Sent         int
+	Retried      int
+	DeadLettered int
+	// Failed - доставленные уведомления, которые не удалось отметить, они будут доставлены повторно
+	Failed int
+	// Released - захваченные задания, которые не начали выполняться из-за остановки воркера
+	Released int
+	// LeaseLost - доставленные уведомления, задания которых к завершению захватил другой обработчик
+	LeaseLost int
+}
+
+func (s *RunStats) add(other RunStats) {
+	s.Sent += other.Sent
+	s.Retried += other.Retried
+	s.DeadLettered += other.DeadLettered
+	s.Failed += other.Failed
+	s.Released += other.Released
+	s.LeaseLost += other.LeaseLost
+}
+
 type PassCheckWorker struct {
-	store        UsersStore
+	store        PassCheckStore
+	notifier     Notifier
 	workersCount int
 	interval     time.Duration
+	retry        RetryPolicy
+	auditor      Auditor
 }
 
-func NewPassCheckWorker(interval time.Duration, store UsersStore, workersCount int) PassCheckWorker {
+// NewPassCheckWorker создает воркер уведомлений об истекших паролях. auditor может быть nil,
+// тогда отправленные уведомления не попадают в журнал аудита. Незаданные поля retry
+// берутся из DefaultRetryPolicy.
+func NewPassCheckWorker(interval time.Duration, store PassCheckStore, notifier Notifier, workersCount int, retry RetryPolicy,
+	auditor Auditor) PassCheckWorker {
+	if workersCount < 1 {
+		workersCount = 1
+	}
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
 	return PassCheckWorker{
 		interval:     interval,
 		store:        store,
+		notifier:     notifier,
 		workersCount: workersCount,
+		retry:        retry.withDefaults(),
+		auditor:      auditor,
 	}
 }
 
 func (w PassCheckWorker) Run(ctx context.Context) error {
-	ch := make(chan int)
-
-	nwg := sync.WaitGroup{}
-	for i := 0; i < w.workersCount; i++ {
-		workerNum := i + 1
-		nwg.Add(1)
-		go w.notificationWorker(ctx, &nwg, workerNum, ch)
-	}
-
 	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
-			close(ch)
-			// необходимо дождаться выполнения работы воркеров
-			nwg.Wait()
 			return ErrStopped
 		case <-ticker.C:
-			err := w.checkUsersPasswords(ctx, &nwg, ch)
-			if errors.Is(err, ErrStopped) {
-				return ErrStopped
-			}
+			stats, err := w.RunOnce(ctx)
 			if err != nil {
-				log.Println("check passwords: %w", err)
+				slog.Error("check passwords failed", slog.Any("error", err))
+			}
+			if stats.Claimed > 0 || stats.Enqueued > 0 {
+				slog.Info("password notifications processed",
+					slog.Int("enqueued", stats.Enqueued),
+					slog.Int("claimed", stats.Claimed),
+					slog.Int("sent", stats.Sent),
+					slog.Int("retried", stats.Retried),
+					slog.Int("dead_lettered", stats.DeadLettered),
+					slog.Int("failed", stats.Failed),
+					slog.Int("released", stats.Released),
+					slog.Int("lease_lost", stats.LeaseLost))
 			}
 		}
 	}
 }
 
-func (w PassCheckWorker) checkUsersPasswords(ctx context.Context, nwg *sync.WaitGroup, ch chan<- int) error {
+// RunOnce ставит в очередь уведомления для пользователей с истекшим паролем
+// и выполняет готовые задания. Захваченные задания доводятся до конца даже после отмены ctx.
+func (w PassCheckWorker) RunOnce(ctx context.Context) (RunStats, error) {
+	var stats RunStats
+
+	enqueued, err := w.enqueueExpired(ctx)
+	stats.Enqueued = enqueued
+	if err != nil {
+		return stats, err
+	}
+
+	now := time.Now().UTC()
+	ready, err := w.store.CountReadyNotifications(ctx, now)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	metrics.PassCheckQueueDepth.Set(float64(ready))
+
+	jobs, err := w.store.ClaimNotifications(ctx, now, claimLease, claimBatchSize)
+	if err != nil {
+		return stats, fmt.Errorf("failed to claim notifications: %w", err)
+	}
+	stats.Claimed = len(jobs)
+
+	ch := make(chan domain.NotificationJob, len(jobs))
+	for _, job := range jobs {
+		ch <- job
+	}
+	close(ch)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < w.workersCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var workerStats RunStats
+			for job := range ch {
+				w.process(ctx, job, &workerStats)
+			}
+
+			mu.Lock()
+			stats.add(workerStats)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return stats, nil
+}
+
+func (w PassCheckWorker) enqueueExpired(ctx context.Context) (int, error) {
 	users, err := w.store.GetUsersWithExpiredPassword(ctx)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to get users with expired password: %w", err)
 	}
 
+	enqueued := 0
+	now := time.Now().UTC()
 	for _, u := range users {
-		select {
-		case <-ctx.Done():
-			// необходимо дождаться выполнения работы воркеров
-			nwg.Wait()
-			fmt.Printf("total notifications sent: %d\n", notificationsCount)
-			return ErrStopped
-		default:
+		created, err := w.store.EnqueueNotification(ctx, domain.NotificationJob{
+			UserID:         u.ID,
+			Kind:           notifications.KindPasswordExpired,
+			IdempotencyKey: passwordExpiredKey(u),
+			NextAttemptAt:  now,
+		})
+		if err != nil {
+			return enqueued, fmt.Errorf("failed to enqueue notification for user %d: %w", u.ID, err)
+		}
+		if created {
+			enqueued++
 		}
-
-		ch <- u.ID
 	}
 
-	// ждем завершения отправки всех сообщений
-	for {
-		if notificationsCount == len(users) {
-			fmt.Printf("total notifications sent: %d\n", notificationsCount)
-			notificationsCount = 0
-			return nil
+	return enqueued, nil
+}
+
+// passwordExpiredKey - одно уведомление на каждый срок действия пароля пользователя.
+func passwordExpiredKey(user domain.User) string {
+	return fmt.Sprintf("%s-%d-%d", notifications.KindPasswordExpired, user.ID, user.PasswordExpires.Unix())
+}
+
+func (w PassCheckWorker) process(ctx context.Context, job domain.NotificationJob, stats *RunStats) {
+	// задание не начато, возвращаем его в очередь без ожидания
+	if ctx.Err() != nil {
+		err := w.store.RetryNotification(context.WithoutCancel(ctx), job.ID, job.ClaimToken, time.Now().UTC(), "worker stopped")
+		if err != nil {
+			slog.Error("release notification failed", slog.Int("job_id", job.ID), slog.Any("error", err))
 		}
+		stats.Released++
+		return
 	}
+
+	// доставку не прерываем при остановке, только по таймауту
+	notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
+	logger := slog.With(slog.Int("job_id", job.ID), slog.Int("user_id", job.UserID), slog.Int("attempt", job.Attempts))
+
+	err := w.notifier.Notify(notifyCtx, notifications.Notification{
+		Kind:           job.Kind,
+		User:           job.User,
+		IdempotencyKey: job.IdempotencyKey,
+	})
+	if err != nil {
+		w.fail(notifyCtx, logger, job, err, stats)
+		return
+	}
+
+	err = w.store.CompleteNotification(notifyCtx, job.ID, job.ClaimToken)
+	if errors.Is(err, domain.ErrLeaseLost) {
+		// доставка заняла дольше lease, задание уже выполняет другой обработчик
+		logger.Warn("notification lease lost before completion")
+		metrics.NotificationsSent.With(metrics.ResultFailure).Inc()
+		stats.LeaseLost++
+		return
+	}
+	if err != nil {
+		// задание останется захваченным и будет доставлено повторно по истечении lease
+		logger.Error("failed to complete notification", slog.Any("error", err))
+		metrics.NotificationsSent.With(metrics.ResultFailure).Inc()
+		stats.Failed++
+		return
+	}
+
+	logger.Info("notification sent")
+	w.audit(notifyCtx, job, domain.AuditSuccess, "")
+	metrics.NotificationsSent.With(metrics.ResultSuccess).Inc()
+	stats.Sent++
 }
 
-func (w PassCheckWorker) notificationWorker(ctx context.Context, wg *sync.WaitGroup, workerNum int, ch <-chan int) {
-	fmt.Printf("starting notification worker: %d\n", workerNum)
-	for {
-		select {
-		case <-ctx.Done():
-			wg.Done()
-			return
-		case id := <-ch:
-			time.Sleep(time.Second * 1)
-			dbCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-			defer cancel()
-
-			err := w.store.UpdateNotificationSent(dbCtx, id)
-			if err != nil {
-				log.Printf("user %d update failed: %s\n", id, err.Error())
-			}
-			log.Printf("worker: %d message sent for user: %d\n", workerNum, id)
-			mu.Lock()
-			notificationsCount++
-			mu.Unlock()
+func (w PassCheckWorker) fail(ctx context.Context, logger *slog.Logger, job domain.NotificationJob, notifyErr error, stats *RunStats) {
+	if job.Attempts >= w.retry.MaxAttempts {
+		logger.Error("notification dead-lettered", slog.Any("error", notifyErr))
+		err := w.store.DeadLetterNotification(ctx, job.ID, job.ClaimToken, notifyErr.Error())
+		if err != nil {
+			logger.Error("failed to dead-letter notification", slog.Any("error", err))
 		}
+		w.audit(ctx, job, domain.AuditFailure, "dead_letter")
+		metrics.NotificationsSent.With(metrics.ResultDead).Inc()
+		stats.DeadLettered++
+		return
+	}
+
+	nextAttemptAt := time.Now().UTC().Add(w.retry.Backoff(job.Attempts))
+	logger.Warn("notification failed, will retry", slog.Time("next_attempt_at", nextAttemptAt), slog.Any("error", notifyErr))
+	err := w.store.RetryNotification(ctx, job.ID, job.ClaimToken, nextAttemptAt, notifyErr.Error())
+	if err != nil {
+		logger.Error("failed to reschedule notification", slog.Any("error", err))
+	}
+	w.audit(ctx, job, domain.AuditFailure, "retry")
+	metrics.NotificationsSent.With(metrics.ResultRetry).Inc()
+	stats.Retried++
+}
+
+func (w PassCheckWorker) audit(ctx context.Context, job domain.NotificationJob, outcome string, reason string) {
+	if w.auditor == nil {
+		return
 	}
+
+	w.auditor.Record(ctx, domain.AuditEvent{
+		Actor:   domain.AuditActorSystem,
+		Action:  domain.AuditPasswordNotification,
+		Target:  domain.UserTarget(job.UserID),
+		Outcome: outcome,
+		Reason:  reason,
+	})
 }
@@ -0,0 +1,185 @@
+package workers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/notifications"
+	"movies-auth/users/internal/storage/db"
+	"movies-auth/users/internal/storage/inmemory"
+	"movies-auth/users/internal/tests/pgtest"
+	"movies-auth/users/internal/workers"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingNotifier struct {
+	mu    sync.Mutex
+	count map[int]int
+}
+
+func (n *countingNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.count[notification.User.ID]++
+
+	return nil
+}
+
+type replicaStorage struct {
+	name       string
+	newStorage func(t *testing.T) workers.PassCheckStore
+}
+
+// replicaStorages - хранилища, общие для нескольких реплик. Postgres проверяется только
+// при заданной TEST_DATABASE_URL, без нее выполняется лишь случай inmemory.
+func replicaStorages() []replicaStorage {
+	return []replicaStorage{
+		{
+			name: "inmemory",
+			newStorage: func(t *testing.T) workers.PassCheckStore {
+				return inmemory.NewStorage()
+			},
+		},
+		{
+			name: "postgres",
+			newStorage: func(t *testing.T) workers.PassCheckStore {
+				if os.Getenv(pgtest.EnvDatabaseURL) == "" {
+					t.Skipf("SKIP LOCKED claiming and claim tokens across replicas are not exercised: %s is not set", pgtest.EnvDatabaseURL)
+				}
+				return db.NewDbStorage(pgtest.NewDB(t))
+			},
+		},
+	}
+}
+
+// TestPassCheckWorkerReplicas запускает несколько воркеров над одним хранилищем,
+// как это происходит при нескольких репликах сервиса, и проверяет,
+// что каждый пользователь получил ровно одно уведомление.
+func TestPassCheckWorkerReplicas(t *testing.T) {
+	testCases := replicaStorages()
+
+	const (
+		usersCount    = 250
+		replicasCount = 4
+		runsCount     = 3
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.newStorage(t)
+
+			inserter := store.(interface {
+				Insert(ctx context.Context, user domain.User) (domain.User, error)
+			})
+			for i := 0; i < usersCount; i++ {
+				_, err := inserter.Insert(ctx, domain.User{
+					Login:           fmt.Sprintf("user%d", i),
+					Password:        "hash",
+					PasswordAlgo:    "bcrypt",
+					PasswordExpires: time.Now().Add(-time.Hour),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			notifier := &countingNotifier{count: make(map[int]int)}
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < replicasCount; i++ {
+				worker := workers.NewPassCheckWorker(time.Minute, store, notifier, 4, workers.DefaultRetryPolicy, nil)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					for run := 0; run < runsCount; run++ {
+						_, err := worker.RunOnce(ctx)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if len(notifier.count) != usersCount {
+				t.Errorf("expected notified users: %d, got: %d", usersCount, len(notifier.count))
+			}
+			for userID, count := range notifier.count {
+				if count != 1 {
+					t.Errorf("expected notifications of user %d: 1, got: %d", userID, count)
+				}
+			}
+		})
+	}
+}
+
+// TestPassCheckWorkerLeaseExpired проверяет реплику, lease которой истек во время доставки:
+// задание захватывает другая реплика, и прежний владелец уже не может ни вернуть его в очередь,
+// ни завершить, поэтому уведомление не отправляется еще раз.
+func TestPassCheckWorkerLeaseExpired(t *testing.T) {
+	for _, tc := range replicaStorages() {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.newStorage(t)
+
+			inserter := store.(interface {
+				Insert(ctx context.Context, user domain.User) (domain.User, error)
+			})
+			// пароль не истек, задание ставится в очередь только вручную
+			user, err := inserter.Insert(ctx, domain.User{Login: "user1", Password: "hash", PasswordAlgo: "bcrypt",
+				PasswordExpires: time.Now().Add(time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now().UTC()
+			_, err = store.EnqueueNotification(ctx, domain.NotificationJob{UserID: user.ID, Kind: notifications.KindPasswordExpired,
+				IdempotencyKey: "lease-expired", NextAttemptAt: now.Add(-time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// зависшая реплика захватила задание, и ее lease уже истек
+			stale, err := store.ClaimNotifications(ctx, now.Add(-time.Hour), time.Minute, 10)
+			if err != nil || len(stale) != 1 {
+				t.Fatalf("expected one claimed job, got: %+v, %v", stale, err)
+			}
+
+			notifier := &countingNotifier{count: make(map[int]int)}
+			worker := workers.NewPassCheckWorker(time.Minute, store, notifier, 1, workers.DefaultRetryPolicy, nil)
+			stats, err := worker.RunOnce(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Sent != 1 {
+				t.Fatalf("expected job reclaimed and sent, got: %+v", stats)
+			}
+
+			// зависшая реплика завершила доставку с ошибкой и пытается вернуть задание в очередь
+			err = store.RetryNotification(ctx, stale[0].ID, stale[0].ClaimToken, now, "timeout")
+			if !errors.Is(err, domain.ErrLeaseLost) {
+				t.Errorf("expected error: %v, got: %v", domain.ErrLeaseLost, err)
+			}
+			err = store.CompleteNotification(ctx, stale[0].ID, stale[0].ClaimToken)
+			if !errors.Is(err, domain.ErrLeaseLost) {
+				t.Errorf("expected error: %v, got: %v", domain.ErrLeaseLost, err)
+			}
+
+			stats, err = worker.RunOnce(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Claimed != 0 || notifier.count[user.ID] != 1 {
+				t.Errorf("expected notifications of user %d: 1, got: %d (claimed again: %d)", user.ID, notifier.count[user.ID], stats.Claimed)
+			}
+		})
+	}
+}
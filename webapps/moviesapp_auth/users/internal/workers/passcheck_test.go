@@ -0,0 +1,316 @@
+package workers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"movies-auth/users/internal/domain"
+	"movies-auth/users/internal/notifications"
+	"movies-auth/users/internal/storage/inmemory"
+	"movies-auth/users/internal/tests/notifytest"
+	"movies-auth/users/internal/workers"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// deliveryCheckingStore проверяет, что уведомление доставлено до того, как оно отмечено отправленным.
+type deliveryCheckingStore struct {
+	*inmemory.Storage
+	delivered func(user domain.User) bool
+	marked    chan int
+
+	mu       sync.Mutex
+	jobUsers map[int]domain.User
+}
+
+func (s *deliveryCheckingStore) ClaimNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.NotificationJob, error) {
+	jobs, err := s.Storage.ClaimNotifications(ctx, now, lease, limit)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range jobs {
+		s.jobUsers[job.ID] = job.User
+	}
+
+	return jobs, err
+}
+
+func (s *deliveryCheckingStore) CompleteNotification(ctx context.Context, id int, claimToken uuid.UUID) error {
+	s.mu.Lock()
+	user := s.jobUsers[id]
+	s.mu.Unlock()
+
+	if !s.delivered(user) {
+		return errors.New("notification marked sent before delivery")
+	}
+
+	err := s.Storage.CompleteNotification(ctx, id, claimToken)
+	s.marked <- user.ID
+
+	return err
+}
+
+func TestPassCheckWorkerDelivery(t *testing.T) {
+	smtpServer := notifytest.NewSMTPServer(t)
+
+	var mu sync.Mutex
+	webhookLogins := make(map[string]bool)
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Login string `json:"login"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		webhookLogins[payload.Login] = true
+		mu.Unlock()
+	}))
+	defer webhookServer.Close()
+
+	ctx := context.Background()
+	storage := inmemory.NewStorage()
+	expires := time.Now().Add(-time.Hour)
+	_, err := storage.Insert(ctx, domain.User{Login: "mail", PasswordExpires: expires,
+		Email: "mail@example.com", NotificationChannel: domain.NotificationChannelEmail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.Insert(ctx, domain.User{Login: "hook", PasswordExpires: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &deliveryCheckingStore{
+		Storage:  storage,
+		jobUsers: make(map[int]domain.User),
+		marked:   make(chan int, 2),
+		delivered: func(user domain.User) bool {
+			if user.NotificationChannel == domain.NotificationChannelEmail {
+				for _, mail := range smtpServer.Mails() {
+					if len(mail.To) == 1 && mail.To[0] == user.Email {
+						return true
+					}
+				}
+				return false
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			return webhookLogins[user.Login]
+		},
+	}
+
+	notifier := notifications.NewDispatcher(domain.NotificationChannelWebhook, map[string]notifications.Notifier{
+		domain.NotificationChannelEmail:   notifications.NewSMTPNotifier(smtpServer.Host, smtpServer.Port, "", "", "noreply@example.com"),
+		domain.NotificationChannelWebhook: notifications.NewWebhookNotifier(webhookServer.URL, time.Second),
+	})
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	worker := workers.NewPassCheckWorker(10*time.Millisecond, store, notifier, 2, workers.DefaultRetryPolicy, nil)
+	done := make(chan error)
+	go func() {
+		done <- worker.Run(runCtx)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-store.marked:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for notifications")
+		}
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, workers.ErrStopped) {
+			t.Errorf("expected error: %v, got: %v", workers.ErrStopped, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for worker to stop")
+	}
+
+	users, err := storage.GetUsersWithExpiredPassword(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 0 {
+		t.Errorf("expected all users notified, got: %+v", users)
+	}
+}
+
+type flakyNotifier struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	keys     []string
+}
+
+func (n *flakyNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.calls++
+	n.keys = append(n.keys, notification.IdempotencyKey)
+	if n.calls <= n.failures {
+		return errors.New("delivery failed")
+	}
+
+	return nil
+}
+
+func TestPassCheckWorkerRunOnce(t *testing.T) {
+	retry := workers.RetryPolicy{MaxAttempts: 3}
+
+	testCases := []struct {
+		name      string
+		failures  int
+		wantStats []workers.RunStats
+		wantSent  bool
+	}{
+		{
+			name: "success_delivered",
+			wantStats: []workers.RunStats{
+				{Enqueued: 1, Claimed: 1, Sent: 1},
+				{},
+			},
+			wantSent: true,
+		},
+		{
+			name:     "success_delivered_after_retry",
+			failures: 2,
+			wantStats: []workers.RunStats{
+				{Enqueued: 1, Claimed: 1, Retried: 1},
+				{Claimed: 1, Retried: 1},
+				{Claimed: 1, Sent: 1},
+				{},
+			},
+			wantSent: true,
+		},
+		{
+			name:     "fail_dead_lettered",
+			failures: 3,
+			wantStats: []workers.RunStats{
+				{Enqueued: 1, Claimed: 1, Retried: 1},
+				{Claimed: 1, Retried: 1},
+				{Claimed: 1, DeadLettered: 1},
+				{},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := inmemory.NewStorage()
+			_, err := storage.Insert(ctx, domain.User{Login: "user1", PasswordExpires: time.Now().Add(-time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			notifier := &flakyNotifier{failures: tc.failures}
+			worker := workers.NewPassCheckWorker(time.Minute, storage, notifier, 2, retry, nil)
+
+			for i, wantStats := range tc.wantStats {
+				stats, err := worker.RunOnce(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stats != wantStats {
+					t.Errorf("run %d: expected stats: %+v, got: %+v", i+1, wantStats, stats)
+				}
+			}
+
+			for _, key := range notifier.keys {
+				if key != notifier.keys[0] {
+					t.Errorf("expected the same idempotency key on every attempt, got: %v", notifier.keys)
+				}
+			}
+
+			users, err := storage.GetUsersWithExpiredPassword(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent := len(users) == 0; sent != tc.wantSent {
+				t.Errorf("expected notification sent: %v, got: %v", tc.wantSent, sent)
+			}
+		})
+	}
+}
+
+func TestPassCheckWorkerZeroRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemory.NewStorage()
+	_, err := storage.Insert(ctx, domain.User{Login: "user1", PasswordExpires: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// max_attempts не задан в конфигурации: первая неудача не должна переводить задание в dead
+	notifier := &flakyNotifier{failures: 1}
+	worker := workers.NewPassCheckWorker(time.Minute, storage, notifier, 1, workers.RetryPolicy{}, nil)
+
+	wantStats := []workers.RunStats{
+		{Enqueued: 1, Claimed: 1, Retried: 1},
+		{Claimed: 1, Sent: 1},
+	}
+	for i, want := range wantStats {
+		stats, err := worker.RunOnce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats != want {
+			t.Errorf("run %d: expected stats: %+v, got: %+v", i+1, want, stats)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := workers.RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	testCases := []struct {
+		name      string
+		attempt   int
+		wantDelay time.Duration
+	}{
+		{
+			name:      "success_first_attempt",
+			attempt:   1,
+			wantDelay: time.Second,
+		},
+		{
+			name:      "success_doubled",
+			attempt:   2,
+			wantDelay: 2 * time.Second,
+		},
+		{
+			name:      "success_doubled_twice",
+			attempt:   4,
+			wantDelay: 8 * time.Second,
+		},
+		{
+			name:      "success_capped",
+			attempt:   5,
+			wantDelay: 10 * time.Second,
+		},
+		{
+			name:      "success_capped_large_attempt",
+			attempt:   50,
+			wantDelay: 10 * time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay := policy.Backoff(tc.attempt)
+			if delay != tc.wantDelay {
+				t.Errorf("expected delay: %v, got: %v", tc.wantDelay, delay)
+			}
+		})
+	}
+}
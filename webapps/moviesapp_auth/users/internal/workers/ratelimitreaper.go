@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type RateLimitsStore interface {
+	DeleteExpiredRateLimits(ctx context.Context, now time.Time) (int64, error)
+}
+
+// RateLimitReaper периодически удаляет из хранилища счетчики попыток с истекшим окном.
+type RateLimitReaper struct {
+	store    RateLimitsStore
+	interval time.Duration
+}
+
+func NewRateLimitReaper(interval time.Duration, store RateLimitsStore) RateLimitReaper {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return RateLimitReaper{
+		interval: interval,
+		store:    store,
+	}
+}
+
+func (w RateLimitReaper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ErrStopped
+		case <-ticker.C:
+			deleted, err := w.store.DeleteExpiredRateLimits(ctx, time.Now().UTC())
+			if err != nil {
+				slog.Error("reap rate limits failed", slog.Any("error", err))
+				continue
+			}
+			if deleted > 0 {
+				slog.Debug("expired rate limits deleted", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
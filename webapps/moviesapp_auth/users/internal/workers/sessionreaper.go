@@ -0,0 +1,51 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type SessionsStore interface {
+	DeleteExpiredSessions(ctx context.Context, now time.Time, idleTimeout time.Duration) (int64, error)
+}
+
+// SessionReaper периодически удаляет из хранилища истекшие сессии.
+type SessionReaper struct {
+	store       SessionsStore
+	interval    time.Duration
+	idleTimeout time.Duration
+}
+
+func NewSessionReaper(interval time.Duration, store SessionsStore, idleTimeout time.Duration) SessionReaper {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return SessionReaper{
+		interval:    interval,
+		store:       store,
+		idleTimeout: idleTimeout,
+	}
+}
+
+func (w SessionReaper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ErrStopped
+		case <-ticker.C:
+			deleted, err := w.store.DeleteExpiredSessions(ctx, time.Now().UTC(), w.idleTimeout)
+			if err != nil {
+				slog.Error("reap sessions failed", slog.Any("error", err))
+				continue
+			}
+			if deleted > 0 {
+				slog.Info("expired sessions deleted", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package workers_test
+
+import (
+	"context"
+	"errors"
+	"movies-auth/users/internal/storage/inmemory"
+	"movies-auth/users/internal/workers"
+	"testing"
+	"time"
+)
+
+func TestReapersZeroInterval(t *testing.T) {
+	store := inmemory.NewStorage()
+
+	testCases := []struct {
+		name string
+		run  func(ctx context.Context) error
+	}{
+		{
+			name: "session_reaper",
+			run:  workers.NewSessionReaper(0, store, time.Minute).Run,
+		},
+		{
+			name: "rate_limit_reaper",
+			run:  workers.NewRateLimitReaper(-time.Second, store).Run,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			// без периода в конфигурации воркер работает с периодом по умолчанию, а не падает
+			err := tc.run(ctx)
+			if !errors.Is(err, workers.ErrStopped) {
+				t.Errorf("expected error: %v, got: %v", workers.ErrStopped, err)
+			}
+		})
+	}
+}